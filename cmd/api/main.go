@@ -1,13 +1,17 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/agileproject-gurpreet/csv2json/internal/database"
 	"github.com/agileproject-gurpreet/csv2json/internal/handler"
 	"github.com/agileproject-gurpreet/csv2json/internal/service"
+	"github.com/agileproject-gurpreet/csv2json/internal/storage"
 )
 
 func main() {
@@ -15,46 +19,67 @@ func main() {
 	logger := log.New(os.Stdout, "[CSV2JSON-API] ", log.LstdFlags|log.Lshortfile)
 	logger.Println("Starting CSV2JSON API server...")
 
-	// Initialize PostgreSQL database (optional)
-	var db *database.PostgresDB
-	dbConfig := database.Config{
-		Host:     getEnv("DB_HOST", "localhost"),
-		Port:     getEnv("DB_PORT", "5432"),
-		User:     getEnv("DB_USER", "postgres"),
-		Password: getEnv("DB_PASSWORD", "postgres"),
-		DBName:   getEnv("DB_NAME", "csv2json"),
-		SSLMode:  getEnv("DB_SSLMODE", "disable"),
+	if err := run(logger); err != nil {
+		logger.Fatal(err)
 	}
+}
 
-	db, err := database.NewPostgresDB(dbConfig)
-	if err != nil {
-		logger.Printf("Warning: Failed to connect to database: %v", err)
-		logger.Println("Running without database persistence - CSV conversion will still work!")
-		db = nil
-	} else {
-		defer db.Close()
-		logger.Println("Successfully connected to PostgreSQL database")
+// run serves the API until the server fails. Settings are checked before
+// the store is opened, and the store is closed before run returns.
+func run(logger *log.Logger) error {
+	// Also save each record on its own with STORE_ROWS=true, for /api/data/rows
+	storeRows := false
+	if v := getEnv("STORE_ROWS", ""); v != "" {
+		var err error
+		if storeRows, err = strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("invalid STORE_ROWS %q: %w", v, err)
+		}
+	}
 
-		// Initialize database schema
-		if err := db.InitSchema(); err != nil {
-			logger.Printf("Warning: Failed to initialize database schema: %v", err)
-			db = nil
-		} else {
-			logger.Println("Database schema initialized")
+	// Stop conversions and queries that outlive REQUEST_TIMEOUT, e.g. "5m"
+	var timeout time.Duration
+	if v := getEnv("REQUEST_TIMEOUT", ""); v != "" {
+		var err error
+		if timeout, err = time.ParseDuration(v); err != nil {
+			return fmt.Errorf("invalid REQUEST_TIMEOUT %q: %w", v, err)
 		}
 	}
 
+	// Select where uploads are stored
+	store, err := openStore(getEnv("STORAGE", "postgres"), logger)
+	if err != nil {
+		return fmt.Errorf("failed to open storage: %w", err)
+	}
+	if store != nil {
+		defer store.Close()
+	}
+
 	// Initialize service and handler
-	svc := service.NewConversionService(db)
+	svc := service.NewConversionService(store)
 	csvHandler := handler.NewCSVHandler(svc, logger)
 
+	svc.SetStoreRows(storeRows)
+	if storeRows && store != nil {
+		logger.Println("Saving each uploaded row on its own as well")
+	}
+
 	// Setup routes
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/upload", csvHandler.UploadCSV)
+	mux.HandleFunc("/api/json2csv", csvHandler.ConvertJSONToCSV)
+	mux.HandleFunc("/api/schema", csvHandler.InferSchema)
 	mux.HandleFunc("/api/data", csvHandler.GetAllData)
 	mux.HandleFunc("/api/data/id", csvHandler.GetDataByID)
+	mux.HandleFunc("/api/data/rows", csvHandler.GetRows)
+	mux.HandleFunc("/api/data/row", csvHandler.GetRow)
 	mux.HandleFunc("/api/health", csvHandler.Health)
 
+	var h http.Handler = mux
+	if timeout != 0 {
+		h = handler.WithTimeout(mux, timeout)
+		logger.Printf("Requests time out after %s", timeout)
+	}
+
 	// Start server
 	port := getEnv("PORT", "8080")
 	addr := ":" + port
@@ -62,13 +87,68 @@ func main() {
 	logger.Printf("Server starting on port %s", port)
 	logger.Println("Available endpoints:")
 	logger.Println("  POST /api/upload     - Upload CSV file")
-	logger.Println("  GET  /api/data       - Get all stored CSV data")
-	logger.Println("  GET  /api/data/id    - Get CSV data by ID (requires ?id=<id>)")
+	logger.Println("  POST /api/json2csv   - Convert JSON or NDJSON to CSV")
+	logger.Println("  POST /api/schema     - Infer the JSON Schema of a CSV file")
+	logger.Println("  GET  /api/data       - List stored CSV data (paged; filter with ?filename=, ?created_after=, ...)")
+	logger.Println("  GET  /api/data/id    - Get CSV data by ID (requires ?id=<id>, add &format=csv for CSV)")
+	logger.Println("  GET  /api/data/rows  - Get rows of CSV data (requires ?id=<id>, add &start=<n>&limit=<n>)")
+	logger.Println("  GET  /api/data/row   - Get one row of CSV data (requires ?id=<id>&row=<n>)")
 	logger.Println("  GET  /api/health     - Health check")
 
-	if err := http.ListenAndServe(addr, mux); err != nil {
-		logger.Fatalf("Server failed to start: %v", err)
+	if err := http.ListenAndServe(addr, h); err != nil {
+		return fmt.Errorf("server failed to start: %w", err)
 	}
+	return nil
+}
+
+// openStore returns the store named by backend: "postgres", configured by
+// the DB_* variables, "sqlite", a file at SQLITE_PATH, "memory" or "none".
+// A nil store disables persistence; postgres falls back to it when the
+// database cannot be reached, while an SQLite file that cannot be opened
+// is an error.
+func openStore(backend string, logger *log.Logger) (storage.Store, error) {
+	switch backend {
+	case "postgres":
+		db, err := database.NewPostgresDB(database.ConfigFromEnv())
+		if err != nil {
+			logger.Printf("Warning: Failed to connect to database: %v", err)
+			logger.Println("Running without database persistence - CSV conversion will still work!")
+			return nil, nil
+		}
+		logger.Println("Successfully connected to PostgreSQL database")
+
+		// Apply pending schema migrations
+		if err := db.InitSchema(); err != nil {
+			logger.Printf("Warning: Failed to initialize database schema: %v", err)
+			db.Close()
+			return nil, nil
+		}
+		logger.Println("Database schema is up to date")
+		return db, nil
+
+	case "sqlite":
+		path := getEnv("SQLITE_PATH", "csv2json.db")
+		db, err := database.NewSQLiteDB(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.InitSchema(); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize schema of %s: %w", path, err)
+		}
+		logger.Printf("Storing uploads in SQLite database %s", path)
+		return db, nil
+
+	case "memory":
+		logger.Println("Storing uploads in memory; they are lost on restart")
+		return storage.NewMemoryStore(), nil
+
+	case "none":
+		logger.Println("Running without persistence")
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown backend %q (want postgres, sqlite, memory or none)", backend)
 }
 
 func getEnv(key, defaultValue string) string {
@@ -0,0 +1,129 @@
+// Command migrate applies and reverts the schema migrations of the database
+// the API stores uploads in. The API applies pending migrations when it
+// starts; this command runs them on their own, reverts them, and reports
+// the schema version.
+//
+// Usage:
+//
+//	migrate [-storage postgres|sqlite] [-sqlite-path file] up | down [N] | status | version
+//
+// The database is chosen like the API's: -storage defaults to STORAGE,
+// Postgres is configured by the DB_* variables and -sqlite-path defaults to
+// SQLITE_PATH.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/database"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	backend := flags.String("storage", getEnv("STORAGE", "postgres"), "database to migrate: postgres or sqlite")
+	path := flags.String("sqlite-path", getEnv("SQLITE_PATH", "csv2json.db"), "SQLite database file")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: migrate [flags] up | down [N] | status | version")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	command := flags.Arg(0)
+	steps := 1
+	switch {
+	case command == "down" && flags.NArg() == 2:
+		n, err := strconv.Atoi(flags.Arg(1))
+		if err != nil || n < 1 {
+			fmt.Fprintf(stderr, "migrate: invalid number of steps %q\n", flags.Arg(1))
+			return 2
+		}
+		steps = n
+	case command != "up" && command != "down" && command != "status" && command != "version", flags.NArg() > 1:
+		flags.Usage()
+		return 2
+	}
+
+	migrator, closeDB, err := open(*backend, *path)
+	if err != nil {
+		fmt.Fprintf(stderr, "migrate: %v\n", err)
+		return 1
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		err = migrator.Down(ctx, steps)
+	case "status":
+		var statuses []database.MigrationStatus
+		if statuses, err = migrator.Status(ctx); err == nil {
+			for _, s := range statuses {
+				state := "pending"
+				if s.Applied {
+					state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+				}
+				fmt.Fprintf(stdout, "%04d_%s\t%s\n", s.Version, s.Name, state)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "migrate: %v\n", err)
+		return 1
+	}
+
+	// The other commands end by reporting where the schema is
+	if command != "status" {
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			fmt.Fprintf(stderr, "migrate: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "schema version %d\n", version)
+	}
+	return 0
+}
+
+// open connects to the database named by backend, returning its Migrator
+// and the function closing it.
+func open(backend, path string) (*database.Migrator, func() error, error) {
+	switch backend {
+	case "postgres":
+		db, err := database.NewPostgresDB(database.ConfigFromEnv())
+		if err != nil {
+			return nil, nil, err
+		}
+		return db.Migrator(), db.Close, nil
+	case "sqlite":
+		db, err := database.NewSQLiteDB(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db.Migrator(), db.Close, nil
+	}
+	return nil, nil, fmt.Errorf("storage %q has no schema to migrate (want postgres or sqlite)", backend)
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
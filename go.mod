@@ -2,4 +2,7 @@ module github.com/agileproject-gurpreet/csv2json
 
 go 1.21
 
-require github.com/lib/pq v1.10.9
+require (
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.33
+)
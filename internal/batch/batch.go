@@ -0,0 +1,275 @@
+// Package batch converts many CSV files concurrently.
+package batch
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/converter"
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+	"github.com/agileproject-gurpreet/csv2json/internal/validate"
+)
+
+// Options controls a batch conversion.
+type Options struct {
+	// Convert is applied to every file.
+	Convert converter.Options
+	// Workers is the most files converted at once. Zero means the number
+	// of CPUs.
+	Workers int
+	// OutputDir receives the outputs. Empty writes each output next to its
+	// input. Files found under a directory keep their relative path.
+	OutputDir string
+	// Recursive also converts CSV files in subdirectories of directories.
+	Recursive bool
+	// OnFile, if set, is called as each file completes, one call at a time.
+	OnFile func(FileResult)
+}
+
+// FileResult is the outcome of converting one file.
+type FileResult struct {
+	// Input is the CSV file and Output the JSON written for it.
+	Input  string
+	Output string
+	// Rows is the number of records written.
+	Rows int
+	// Validation lists the violations found, when validating.
+	Validation *validate.Report
+	// Err is why the file failed; nothing is written at Output then.
+	Err error
+}
+
+// Summary is the outcome of a batch, with files in input order.
+type Summary struct {
+	Files []FileResult
+	// Rows is the number of records written across all files.
+	Rows int
+	// Failed is the number of files that could not be converted.
+	Failed int
+}
+
+// input is a file to convert and the path of its output relative to the
+// output directory.
+type input struct {
+	path string
+	rel  string
+}
+
+// Run converts the CSV files matched by patterns: files, directories,
+// whose *.csv files are converted, or glob patterns. A file that fails
+// does not stop the others; its error is in the Summary. Run itself only
+// fails when a pattern is invalid or nothing matches.
+func Run(patterns []string, opts Options) (*Summary, error) {
+	if err := opts.Convert.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Workers < 0 {
+		return nil, fmt.Errorf("invalid worker count %d", opts.Workers)
+	}
+	inputs, err := expand(patterns, opts.Recursive)
+	if err != nil {
+		return nil, err
+	}
+
+	ext := ".json"
+	if opts.Convert.Format == encoder.FormatNDJSON {
+		ext = ".ndjson"
+	}
+	files := make([]FileResult, len(inputs))
+	outputs := make(map[string]string, len(inputs))
+	for i, in := range inputs {
+		files[i] = FileResult{Input: in.path, Output: outputPath(in, opts.OutputDir, ext)}
+		if other, taken := outputs[files[i].Output]; taken {
+			files[i].Err = fmt.Errorf("output %s is also the output of %s", files[i].Output, other)
+		} else if files[i].Output == in.path {
+			files[i].Err = fmt.Errorf("output would overwrite the input")
+		}
+		outputs[files[i].Output] = in.path
+	}
+
+	workers := opts.Workers
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+	workers = min(workers, len(files))
+
+	var mu sync.Mutex
+	done := func(i int) {
+		if opts.OnFile == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		opts.OnFile(files[i])
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if files[i].Err == nil {
+					convertFile(&files[i], opts.Convert)
+				}
+				done(i)
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	summary := &Summary{Files: files}
+	for _, f := range files {
+		summary.Rows += f.Rows
+		if f.Err != nil {
+			summary.Failed++
+		}
+	}
+	return summary, nil
+}
+
+// expand lists the files patterns match, each once, in pattern order.
+func expand(patterns []string, recursive bool) ([]input, error) {
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no files given")
+	}
+
+	var inputs []input
+	seen := make(map[string]bool)
+	add := func(path, rel string) {
+		if !seen[path] {
+			seen[path] = true
+			inputs = append(inputs, input{path: path, rel: rel})
+		}
+	}
+
+	for _, pattern := range patterns {
+		if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+			found, err := csvFiles(pattern, recursive)
+			if err != nil {
+				return nil, err
+			}
+			for _, path := range found {
+				rel, _ := filepath.Rel(pattern, path)
+				add(path, rel)
+			}
+			continue
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", pattern, err)
+		}
+		if matches == nil {
+			return nil, fmt.Errorf("%s: no such file", pattern)
+		}
+		for _, path := range matches {
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				add(path, filepath.Base(path))
+			}
+		}
+	}
+
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no CSV files found")
+	}
+	return inputs, nil
+}
+
+// csvFiles lists the *.csv files in dir, sorted, descending into
+// subdirectories when recursive.
+func csvFiles(dir string, recursive bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(path), ".csv") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func outputPath(in input, outputDir, ext string) string {
+	if outputDir == "" {
+		return strings.TrimSuffix(in.path, filepath.Ext(in.path)) + ext
+	}
+	return filepath.Join(outputDir, strings.TrimSuffix(in.rel, filepath.Ext(in.rel))+ext)
+}
+
+// convertFile converts f.Input to f.Output, which only appears once the
+// conversion has succeeded, and records the outcome in f.
+func convertFile(f *FileResult, opts converter.Options) {
+	result, err := writeFile(f.Input, f.Output, opts)
+	if err != nil {
+		f.Err = err
+		return
+	}
+	f.Rows, f.Validation = result.Rows, result.Validation
+}
+
+func writeFile(input, output string, opts converter.Options) (result *converter.Result, err error) {
+	in, err := os.Open(input)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+		return nil, err
+	}
+	out, err := os.CreateTemp(filepath.Dir(output), ".csv2json-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(out.Name())
+		}
+	}()
+
+	buffered := bufio.NewWriter(out)
+	result, err = converter.Convert(in, buffered, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := buffered.Flush(); err != nil {
+		return nil, err
+	}
+	// CreateTemp makes files private; outputs are ordinary files
+	if err := out.Chmod(0o644); err != nil {
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(out.Name(), output); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/batch"
+	"github.com/agileproject-gurpreet/csv2json/internal/converter"
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+	"github.com/agileproject-gurpreet/csv2json/internal/parser"
+)
+
+// writeFiles creates files under dir from a map of relative paths to content.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRun_Directory(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.csv":        "x\n1\n2\n",
+		"b.CSV":        "y\n3\n",
+		"notes.txt":    "not csv",
+		"nested/c.csv": "z\n4\n",
+	})
+
+	summary, err := batch.Run([]string{dir}, batch.Options{Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(summary.Files) != 2 || summary.Rows != 3 || summary.Failed != 0 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if got := readFile(t, filepath.Join(dir, "a.json")); got != `[{"x":"1"},{"x":"2"}]` {
+		t.Errorf("unexpected a.json %q", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "b.json")); got != `[{"y":"3"}]` {
+		t.Errorf("unexpected b.json %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "nested", "c.json")); !os.IsNotExist(err) {
+		t.Errorf("expected subdirectories to be skipped, got %v", err)
+	}
+}
+
+func TestRun_RecursiveIntoOutputDir(t *testing.T) {
+	in, out := t.TempDir(), filepath.Join(t.TempDir(), "out")
+	writeFiles(t, in, map[string]string{
+		"a.csv":        "x\n1\n",
+		"nested/a.csv": "x\n2\n",
+	})
+
+	opts := batch.Options{
+		Convert:   converter.Options{Format: encoder.FormatNDJSON},
+		OutputDir: out,
+		Recursive: true,
+	}
+	summary, err := batch.Run([]string{in}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Failed != 0 {
+		t.Fatalf("unexpected failures %+v", summary.Files)
+	}
+	if got := readFile(t, filepath.Join(out, "a.ndjson")); got != "{\"x\":\"1\"}\n" {
+		t.Errorf("unexpected a.ndjson %q", got)
+	}
+	if got := readFile(t, filepath.Join(out, "nested", "a.ndjson")); got != "{\"x\":\"2\"}\n" {
+		t.Errorf("unexpected nested/a.ndjson %q", got)
+	}
+}
+
+func TestRun_FailuresDoNotStopOthers(t *testing.T) {
+	dir := t.TempDir()
+	files := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("f%02d.csv", i)] = "x\n1\n"
+	}
+	files["f07.csv"] = "x\n\"unterminated\n"
+	writeFiles(t, dir, files)
+
+	var calls int32
+	summary, err := batch.Run([]string{filepath.Join(dir, "*.csv")}, batch.Options{
+		Workers: 4,
+		OnFile:  func(batch.FileResult) { atomic.AddInt32(&calls, 1) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Failed != 1 || summary.Rows != 19 || calls != 20 {
+		t.Fatalf("unexpected summary: %d failed, %d rows, %d calls", summary.Failed, summary.Rows, calls)
+	}
+	failed := summary.Files[7]
+	var parseErr *parser.ParseError
+	if !errors.As(failed.Err, &parseErr) {
+		t.Errorf("expected a parse error for %s, got %v", failed.Input, failed.Err)
+	}
+	if _, err := os.Stat(failed.Output); !os.IsNotExist(err) {
+		t.Errorf("expected no output for the failed file, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 39 {
+		t.Errorf("expected 20 inputs and 19 outputs, found %d entries", len(entries))
+	}
+}
+
+func TestRun_OutputCollision(t *testing.T) {
+	a, b, out := t.TempDir(), t.TempDir(), t.TempDir()
+	writeFiles(t, a, map[string]string{"data.csv": "x\n1\n"})
+	writeFiles(t, b, map[string]string{"data.csv": "x\n2\n"})
+
+	summary, err := batch.Run([]string{filepath.Join(a, "data.csv"), filepath.Join(b, "data.csv")}, batch.Options{OutputDir: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary.Failed != 1 || summary.Files[1].Err == nil {
+		t.Fatalf("expected the second file to fail, got %+v", summary.Files)
+	}
+	if got := readFile(t, filepath.Join(out, "data.json")); got != `[{"x":"1"}]` {
+		t.Errorf("unexpected data.json %q", got)
+	}
+}
+
+func TestRun_Errors(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]struct {
+		patterns []string
+		opts     batch.Options
+	}{
+		"no patterns":     {nil, batch.Options{}},
+		"no match":        {[]string{filepath.Join(dir, "*.csv")}, batch.Options{}},
+		"bad glob":        {[]string{"[x"}, batch.Options{}},
+		"invalid options": {[]string{dir}, batch.Options{Convert: converter.Options{Arrays: true, Unflatten: true}}},
+		"empty directory": {[]string{dir}, batch.Options{}},
+		"bad workers":     {[]string{dir}, batch.Options{Workers: -1}},
+	}
+	for name, tt := range tests {
+		if _, err := batch.Run(tt.patterns, tt.opts); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
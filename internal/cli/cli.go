@@ -0,0 +1,408 @@
+// Package cli implements the csv2json command.
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/batch"
+	"github.com/agileproject-gurpreet/csv2json/internal/converter"
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+	"github.com/agileproject-gurpreet/csv2json/internal/infer"
+	"github.com/agileproject-gurpreet/csv2json/internal/parser"
+	"github.com/agileproject-gurpreet/csv2json/internal/validate"
+)
+
+// Exit codes returned by Run.
+const (
+	// ExitOK means every input was converted.
+	ExitOK = 0
+	// ExitError means an input or the output could not be read or written.
+	ExitError = 1
+	// ExitUsage means the command line was invalid.
+	ExitUsage = 2
+	// ExitInvalidInput means an input was not valid CSV; the problem is
+	// printed with its line and column.
+	ExitInvalidInput = 3
+	// ExitValidation means an input failed the -validate spec.
+	ExitValidation = 4
+)
+
+// stdinName names standard input in messages, and as an argument.
+const stdinName = "-"
+
+// maxPrintedViolations caps the violations printed per input.
+const maxPrintedViolations = 20
+
+const usage = `Usage: csv2json [flags] [file ...]
+       csv2json -batch [-out-dir dir] [flags] file|dir|glob ...
+
+Converts CSV files to JSON. With no file, or "-", standard input is read.
+Several files are only accepted with -format ndjson, and are written one
+after another.
+
+With -batch, each file, each *.csv file of a directory and each match of a
+glob is converted concurrently to its own name.json (name.ndjson with
+-format ndjson), next to it or under -out-dir. A failing file does not stop
+the others; a summary is printed at the end.
+
+Exit status is 0 on success, 1 if a file cannot be read or written, 2 for
+invalid flags, 3 for malformed CSV and 4 when -validate finds violations.
+In batch mode it is the highest status of any file.
+
+Flags:
+`
+
+// config holds the parsed command line.
+type config struct {
+	opts   converter.Options
+	output string
+	inputs []string
+	batch  bool
+	// outDir, workers and recursive apply in batch mode.
+	outDir    string
+	workers   int
+	recursive bool
+}
+
+// Run executes the command with args, excluding the program name, and
+// returns its exit code. Errors are printed to stderr.
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	cfg, err := parseArgs(args, stderr)
+	if err == flag.ErrHelp {
+		return ExitOK
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "csv2json: %v\n", err)
+		return ExitUsage
+	}
+
+	if cfg.batch {
+		return convertBatch(cfg, stdout, stderr)
+	}
+	return convertAll(cfg, stdin, stdout, stderr)
+}
+
+func parseArgs(args []string, stderr io.Writer) (*config, error) {
+	fs := flag.NewFlagSet("csv2json", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+
+	var (
+		cfg        config
+		delimiter  = fs.String("delimiter", "", "field `char`acter, or tab (default: detected)")
+		quote      = fs.String("quote", "", "quote `char`acter (default: detected)")
+		comment    = fs.String("comment", "", "skip lines starting with `char`acter, such as #")
+		header     = fs.String("header", "true", "whether the first row is a header row: auto, true or false")
+		columns    = fs.String("columns", "", "comma-separated column `names` to use instead of the header row")
+		encoding   = fs.String("encoding", "", "source `encoding`, such as windows-1252 (default: UTF-8, or UTF-16 by BOM)")
+		format     = fs.String("format", "json", "output `format`: json or ndjson")
+		pretty     = fs.Bool("pretty", false, "indent JSON output")
+		indent     = fs.String("indent", "  ", "indentation used by -pretty")
+		types      = fs.String("types", "", "column types as `column:type` pairs, e.g. zip:string,qty:integer")
+		ragged     = fs.String("ragged", "strict", "rows with the wrong number of fields: strict, pad, extra or skip")
+		headerCase = fs.String("header-case", "keep", "header name case: keep, snake or camel")
+		spec       = fs.String("validate", "", "validate records against the JSON Schema or column rules in `file`")
+		mode       = fs.String("validation-mode", "reject", "reject fails on violations; report only prints them")
+	)
+	fs.StringVar(&cfg.output, "o", "", "write to `file` instead of standard output")
+	fs.BoolVar(&cfg.opts.InferTypes, "infer", false, "emit numbers, booleans, nulls and timestamps as JSON types")
+	fs.IntVar(&cfg.opts.InferRows, "infer-rows", converter.DefaultInferRows, "rows scanned by -infer")
+	fs.BoolVar(&cfg.opts.Arrays, "arrays", false, "write each row as an array of values")
+	fs.BoolVar(&cfg.opts.Unflatten, "unflatten", false, "nest values under header paths such as address.city and tags[0]")
+	fs.StringVar(&cfg.opts.Separator, "separator", encoder.DefaultSeparator, "header path separator for -unflatten")
+	fs.BoolVar(&cfg.opts.Parser.TrimHeaders, "trim-headers", false, "trim whitespace around header names")
+	fs.BoolVar(&cfg.opts.Parser.TrimSpace, "trim", false, "trim whitespace around every field and header name")
+	fs.BoolVar(&cfg.opts.Parser.LazyQuotes, "lazy-quotes", false, "accept stray quotes instead of failing")
+	fs.BoolVar(&cfg.batch, "batch", false, "convert each file to its own output, concurrently")
+	fs.StringVar(&cfg.outDir, "out-dir", "", "write batch outputs under `dir` instead of next to each input")
+	fs.IntVar(&cfg.workers, "workers", runtime.NumCPU(), "files converted at once in batch mode")
+	fs.BoolVar(&cfg.recursive, "recursive", false, "include subdirectories of directories in batch mode")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	opts := &cfg.opts
+	var err error
+	if *delimiter != "" {
+		if opts.Parser.Delimiter, err = parseChar(*delimiter); err != nil {
+			return nil, fmt.Errorf("-delimiter: %w", err)
+		}
+	}
+	if *quote != "" {
+		if opts.Parser.Quote, err = parseChar(*quote); err != nil {
+			return nil, fmt.Errorf("-quote: %w", err)
+		}
+	}
+	if *comment != "" {
+		if opts.Parser.Comment, err = parseChar(*comment); err != nil {
+			return nil, fmt.Errorf("-comment: %w", err)
+		}
+	}
+	switch *header {
+	case "auto":
+		opts.Parser.Header = parser.HeaderAuto
+	case "true":
+		opts.Parser.Header = parser.HeaderPresent
+	case "false":
+		opts.Parser.Header = parser.HeaderAbsent
+	default:
+		return nil, fmt.Errorf("-header: unknown value %q", *header)
+	}
+	if *columns != "" {
+		opts.Parser.ColumnNames = strings.Split(*columns, ",")
+	}
+	if *encoding != "" {
+		if opts.Parser.Encoding, err = parser.ParseEncoding(*encoding); err != nil {
+			return nil, fmt.Errorf("-encoding: %w", err)
+		}
+	}
+	if opts.Format, err = encoder.ParseFormat(*format); err != nil {
+		return nil, fmt.Errorf("-format: %w", err)
+	}
+	if *pretty {
+		opts.Indent = *indent
+	}
+	if *types != "" {
+		if opts.ColumnTypes, err = parseTypes(*types); err != nil {
+			return nil, fmt.Errorf("-types: %w", err)
+		}
+	}
+	if opts.Parser.Ragged, err = parser.ParseRaggedPolicy(*ragged); err != nil {
+		return nil, fmt.Errorf("-ragged: %w", err)
+	}
+	if opts.Parser.HeaderCase, err = parser.ParseHeaderCase(*headerCase); err != nil {
+		return nil, fmt.Errorf("-header-case: %w", err)
+	}
+	if *spec != "" {
+		data, err := os.ReadFile(*spec)
+		if err != nil {
+			return nil, fmt.Errorf("-validate: %w", err)
+		}
+		if opts.Validation, err = validate.ParseSpec(data); err != nil {
+			return nil, fmt.Errorf("-validate: %s: %w", *spec, err)
+		}
+	}
+	if opts.ValidationMode, err = validate.ParseMode(*mode); err != nil {
+		return nil, fmt.Errorf("-validation-mode: %w", err)
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	cfg.inputs = fs.Args()
+	if cfg.batch {
+		switch {
+		case cfg.output != "":
+			return nil, fmt.Errorf("-o cannot be used with -batch; use -out-dir")
+		case len(cfg.inputs) == 0:
+			return nil, fmt.Errorf("-batch needs at least one file, directory or glob")
+		case cfg.workers < 1:
+			return nil, fmt.Errorf("-workers must be at least 1")
+		}
+		return &cfg, nil
+	}
+	if cfg.outDir != "" || cfg.recursive {
+		return nil, fmt.Errorf("-out-dir and -recursive need -batch")
+	}
+	if len(cfg.inputs) == 0 {
+		cfg.inputs = []string{stdinName}
+	}
+	if len(cfg.inputs) > 1 && opts.Format != encoder.FormatNDJSON {
+		return nil, fmt.Errorf("several files need -format ndjson, since JSON output is a single array")
+	}
+	return &cfg, nil
+}
+
+// convertAll converts every input to the output, stopping at the first
+// failure. A file output is only created once everything has converted.
+func convertAll(cfg *config, stdin io.Reader, stdout, stderr io.Writer) int {
+	var out io.Writer = stdout
+	var file *os.File
+	if cfg.output != "" {
+		var err error
+		// Written beside the target so the final rename stays on one filesystem
+		file, err = os.CreateTemp(filepath.Dir(cfg.output), ".csv2json-*")
+		if err != nil {
+			fmt.Fprintf(stderr, "csv2json: %v\n", err)
+			return ExitError
+		}
+		defer os.Remove(file.Name())
+		defer file.Close()
+		out = file
+	}
+	buffered := bufio.NewWriter(out)
+
+	for _, name := range cfg.inputs {
+		if code := convert(name, cfg.opts, stdin, buffered, stderr); code != ExitOK {
+			buffered.Flush()
+			return code
+		}
+	}
+	if cfg.opts.Format != encoder.FormatNDJSON {
+		buffered.WriteString("\n")
+	}
+
+	if err := buffered.Flush(); err != nil {
+		fmt.Fprintf(stderr, "csv2json: %v\n", err)
+		return ExitError
+	}
+	if file == nil {
+		return ExitOK
+	}
+	// CreateTemp makes files private; the output is an ordinary file
+	if err := file.Chmod(0o644); err != nil {
+		fmt.Fprintf(stderr, "csv2json: %v\n", err)
+		return ExitError
+	}
+	if err := file.Close(); err != nil {
+		fmt.Fprintf(stderr, "csv2json: %v\n", err)
+		return ExitError
+	}
+	if err := os.Rename(file.Name(), cfg.output); err != nil {
+		fmt.Fprintf(stderr, "csv2json: %v\n", err)
+		return ExitError
+	}
+	return ExitOK
+}
+
+// convertBatch converts every input to its own output, printing each
+// outcome and a summary.
+func convertBatch(cfg *config, stdout, stderr io.Writer) int {
+	code := ExitOK
+	summary, err := batch.Run(cfg.inputs, batch.Options{
+		Convert:   cfg.opts,
+		Workers:   cfg.workers,
+		OutputDir: cfg.outDir,
+		Recursive: cfg.recursive,
+		OnFile: func(f batch.FileResult) {
+			if f.Err != nil {
+				code = max(code, printError(stderr, f.Input, f.Err))
+				return
+			}
+			if f.Validation != nil && !f.Validation.Valid() {
+				printViolations(stderr, f.Input, f.Validation)
+			}
+			fmt.Fprintf(stdout, "%s -> %s (%d rows)\n", f.Input, f.Output, f.Rows)
+		},
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "csv2json: %v\n", err)
+		return ExitError
+	}
+
+	fmt.Fprintf(stdout, "%d files: %d converted, %d failed, %d rows\n",
+		len(summary.Files), len(summary.Files)-summary.Failed, summary.Failed, summary.Rows)
+	return code
+}
+
+// convert converts the input called name to w and prints any problem.
+func convert(name string, opts converter.Options, stdin io.Reader, w io.Writer, stderr io.Writer) int {
+	var in io.Reader = stdin
+	if name != stdinName {
+		file, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(stderr, "csv2json: %v\n", err)
+			return ExitError
+		}
+		defer file.Close()
+		in = file
+	}
+
+	result, err := converter.Convert(in, w, opts)
+	if err != nil {
+		return printError(stderr, displayName(name), err)
+	}
+	if result.Validation != nil && !result.Validation.Valid() {
+		printViolations(stderr, displayName(name), result.Validation)
+	}
+	return ExitOK
+}
+
+// printError describes a failed conversion and returns its exit code.
+func printError(stderr io.Writer, name string, err error) int {
+	var parseErr *parser.ParseError
+	if errors.As(err, &parseErr) {
+		fmt.Fprintf(stderr, "csv2json: %s:%d:%d: %s\n", name, parseErr.Line, parseErr.Column, parseErr.Reason)
+		if parseErr.Snippet != "" {
+			fmt.Fprintf(stderr, "    %s\n", parseErr.Snippet)
+		}
+		return ExitInvalidInput
+	}
+	var pathErr *encoder.PathError
+	if errors.As(err, &pathErr) {
+		fmt.Fprintf(stderr, "csv2json: %s: %v\n", name, pathErr)
+		return ExitInvalidInput
+	}
+	var validationErr *validate.Error
+	if errors.As(err, &validationErr) {
+		printViolations(stderr, name, validationErr.Report)
+		return ExitValidation
+	}
+
+	fmt.Fprintf(stderr, "csv2json: %s: %v\n", name, err)
+	return ExitError
+}
+
+// printViolations lists the first violations of report, then a summary.
+func printViolations(stderr io.Writer, name string, report *validate.Report) {
+	for i, v := range report.Violations {
+		if i == maxPrintedViolations {
+			break
+		}
+		location := name
+		if v.Line > 0 {
+			location = fmt.Sprintf("%s:%d:%d", name, v.Line, v.Column)
+		}
+		fmt.Fprintf(stderr, "csv2json: %s: %s: %s (%s)\n", location, v.Field, v.Message, v.Rule)
+	}
+	fmt.Fprintf(stderr, "csv2json: %s: %d of %d rows invalid, %d violations\n",
+		name, report.InvalidRows, report.Rows, report.Total)
+}
+
+func displayName(name string) string {
+	if name == stdinName {
+		return "<stdin>"
+	}
+	return name
+}
+
+// parseChar accepts a single character, or "tab".
+func parseChar(v string) (rune, error) {
+	if v == "tab" || v == `\t` {
+		return '\t', nil
+	}
+	runes := []rune(v)
+	if len(runes) != 1 {
+		return 0, fmt.Errorf("expected a single character, got %q", v)
+	}
+	return runes[0], nil
+}
+
+// parseTypes parses a list such as "age:integer,active:boolean".
+func parseTypes(v string) (map[string]infer.Type, error) {
+	types := make(map[string]infer.Type)
+	for _, entry := range strings.Split(v, ",") {
+		i := strings.LastIndex(entry, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("expected column:type, got %q", entry)
+		}
+		t, err := infer.ParseType(entry[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		types[entry[:i]] = t
+	}
+	return types, nil
+}
@@ -0,0 +1,206 @@
+package tests
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/cli"
+)
+
+// run executes the command and returns its exit code, stdout and stderr.
+func run(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := cli.Run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// writeFile creates a file in a temporary directory and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun_Stdin(t *testing.T) {
+	code, stdout, stderr := run(t, "name;age\nAlice;30\n", "-infer")
+
+	if code != cli.ExitOK {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr)
+	}
+	if want := "[{\"name\":\"Alice\",\"age\":30}]\n"; stdout != want {
+		t.Errorf("expected %q, got %q", want, stdout)
+	}
+}
+
+func TestRun_FileToOutputFile(t *testing.T) {
+	input := writeFile(t, "people.csv", "id|name\n1|Alice\n")
+	output := filepath.Join(t.TempDir(), "people.json")
+
+	code, stdout, stderr := run(t, "", "-delimiter", "|", "-pretty", "-o", output, input)
+
+	if code != cli.ExitOK {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("expected nothing on stdout, got %q", stdout)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[\n  {\n    \"id\": \"1\",\n    \"name\": \"Alice\"\n  }\n]\n"; string(data) != want {
+		t.Errorf("expected %q, got %q", want, data)
+	}
+}
+
+func TestRun_NDJSONSeveralFiles(t *testing.T) {
+	a := writeFile(t, "a.csv", "x\n1\n")
+	b := writeFile(t, "b.csv", "y\n2\n")
+
+	code, stdout, stderr := run(t, "x\n0\n", "-format", "ndjson", "-", a, b)
+
+	if code != cli.ExitOK {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr)
+	}
+	if want := "{\"x\":\"0\"}\n{\"x\":\"1\"}\n{\"y\":\"2\"}\n"; stdout != want {
+		t.Errorf("expected %q, got %q", want, stdout)
+	}
+}
+
+func TestRun_HeaderOptions(t *testing.T) {
+	code, stdout, _ := run(t, "1,2\n", "-header", "false", "-columns", "a,b", "-arrays")
+
+	if code != cli.ExitOK || stdout != "[[\"1\",\"2\"]]\n" {
+		t.Errorf("unexpected result %d %q", code, stdout)
+	}
+}
+
+func TestRun_DialectOptions(t *testing.T) {
+	input := "# generated\nname , note\n Alice , 5\" tall\n"
+	code, stdout, stderr := run(t, input, "-comment", "#", "-trim", "-lazy-quotes")
+
+	if code != cli.ExitOK {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr)
+	}
+	if want := "[{\"name\":\"Alice\",\"note\":\"5\\\" tall\"}]\n"; stdout != want {
+		t.Errorf("expected %q, got %q", want, stdout)
+	}
+}
+
+func TestRun_ParseErrorExitCode(t *testing.T) {
+	input := writeFile(t, "bad.csv", "name,age\nAlice,30\nBob,3\"1\n")
+	output := filepath.Join(filepath.Dir(input), "bad.json")
+
+	code, _, stderr := run(t, "", "-o", output, input)
+
+	if code != cli.ExitInvalidInput {
+		t.Fatalf("expected exit %d, got %d", cli.ExitInvalidInput, code)
+	}
+	if want := input + ":3:6: bare \" in non-quoted-field"; !strings.Contains(stderr, want) {
+		t.Errorf("expected %q in stderr, got %q", want, stderr)
+	}
+	if !strings.Contains(stderr, "    Bob,3\"1") {
+		t.Errorf("expected the snippet in stderr, got %q", stderr)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("expected no output file, got %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(input)); len(entries) != 1 {
+		t.Errorf("expected temporary files to be removed, found %d entries", len(entries))
+	}
+}
+
+func TestRun_ValidationExitCode(t *testing.T) {
+	spec := writeFile(t, "rules.json", `{"columns": {"age": {"max": 120}}}`)
+
+	code, _, stderr := run(t, "name,age\nAlice,130\n", "-validate", spec)
+	if code != cli.ExitValidation {
+		t.Fatalf("expected exit %d, got %d", cli.ExitValidation, code)
+	}
+	if !strings.Contains(stderr, "<stdin>:2:7: age: 130 is greater than 120 (max)") {
+		t.Errorf("unexpected stderr %q", stderr)
+	}
+
+	code, stdout, stderr := run(t, "name,age\nAlice,130\n", "-validate", spec, "-validation-mode", "report")
+	if code != cli.ExitOK || !strings.Contains(stdout, "Alice") || !strings.Contains(stderr, "1 of 1 rows invalid") {
+		t.Errorf("unexpected report mode result %d %q %q", code, stdout, stderr)
+	}
+}
+
+func TestRun_UsageErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"-format", "xml"},
+		{"-delimiter", "ab"},
+		{"-types", "age"},
+		{"-pretty", "-format", "ndjson"},
+		{"-unflatten", "-arrays"},
+		{"a.csv", "b.csv"},
+		{"-no-such-flag"},
+	} {
+		if code, _, _ := run(t, "", args...); code != cli.ExitUsage {
+			t.Errorf("%v: expected exit %d, got %d", args, cli.ExitUsage, code)
+		}
+	}
+}
+
+func TestRun_MissingFile(t *testing.T) {
+	code, _, stderr := run(t, "", filepath.Join(t.TempDir(), "missing.csv"))
+
+	if code != cli.ExitError {
+		t.Errorf("expected exit %d, got %d", cli.ExitError, code)
+	}
+	if !strings.Contains(stderr, "missing.csv") {
+		t.Errorf("expected the file name in stderr, got %q", stderr)
+	}
+}
+
+func TestRun_Batch(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.csv": "x\n1\n2\n",
+		"b.csv": "x\n3\n",
+		"c.csv": "x\n\"4\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(t.TempDir(), "json")
+
+	code, stdout, stderr := run(t, "", "-batch", "-workers", "2", "-out-dir", out, dir)
+
+	if code != cli.ExitInvalidInput {
+		t.Errorf("expected exit %d, got %d", cli.ExitInvalidInput, code)
+	}
+	if !strings.Contains(stdout, "3 files: 2 converted, 1 failed, 3 rows") {
+		t.Errorf("expected a summary, got %q", stdout)
+	}
+	if !strings.Contains(stderr, filepath.Join(dir, "c.csv")+":2:") {
+		t.Errorf("expected the located failure, got %q", stderr)
+	}
+	if data, err := os.ReadFile(filepath.Join(out, "b.json")); err != nil || string(data) != `[{"x":"3"}]` {
+		t.Errorf("unexpected b.json %q, %v", data, err)
+	}
+}
+
+func TestRun_BatchUsageErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"-batch"},
+		{"-batch", "-o", "out.json", "a.csv"},
+		{"-batch", "-workers", "0", "a.csv"},
+		{"-out-dir", "out", "a.csv"},
+	} {
+		if code, _, _ := run(t, "", args...); code != cli.ExitUsage {
+			t.Errorf("%v: expected exit %d, got %d", args, cli.ExitUsage, code)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+	"github.com/agileproject-gurpreet/csv2json/internal/infer"
+	"github.com/agileproject-gurpreet/csv2json/internal/parser"
+	"github.com/agileproject-gurpreet/csv2json/internal/schema"
+	"github.com/agileproject-gurpreet/csv2json/internal/validate"
+)
+
+// DefaultInferRows is the number of rows scanned for type inference.
+const DefaultInferRows = 1000
+
+// Options controls a CSV to JSON conversion.
+type Options struct {
+	Parser parser.Options
+	// InferTypes emits values as native JSON types inferred per column.
+	// Inference looks at the first InferRows rows; later values that do not
+	// fit the inferred type are emitted as strings.
+	InferTypes bool
+	// InferRows is the number of rows scanned for inference. Zero means
+	// DefaultInferRows.
+	InferRows int
+	// Arrays writes each record as a JSON array of its values in column
+	// order instead of an object. Surplus fields under parser.RaggedExtra
+	// are appended to the array.
+	Arrays bool
+	// ColumnTypes fixes the type of the named columns, by their normalised
+	// names. Values that cannot be converted are an error.
+	ColumnTypes map[string]infer.Type
+	// Unflatten nests values under their header paths, so "address.city"
+	// and "tags[0]" become {"address":{"city":...},"tags":[...]}. Headers
+	// that conflict, such as "a" and "a.b", are an error.
+	Unflatten bool
+	// Separator splits header paths when unflattening. Empty means
+	// encoder.DefaultSeparator.
+	Separator string
+	// Format is the output format. Empty means encoder.FormatJSON.
+	Format encoder.Format
+	// Indent pretty-prints JSON output, indenting each level by Indent. It
+	// cannot be combined with NDJSON, which has one record per line.
+	Indent string
+	// Schema infers a JSON Schema of the output, returned in Result.Schema.
+	Schema bool
+	// Validation checks every record against a spec; the violations are
+	// returned in Result.Validation. Column rules see the raw values, a
+	// JSON Schema the values written.
+	Validation *validate.Spec
+	// ValidationMode decides whether violations fail the conversion, with a
+	// *validate.Error once all records are written. Empty means
+	// validate.ModeReject.
+	ValidationMode validate.Mode
+}
+
+// Validate reports options that cannot be used, as Convert would before
+// reading any input.
+func (o Options) Validate() error {
+	if err := o.Parser.Validate(); err != nil {
+		return err
+	}
+	if o.InferRows < 0 {
+		return fmt.Errorf("invalid infer rows %d", o.InferRows)
+	}
+	switch o.Format {
+	case "", encoder.FormatJSON, encoder.FormatNDJSON:
+	default:
+		return fmt.Errorf("unknown format %q", o.Format)
+	}
+	if o.Unflatten && o.Arrays {
+		return fmt.Errorf("unflatten cannot be combined with arrays")
+	}
+	if o.Indent != "" && o.Format == encoder.FormatNDJSON {
+		return fmt.Errorf("indent cannot be combined with NDJSON")
+	}
+	if strings.Trim(o.Indent, " \t") != "" {
+		return fmt.Errorf("indent must be spaces or tabs")
+	}
+	switch o.ValidationMode {
+	case "", validate.ModeReject, validate.ModeReport:
+	default:
+		return fmt.Errorf("unknown validation mode %q", o.ValidationMode)
+	}
+	for column, t := range o.ColumnTypes {
+		if parsed, err := infer.ParseType(string(t)); err != nil || parsed != t {
+			return fmt.Errorf("column %q: unknown type %q", column, t)
+		}
+	}
+	return nil
+}
+
+// Result describes a completed conversion.
+type Result struct {
+	// Encoding is the encoding the input was decoded from.
+	Encoding string
+	// Dialect is the dialect the input was read with, detected or overridden.
+	Dialect parser.Dialect
+	// Rows is the number of records written.
+	Rows int
+	// Types is the JSON type of each column when typing was requested.
+	Types map[string]infer.Type
+	// Columns lists the top-level keys of the output objects in the order
+	// written.
+	Columns []string
+	// Pointers lists the JSON Pointers of the members of nested objects in
+	// the order written, when Unflatten is set; see encoder.Nester.Pointers.
+	Pointers []string
+	// Headers maps each input column to its key in the output.
+	Headers []parser.Header
+	// Ragged is the policy applied to rows whose width differs from the header.
+	Ragged parser.RaggedPolicy
+	// RaggedLines lists the line numbers of the rows the policy padded,
+	// extended or skipped.
+	RaggedLines []int
+	// Schema describes the JSON written, when Options.Schema is set.
+	Schema *schema.Schema
+	// Validation lists the violations found, when Options.Validation is set.
+	Validation *validate.Report
+}
+
+// Convert reads CSV from r and streams it to w as a JSON array, or NDJSON,
+// one record at a time. On error, w may hold partial output; a
+// *validate.Error follows complete output.
+func Convert(r io.Reader, w io.Writer, opts Options) (*Result, error) {
+	return ConvertContext(context.Background(), r, w, opts)
+}
+
+// ConvertContext is Convert with a context. Once ctx is done it stops
+// before the next record and returns an error wrapping ctx.Err().
+func ConvertContext(ctx context.Context, r io.Reader, w io.Writer, opts Options) (*Result, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	reader := parser.NewReaderContext(ctx, r, opts.Parser)
+	headers, err := reader.Headers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV: %w", err)
+	}
+
+	var nester *encoder.Nester
+	if opts.Unflatten {
+		nester, err = encoder.NewNester(headers, opts.Separator)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert CSV: %w", err)
+		}
+	}
+
+	// Rows read ahead for inference are held here until the types are known
+	var sample []bufferedRow
+	var types map[string]infer.Type
+	if opts.InferTypes || len(opts.ColumnTypes) > 0 {
+		limit := opts.InferRows
+		if limit == 0 {
+			limit = DefaultInferRows
+		}
+		if opts.InferTypes {
+			for len(sample) < limit && reader.Next() {
+				sample = append(sample, bufferRow(reader))
+			}
+			if err := reader.Err(); err != nil {
+				return nil, fmt.Errorf("failed to parse CSV: %w", err)
+			}
+		}
+		types = columnTypes(headers, sample, opts)
+	}
+
+	out, err := encoder.NewWriter(w, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	if array, ok := out.(*encoder.ArrayWriter); ok {
+		array.Indent = opts.Indent
+	}
+	var inferrer *schema.Inferrer
+	if opts.Schema {
+		inferrer = schema.NewInferrer(headers)
+	}
+	var validator *validate.Validator
+	if opts.Validation != nil {
+		validator = validate.NewValidator(opts.Validation, headers)
+	}
+
+	rows, hasExtra := 0, false
+	write := func(record parser.Record, extra []string, position func(int) (int, int)) error {
+		rows++
+		object, err := convertRecord(record, types, opts.ColumnTypes, position)
+		if err != nil {
+			return fmt.Errorf("failed to convert CSV: row %d: %w", rows, err)
+		}
+		values := make([]interface{}, len(object), len(object)+len(extra))
+		for i, m := range object {
+			values[i] = m.Value
+		}
+		if inferrer != nil {
+			inferrer.Add(values)
+		}
+		if extra != nil {
+			hasExtra = true
+		}
+
+		var v interface{}
+		if opts.Arrays {
+			for _, e := range extra {
+				values = append(values, e)
+			}
+			v = values
+		} else {
+			if nester != nil {
+				object = nester.Nest(values)
+			}
+			if extra != nil {
+				object = append(object, encoder.Member{Key: parser.ExtraField, Value: extra})
+			}
+			v = object
+		}
+		if validator != nil {
+			validator.Check(rows, record, v, position)
+		}
+		if err := out.Write(v); err != nil {
+			return fmt.Errorf("failed to write JSON: %w", err)
+		}
+		return nil
+	}
+
+	for _, r := range sample {
+		if err := write(r.record, r.extra, r.position); err != nil {
+			return nil, err
+		}
+	}
+	for reader.Next() {
+		if err := write(reader.Record(), reader.Extra(), reader.Position); err != nil {
+			return nil, err
+		}
+	}
+	if err := reader.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse CSV: %w", err)
+	}
+
+	if err := out.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write JSON: %w", err)
+	}
+
+	columns := headers
+	var pointers []string
+	if nester != nil {
+		columns, pointers = nester.Keys(), nester.Pointers()
+	}
+	if hasExtra {
+		columns = append(append([]string(nil), columns...), parser.ExtraField)
+	}
+
+	var inferred *schema.Schema
+	if inferrer != nil {
+		inferred = outputSchema(inferrer, nester, opts.Arrays, hasExtra)
+	}
+
+	var report *validate.Report
+	if validator != nil {
+		report = validator.Report()
+		if !report.Valid() && opts.ValidationMode != validate.ModeReport {
+			return nil, &validate.Error{Report: report}
+		}
+	}
+
+	return &Result{
+		Validation:  report,
+		Schema:      inferred,
+		Encoding:    reader.Encoding(),
+		Dialect:     reader.Dialect(),
+		Rows:        out.Count(),
+		Types:       types,
+		Columns:     columns,
+		Pointers:    pointers,
+		Headers:     reader.HeaderMapping(),
+		Ragged:      opts.Parser.Ragged,
+		RaggedLines: reader.RaggedLines(),
+	}, nil
+}
+
+// outputSchema completes the inferred schema for the shape of the output:
+// rows as arrays, and surplus fields under RaggedExtra.
+func outputSchema(inferrer *schema.Inferrer, nester *encoder.Nester, arrays, hasExtra bool) *schema.Schema {
+	if arrays {
+		item := &schema.Schema{Type: "array", PrefixItems: inferrer.Properties()}
+		if hasExtra {
+			item.Items = &schema.Schema{Type: "string"}
+		}
+		return &schema.Schema{Schema: schema.Draft, Type: "array", Items: item}
+	}
+
+	s := inferrer.Schema(nester)
+	if hasExtra {
+		// Only rows with surplus fields have the member
+		extra := &schema.Schema{Type: "array", Items: &schema.Schema{Type: "string"}}
+		s.Items.Properties = append(s.Items.Properties, encoder.Member{Key: parser.ExtraField, Value: extra})
+	}
+	return s
+}
+
+// bufferedRow is a row read ahead for type inference, with the position
+// of each field for error reporting.
+type bufferedRow struct {
+	record    parser.Record
+	extra     []string
+	positions [][2]int
+}
+
+func bufferRow(reader *parser.Reader) bufferedRow {
+	row := bufferedRow{record: reader.Record(), extra: reader.Extra()}
+	row.positions = make([][2]int, len(row.record))
+	for i := range row.record {
+		row.positions[i][0], row.positions[i][1] = reader.Position(i)
+	}
+	return row
+}
+
+func (r bufferedRow) position(i int) (int, int) {
+	return r.positions[i][0], r.positions[i][1]
+}
+
+// columnTypes decides the type of every column: overrides first, then the
+// type inferred from sample, or String when inference is off.
+func columnTypes(headers []string, sample []bufferedRow, opts Options) map[string]infer.Type {
+	types := make(map[string]infer.Type, len(headers))
+	for i, header := range headers {
+		if t, ok := opts.ColumnTypes[header]; ok {
+			types[header] = t
+			continue
+		}
+		if !opts.InferTypes {
+			types[header] = infer.String
+			continue
+		}
+
+		t := infer.Null
+		for _, r := range sample {
+			t = infer.Merge(t, infer.Detect(r.record[i].Value))
+		}
+		types[header] = t
+	}
+	return types
+}
+
+// convertRecord builds the output object for record, converting each value
+// to its column type when types is set. Missing fields are null. Values
+// that do not fit an inferred type are kept as strings; overridden columns
+// must fit, or a *parser.ParseError at the field's position is returned.
+func convertRecord(record parser.Record, types, overrides map[string]infer.Type, position func(int) (int, int)) (encoder.Object, error) {
+	object := make(encoder.Object, len(record))
+	for i, f := range record {
+		var v interface{} = f.Value
+		if f.Null {
+			v = nil
+		} else if types != nil {
+			typed, err := infer.Convert(f.Value, types[f.Name])
+			if err == nil {
+				v = typed
+			} else if _, fixed := overrides[f.Name]; fixed {
+				line, column := position(i)
+				return nil, &parser.ParseError{
+					Line:    line,
+					Column:  column,
+					Snippet: f.Value,
+					Reason:  fmt.Sprintf("column %q: %v", f.Name, err),
+					Err:     err,
+				}
+			}
+		}
+		object[i] = encoder.Member{Key: f.Name, Value: v}
+	}
+	return object, nil
+}
@@ -0,0 +1,242 @@
+package converter
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+)
+
+// CSVOptions controls a JSON to CSV conversion.
+type CSVOptions struct {
+	// Delimiter is the field delimiter. Zero means ','.
+	Delimiter rune
+	// Quote is the quote character. Zero means '"'.
+	Quote rune
+	// QuoteAll quotes every field, not only those that need it.
+	QuoteAll bool
+	// Separator joins the keys of nested objects into header names. Empty
+	// means encoder.DefaultSeparator.
+	Separator string
+	// Columns fixes the header row and its order; other keys are dropped.
+	// Without it the header is the union of all keys in order of first
+	// appearance, so every record is read before anything is written.
+	Columns []string
+}
+
+// Validate reports options that cannot be used, as ConvertJSON would
+// before reading any input.
+func (o CSVOptions) Validate() error {
+	for _, c := range []struct {
+		name string
+		r    rune
+	}{{"delimiter", o.Delimiter}, {"quote", o.Quote}} {
+		if c.r == '\r' || c.r == '\n' || c.r < 0 || c.r == utf8.RuneError {
+			return fmt.Errorf("invalid %s %q", c.name, c.r)
+		}
+	}
+	delimiter, quote := o.Delimiter, o.Quote
+	if delimiter == 0 {
+		delimiter = ','
+	}
+	if quote == 0 {
+		quote = '"'
+	}
+	if delimiter == quote {
+		return fmt.Errorf("delimiter and quote must differ")
+	}
+	if o.Columns != nil && len(o.Columns) == 0 {
+		return fmt.Errorf("columns must not be empty")
+	}
+	return nil
+}
+
+// CSVResult describes a completed JSON to CSV conversion.
+type CSVResult struct {
+	// Rows is the number of records converted, excluding the header row.
+	Rows int
+	// Columns is the header row.
+	Columns []string
+}
+
+// ConvertJSON reads a JSON array of objects, or NDJSON with one object per
+// line, from r and writes it to w as CSV with a header row. Nested objects
+// and arrays are flattened into headers such as "address.city" and
+// "tags[0]"; nulls and missing keys are empty fields.
+func ConvertJSON(r io.Reader, w io.Writer, opts CSVOptions) (*CSVResult, error) {
+	return ConvertJSONContext(context.Background(), r, w, opts)
+}
+
+// ConvertJSONContext is ConvertJSON with a context. Once ctx is done it
+// stops before the next record and returns an error wrapping ctx.Err().
+func ConvertJSONContext(ctx context.Context, r io.Reader, w io.Writer, opts CSVOptions) (*CSVResult, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	next, err := jsonRecords(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	out := encoder.NewCSVWriter(w, opts.Delimiter, opts.Quote, opts.QuoteAll)
+	write := func(columns []string, record encoder.Object) error {
+		values := make(map[string]string, len(record))
+		for _, m := range record {
+			values[m.Key] = encoder.FormatScalar(m.Value)
+		}
+		row := make([]string, len(columns))
+		for i, c := range columns {
+			row[i] = values[c]
+		}
+		return out.Write(row)
+	}
+
+	result := &CSVResult{Columns: opts.Columns}
+	var buffered []encoder.Object
+	seen := make(map[string]bool)
+	if opts.Columns != nil {
+		// The header is known, so records can be written as they are read
+		if err := out.Write(opts.Columns); err != nil {
+			return nil, fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("failed to convert JSON: %w", err)
+		}
+		record, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: record %d: %w", result.Rows+len(buffered)+1, err)
+		}
+
+		flat := encoder.Flatten(record, opts.Separator)
+		if opts.Columns != nil {
+			if err := write(opts.Columns, flat); err != nil {
+				return nil, fmt.Errorf("failed to write CSV: %w", err)
+			}
+			result.Rows++
+			continue
+		}
+
+		for _, m := range flat {
+			if !seen[m.Key] {
+				seen[m.Key] = true
+				result.Columns = append(result.Columns, m.Key)
+			}
+		}
+		buffered = append(buffered, flat)
+	}
+
+	// Records without any scalar values give no columns and no output
+	if opts.Columns == nil && len(result.Columns) == 0 {
+		result.Rows = len(buffered)
+	} else if opts.Columns == nil {
+		if err := out.Write(result.Columns); err != nil {
+			return nil, fmt.Errorf("failed to write CSV: %w", err)
+		}
+		for _, record := range buffered {
+			if err := write(result.Columns, record); err != nil {
+				return nil, fmt.Errorf("failed to write CSV: %w", err)
+			}
+			result.Rows++
+		}
+	}
+
+	if err := out.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return result, nil
+}
+
+// jsonRecords returns a function yielding the objects of a JSON array, or
+// of a sequence of JSON values such as NDJSON, then io.EOF.
+func jsonRecords(r io.Reader) (func() (encoder.Object, error), error) {
+	br := bufio.NewReader(r)
+	array, err := startsWithArray(br)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(br)
+	dec.UseNumber()
+	if array {
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+
+	done := false
+	return func() (encoder.Object, error) {
+		if done {
+			return nil, io.EOF
+		}
+		if array && !dec.More() {
+			done = true
+			if _, err := dec.Token(); err == io.EOF {
+				return nil, io.ErrUnexpectedEOF
+			} else if err != nil {
+				return nil, err
+			}
+			if _, err := dec.Token(); err != io.EOF {
+				return nil, fmt.Errorf("unexpected data after JSON array")
+			}
+			return nil, io.EOF
+		}
+
+		v, err := encoder.DecodeOrdered(dec)
+		if err != nil {
+			if err == io.EOF {
+				done = true
+			}
+			return nil, err
+		}
+		object, ok := v.(encoder.Object)
+		if !ok {
+			return nil, fmt.Errorf("expected an object, got %s", jsonKind(v))
+		}
+		return object, nil
+	}, nil
+}
+
+// startsWithArray reports whether the first non-blank byte of br is '['.
+func startsWithArray(br *bufio.Reader) (bool, error) {
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b == '[', br.UnreadByte()
+	}
+}
+
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,341 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// migrationFiles holds the schema of each database, one directory per
+// backend, as numbered migrations: NNNN_name.up.sql applies a version and
+// the optional NNNN_name.down.sql reverts it.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationLockKey is the Postgres advisory lock held while migrating, so
+// that servers starting together do not apply the same migration twice.
+const migrationLockKey = 7_223_646_184_052
+
+// Migration is one version of the schema.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	// Down reverts Up; empty if the migration cannot be reverted.
+	Down string
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies migrations to a database in version order, recording
+// each in the schema_migrations table. Every migration runs in its own
+// transaction together with its record, and is skipped if already applied.
+type Migrator struct {
+	db         *sql.DB
+	dialect    dialect
+	migrations []Migration
+	// lock holds off other migrators while conn is in use, returning the
+	// function that releases it.
+	lock func(ctx context.Context, conn *sql.Conn) (func(), error)
+	err  error
+}
+
+// Migrator returns a Migrator for the embedded Postgres migrations. It
+// holds an advisory lock while it runs.
+func (p *PostgresDB) Migrator() *Migrator {
+	return p.MigratorFrom(embeddedMigrations("postgres"))
+}
+
+// MigratorFrom returns a Migrator for the migrations in the root of fsys.
+func (p *PostgresDB) MigratorFrom(fsys fs.FS) *Migrator {
+	return newMigrator(p.DB, postgres, fsys, advisoryLock)
+}
+
+// Migrator returns a Migrator for the embedded SQLite migrations. SQLite
+// allows one writer at a time, so concurrent migrators wait for each other
+// without a separate lock.
+func (s *SQLiteDB) Migrator() *Migrator {
+	return s.MigratorFrom(embeddedMigrations("sqlite"))
+}
+
+// MigratorFrom returns a Migrator for the migrations in the root of fsys.
+func (s *SQLiteDB) MigratorFrom(fsys fs.FS) *Migrator {
+	return newMigrator(s.DB, sqlite, fsys, noLock)
+}
+
+func embeddedMigrations(backend string) fs.FS {
+	fsys, err := fs.Sub(migrationFiles, "migrations/"+backend)
+	if err != nil {
+		panic(err)
+	}
+	return fsys
+}
+
+// newMigrator loads the migrations of fsys. An invalid set of migrations is
+// returned by the first method called.
+func newMigrator(db *sql.DB, d dialect, fsys fs.FS, lock func(context.Context, *sql.Conn) (func(), error)) *Migrator {
+	m := &Migrator{db: db, dialect: d, lock: lock}
+	m.migrations, m.err = loadMigrations(fsys)
+	return m
+}
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// loadMigrations reads the NNNN_name.up.sql and NNNN_name.down.sql files in
+// the root of fsys, sorted by version.
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: name is not NNNN_name.up.sql or NNNN_name.down.sql", entry.Name())
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("migration %s: version must be a positive number", entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %s: version %d is also named %s", entry.Name(), version, m.Name)
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s: no up migration", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrations returns the migrations known to m, in version order.
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Up applies every migration not yet applied, in version order.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.run(ctx, func(conn *sql.Conn) error {
+		for _, mig := range m.migrations {
+			if err := m.apply(ctx, conn, mig, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down reverts the last steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.run(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		known := make(map[int]Migration, len(m.migrations))
+		for _, mig := range m.migrations {
+			known[mig.Version] = mig
+		}
+		for _, v := range versions[:min(steps, len(versions))] {
+			mig, ok := known[v]
+			if !ok {
+				return fmt.Errorf("cannot revert version %d: migration is not known to this build", v)
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("cannot revert migration %04d_%s: no down migration", mig.Version, mig.Name)
+			}
+			if err := m.apply(ctx, conn, mig, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Version returns the highest applied version, or 0 for none.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	version := 0
+	err := m.run(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		for v := range applied {
+			version = max(version, v)
+		}
+		return err
+	})
+	return version, err
+}
+
+// Status reports each known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.run(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			appliedAt, ok := applied[mig.Version]
+			statuses = append(statuses, MigrationStatus{Migration: mig, Applied: ok, AppliedAt: appliedAt})
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// run calls fn with a connection holding the migration lock, once the
+// schema_migrations table exists.
+func (m *Migrator) run(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	unlock, err := m.lock(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	defer unlock()
+
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// applied returns when each applied version was applied.
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// apply runs the up or down SQL of mig and records it, unless another
+// migrator got there first.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration, up bool) error {
+	direction, script := "apply", mig.Up
+	if !up {
+		direction, script = "revert", mig.Down
+	}
+	fail := func(err error) error {
+		return fmt.Errorf("failed to %s migration %04d_%s: %w", direction, mig.Version, mig.Name, err)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback()
+
+	p := m.dialect.placeholder
+	var count int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations WHERE version = "+p(1), mig.Version).Scan(&count); err != nil {
+		return fail(err)
+	}
+	if (count > 0) == up {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fail(err)
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name) VALUES ("+p(1)+", "+p(2)+")", mig.Version, mig.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = "+p(1), mig.Version)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// advisoryLock takes the Postgres migration lock for the session of conn,
+// waiting for other migrators to finish.
+func advisoryLock(ctx context.Context, conn *sql.Conn) (func(), error) {
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return nil, err
+	}
+	return func() {
+		conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+	}, nil
+}
+
+// noLock is the lock of databases that serialise migrators themselves.
+func noLock(context.Context, *sql.Conn) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,19 @@
+-- The baseline schema. Databases set up by InitSchema before migrations
+-- already have it, so every statement is a no-op there and they are
+-- adopted at version 1 without changes.
+CREATE TABLE IF NOT EXISTS csv_data (
+    id SERIAL PRIMARY KEY,
+    filename VARCHAR(255),
+    data JSONB NOT NULL,
+    columns JSONB,
+    schema JSONB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Tables from before columns and schema were added gain them here.
+-- JSONB does not keep object key order; columns records the header order
+ALTER TABLE csv_data ADD COLUMN IF NOT EXISTS columns JSONB;
+ALTER TABLE csv_data ADD COLUMN IF NOT EXISTS schema JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_csv_data_created_at ON csv_data(created_at);
+CREATE INDEX IF NOT EXISTS idx_csv_data_filename ON csv_data(filename);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_csv_data_filename_id;
+DROP INDEX IF EXISTS idx_csv_data_created_at_id;
@@ -0,0 +1,4 @@
+-- Paged listings order by a key and then id, and continue from a cursor on
+-- both; these indexes serve them without sorting the table.
+CREATE INDEX IF NOT EXISTS idx_csv_data_created_at_id ON csv_data(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_csv_data_filename_id ON csv_data((COALESCE(filename, '') COLLATE "C"), id);
@@ -0,0 +1,12 @@
+-- Each record of an upload stored with its rows, so that one record can be
+-- read, or searched for, without loading the whole upload. Rows go with
+-- their upload when it is deleted.
+CREATE TABLE IF NOT EXISTS csv_rows (
+    upload_id INTEGER NOT NULL REFERENCES csv_data(id) ON DELETE CASCADE,
+    row_number INTEGER NOT NULL,
+    data JSONB NOT NULL,
+    PRIMARY KEY (upload_id, row_number)
+);
+
+-- Serves searches on row contents, e.g. data @> '{"city": "Oslo"}'
+CREATE INDEX IF NOT EXISTS idx_csv_rows_data ON csv_rows USING GIN (data);
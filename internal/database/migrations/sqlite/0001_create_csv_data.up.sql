@@ -0,0 +1,15 @@
+-- The baseline schema, as InitSchema created it before migrations, so
+-- existing files are adopted at version 1 without changes. JSON is stored
+-- as text and created_at as fixed-width UTC text, so text order is time
+-- order.
+CREATE TABLE IF NOT EXISTS csv_data (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    filename TEXT,
+    data TEXT NOT NULL CHECK (json_valid(data)),
+    columns TEXT CHECK (columns IS NULL OR json_valid(columns)),
+    schema TEXT CHECK (schema IS NULL OR json_valid(schema)),
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_csv_data_created_at ON csv_data(created_at);
+CREATE INDEX IF NOT EXISTS idx_csv_data_filename ON csv_data(filename);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_csv_data_filename_id;
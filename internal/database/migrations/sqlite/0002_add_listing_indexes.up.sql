@@ -0,0 +1,5 @@
+-- Paged listings order by a key and then id, and continue from a cursor on
+-- both. id is the rowid, which every SQLite index already ends with, so
+-- idx_csv_data_created_at serves the created_at order as it is; the
+-- filename order needs an index on the expression it sorts by.
+CREATE INDEX IF NOT EXISTS idx_csv_data_filename_id ON csv_data(COALESCE(filename, ''), id);
@@ -0,0 +1,10 @@
+-- Each record of an upload stored with its rows, so that one record can be
+-- read without loading the whole upload. Rows go with their upload when it
+-- is deleted. SQLite has no GIN index; rows are found by their primary key,
+-- which a table without rowid keeps them ordered by.
+CREATE TABLE IF NOT EXISTS csv_rows (
+    upload_id INTEGER NOT NULL REFERENCES csv_data(id) ON DELETE CASCADE,
+    row_number INTEGER NOT NULL,
+    data TEXT NOT NULL CHECK (json_valid(data)),
+    PRIMARY KEY (upload_id, row_number)
+) WITHOUT ROWID;
@@ -1,14 +1,19 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"os"
 	"time"
 
+	"github.com/agileproject-gurpreet/csv2json/internal/storage"
 	_ "github.com/lib/pq"
 )
 
+// PostgresDB stores uploads in the csv_data table, and the rows of those
+// inserted with rows in csv_rows. It implements storage.Store.
 type PostgresDB struct {
 	DB *sql.DB
 }
@@ -23,6 +28,29 @@ type Config struct {
 	SSLMode  string
 }
 
+var _ storage.Store = (*PostgresDB)(nil)
+
+// ConfigFromEnv reads the configuration from DB_HOST, DB_PORT, DB_USER,
+// DB_PASSWORD, DB_NAME and DB_SSLMODE, defaulting to a local csv2json
+// database
+func ConfigFromEnv() Config {
+	getEnv := func(key, defaultValue string) string {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+		return defaultValue
+	}
+
+	return Config{
+		Host:     getEnv("DB_HOST", "localhost"),
+		Port:     getEnv("DB_PORT", "5432"),
+		User:     getEnv("DB_USER", "postgres"),
+		Password: getEnv("DB_PASSWORD", "postgres"),
+		DBName:   getEnv("DB_NAME", "csv2json"),
+		SSLMode:  getEnv("DB_SSLMODE", "disable"),
+	}
+}
+
 // NewPostgresDB creates a new PostgreSQL database connection
 func NewPostgresDB(config Config) (*PostgresDB, error) {
 	connStr := fmt.Sprintf(
@@ -48,123 +76,166 @@ func NewPostgresDB(config Config) (*PostgresDB, error) {
 	return &PostgresDB{DB: db}, nil
 }
 
-// InitSchema creates the necessary tables if they don't exist
+// InitSchema brings the schema up to date by applying pending migrations
 func (p *PostgresDB) InitSchema() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS csv_data (
-		id SERIAL PRIMARY KEY,
-		filename VARCHAR(255),
-		data JSONB NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	);
-	
-	CREATE INDEX IF NOT EXISTS idx_csv_data_created_at ON csv_data(created_at);
-	CREATE INDEX IF NOT EXISTS idx_csv_data_filename ON csv_data(filename);
-	`
+	return p.InitSchemaContext(context.Background())
+}
 
-	_, err := p.DB.Exec(query)
-	if err != nil {
-		return fmt.Errorf("failed to create schema: %w", err)
+// InitSchemaContext is InitSchema with a context
+func (p *PostgresDB) InitSchemaContext(ctx context.Context) error {
+	if err := p.Migrator().Up(ctx); err != nil {
+		return fmt.Errorf("failed to migrate schema: %w", err)
 	}
-
 	return nil
 }
 
 // InsertCSVData inserts CSV data (as JSON) into the database
 func (p *PostgresDB) InsertCSVData(filename string, records []map[string]string) error {
+	return p.InsertCSVDataContext(context.Background(), filename, records)
+}
+
+// InsertCSVDataContext is InsertCSVData with a context
+func (p *PostgresDB) InsertCSVDataContext(ctx context.Context, filename string, records []map[string]string) error {
 	// Convert records to JSON
 	jsonData, err := json.Marshal(records)
 	if err != nil {
 		return fmt.Errorf("failed to marshal data: %w", err)
 	}
 
-	query := `
-		INSERT INTO csv_data (filename, data)
-		VALUES ($1, $2)
-		RETURNING id
-	`
+	return p.InsertJSONDataContext(ctx, filename, jsonData, nil, nil)
+}
 
-	var id int
-	err = p.DB.QueryRow(query, filename, jsonData).Scan(&id)
-	if err != nil {
-		return fmt.Errorf("failed to insert data: %w", err)
-	}
+// InsertJSONData inserts already converted JSON into the database. columns is
+// the key order of the records in jsonData, restored when they are read back,
+// and schema its inferred JSON Schema; either may be nil.
+func (p *PostgresDB) InsertJSONData(filename string, jsonData []byte, columns []string, schema []byte) error {
+	return p.InsertJSONDataContext(context.Background(), filename, jsonData, columns, schema)
+}
 
-	return nil
+// InsertJSONDataContext is InsertJSONData with a context; the insert is
+// abandoned if ctx is done first
+func (p *PostgresDB) InsertJSONDataContext(ctx context.Context, filename string, jsonData []byte, columns []string, schema []byte) error {
+	_, err := p.Insert(ctx, &storage.Upload{Filename: filename, Data: jsonData, Columns: columns, Schema: schema})
+	return err
 }
 
 // GetAllCSVData retrieves all CSV data from the database
 func (p *PostgresDB) GetAllCSVData() ([]map[string]interface{}, error) {
-	query := `
-		SELECT id, filename, data, created_at
-		FROM csv_data
-		ORDER BY created_at DESC
-	`
+	return p.GetAllCSVDataContext(context.Background())
+}
 
-	rows, err := p.DB.Query(query)
+// GetAllCSVDataContext is GetAllCSVData with a context
+func (p *PostgresDB) GetAllCSVDataContext(ctx context.Context) ([]map[string]interface{}, error) {
+	uploads, err := p.List(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query data: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
 
 	var results []map[string]interface{}
-	for rows.Next() {
-		var id int
-		var filename string
-		var data []byte
-		var createdAt time.Time
-
-		if err := rows.Scan(&id, &filename, &data, &createdAt); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
+	for i := range uploads {
+		record, err := uploads[i].Record()
+		if err != nil {
+			return nil, err
 		}
-
-		var jsonData interface{}
-		if err := json.Unmarshal(data, &jsonData); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal data: %w", err)
-		}
-
-		results = append(results, map[string]interface{}{
-			"id":         id,
-			"filename":   filename,
-			"data":       jsonData,
-			"created_at": createdAt,
-		})
+		results = append(results, record)
 	}
-
 	return results, nil
 }
 
 // GetCSVDataByID retrieves CSV data by ID
 func (p *PostgresDB) GetCSVDataByID(id int) (map[string]interface{}, error) {
-	query := `
-		SELECT id, filename, data, created_at
-		FROM csv_data
-		WHERE id = $1
-	`
-
-	var filename string
-	var data []byte
-	var createdAt time.Time
+	return p.GetCSVDataByIDContext(context.Background(), id)
+}
 
-	err := p.DB.QueryRow(query, id).Scan(&id, &filename, &data, &createdAt)
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("record not found")
+// GetCSVDataByIDContext is GetCSVDataByID with a context
+func (p *PostgresDB) GetCSVDataByIDContext(ctx context.Context, id int) (map[string]interface{}, error) {
+	upload, err := p.Get(ctx, id)
+	if err != nil {
+		return nil, err
 	}
+	return upload.Record()
+}
+
+// postgres binds arguments as $1, $2, ... and created_at, which has no
+// time zone, as UTC
+var postgres = dialect{
+	placeholder: func(n int) string { return fmt.Sprintf("$%d", n) },
+	timestamp:   func(t time.Time) interface{} { return t.UTC() },
+	noLimit:     "ALL",
+	binary:      ` COLLATE "C"`,
+}
+
+// Insert stores an upload, implementing storage.Store
+func (p *PostgresDB) Insert(ctx context.Context, u *storage.Upload) (int, error) {
+	return p.insert(ctx, p.DB, u)
+}
+
+// InsertWithRows stores an upload and each of its records, implementing
+// storage.Store
+func (p *PostgresDB) InsertWithRows(ctx context.Context, u *storage.Upload) (int, error) {
+	return insertWithRows(ctx, p.DB, postgres, u, p.insert)
+}
+
+// insert stores an upload with db, which may be a transaction
+func (p *PostgresDB) insert(ctx context.Context, db querier, u *storage.Upload) (int, error) {
+	columnsData, err := marshalColumns(u.Columns)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query data: %w", err)
+		return 0, err
+	}
+
+	var createdAt interface{}
+	if !u.CreatedAt.IsZero() {
+		createdAt = postgres.timestamp(u.CreatedAt)
 	}
 
-	var jsonData interface{}
-	if err := json.Unmarshal(data, &jsonData); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal data: %w", err)
+	query := `
+		INSERT INTO csv_data (filename, data, columns, schema, created_at)
+		VALUES ($1, $2, $3, $4, COALESCE($5::timestamp, CURRENT_TIMESTAMP))
+		RETURNING id, created_at
+	`
+
+	err = db.QueryRowContext(ctx, query, u.Filename, u.Data, columnsData, u.Schema, createdAt).Scan(&u.ID, &u.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert data: %w", err)
 	}
 
-	return map[string]interface{}{
-		"id":         id,
-		"filename":   filename,
-		"data":       jsonData,
-		"created_at": createdAt,
-	}, nil
+	return u.ID, nil
+}
+
+// List returns every stored upload, newest first
+func (p *PostgresDB) List(ctx context.Context) ([]storage.Upload, error) {
+	return p.Query(ctx, storage.Query{})
+}
+
+// Get returns the upload stored under id, or storage.ErrNotFound
+func (p *PostgresDB) Get(ctx context.Context, id int) (*storage.Upload, error) {
+	return getUpload(ctx, p.DB, postgres, id)
+}
+
+// Delete removes the upload stored under id, or returns storage.ErrNotFound
+func (p *PostgresDB) Delete(ctx context.Context, id int) error {
+	return deleteUpload(ctx, p.DB, postgres, id)
+}
+
+// Query returns the uploads selected by q, newest first unless it says
+// otherwise
+func (p *PostgresDB) Query(ctx context.Context, q storage.Query) ([]storage.Upload, error) {
+	return queryUploads(ctx, p.DB, postgres, q)
+}
+
+// Count returns the number of uploads matching the filters of q
+func (p *PostgresDB) Count(ctx context.Context, q storage.Query) (int, error) {
+	return countUploads(ctx, p.DB, postgres, q)
+}
+
+// GetRows returns the rows of the upload stored under id from start
+func (p *PostgresDB) GetRows(ctx context.Context, id, start, limit int) ([]storage.Row, error) {
+	return getRows(ctx, p.DB, postgres, id, start, limit)
+}
+
+// GetRow returns row n of the upload stored under id, or storage.ErrNotFound
+func (p *PostgresDB) GetRow(ctx context.Context, id, n int) (*storage.Row, error) {
+	return getRow(ctx, p.DB, postgres, id, n)
 }
 
 // Close closes the database connection
@@ -0,0 +1,406 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/storage"
+)
+
+// dialect holds what differs between the SQL stores in the queries they
+// share on the csv_data and csv_rows tables.
+type dialect struct {
+	// placeholder returns the marker of the nth query argument, from 1.
+	placeholder func(n int) string
+	// timestamp returns t as bound to the created_at column.
+	timestamp func(t time.Time) interface{}
+	// noLimit is the LIMIT of a query without one.
+	noLimit string
+	// binary is the collation clause comparing text byte by byte.
+	binary string
+}
+
+// selectUploads selects the columns scanUpload reads, and selectMetadata
+// the same without the data payload.
+const (
+	selectUploads  = `SELECT id, filename, data, columns, schema, created_at FROM csv_data`
+	selectMetadata = `SELECT id, filename, NULL, columns, schema, created_at FROM csv_data`
+)
+
+// querier is a *sql.DB or a *sql.Tx, for inserts made alone or with rows.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// rowBatch is the number of rows inserted by one statement. Few statements
+// keep large uploads fast; 1000 rows bind 3000 arguments, well within the
+// limits of both databases.
+const rowBatch = 1000
+
+// insertWithRows stores u with insert, and each of its records in csv_rows,
+// in one transaction. u is only changed once both are stored.
+func insertWithRows(ctx context.Context, db *sql.DB, d dialect, u *storage.Upload, insert func(context.Context, querier, *storage.Upload) (int, error)) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert data: %w", err)
+	}
+	defer tx.Rollback()
+
+	stored := *u
+	if _, err := insert(ctx, tx, &stored); err != nil {
+		return 0, err
+	}
+	if err := insertRows(ctx, tx, d, &stored); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to insert data: %w", err)
+	}
+
+	u.ID, u.CreatedAt = stored.ID, stored.CreatedAt
+	return u.ID, nil
+}
+
+// insertRows stores the records of u in csv_rows, rowBatch at a time.
+func insertRows(ctx context.Context, tx *sql.Tx, d dialect, u *storage.Upload) error {
+	// Every full batch runs the same statement, prepared once
+	var batch *sql.Stmt
+	defer func() {
+		if batch != nil {
+			batch.Close()
+		}
+	}()
+
+	args := make([]interface{}, 0, 3*rowBatch)
+	flush := func() error {
+		var err error
+		if len(args) == cap(args) {
+			if batch == nil {
+				batch, err = tx.PrepareContext(ctx, insertRowsQuery(d, rowBatch))
+			}
+			if err == nil {
+				_, err = batch.ExecContext(ctx, args...)
+			}
+		} else {
+			_, err = tx.ExecContext(ctx, insertRowsQuery(d, len(args)/3), args...)
+		}
+		args = args[:0]
+		if err != nil {
+			return fmt.Errorf("failed to insert rows: %w", err)
+		}
+		return nil
+	}
+
+	err := u.EachRow(func(r storage.Row) error {
+		args = append(args, u.ID, r.Number, string(r.Data))
+		if len(args) == cap(args) {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return flush()
+	}
+	return nil
+}
+
+// insertRowsQuery returns the statement inserting n rows into csv_rows.
+func insertRowsQuery(d dialect, n int) string {
+	var b strings.Builder
+	b.WriteString("INSERT INTO csv_rows (upload_id, row_number, data) VALUES ")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "(%s, %s, %s)", d.placeholder(3*i+1), d.placeholder(3*i+2), d.placeholder(3*i+3))
+	}
+	return b.String()
+}
+
+// getRows returns the rows of the upload stored under id from start, at
+// most limit unless it is 0, or storage.ErrNotFound if there is no upload.
+func getRows(ctx context.Context, db *sql.DB, d dialect, id, start, limit int) ([]storage.Row, error) {
+	// The upload's columns, which also tells whether it exists
+	var columnsData []byte
+	err := db.QueryRowContext(ctx, "SELECT columns FROM csv_data WHERE id = "+d.placeholder(1), id).Scan(&columnsData)
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	var columns []string
+	if columnsData != nil {
+		if err := json.Unmarshal(columnsData, &columns); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal columns: %w", err)
+		}
+	}
+
+	query := "SELECT row_number, data FROM csv_rows WHERE upload_id = " + d.placeholder(1) +
+		" AND row_number >= " + d.placeholder(2) + " ORDER BY row_number"
+	args := []interface{}{id, start}
+	if limit > 0 {
+		query += " LIMIT " + d.placeholder(3)
+		args = append(args, limit)
+	}
+
+	result, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+	defer result.Close()
+
+	rows := []storage.Row{}
+	for result.Next() {
+		r := storage.Row{UploadID: id, Columns: columns}
+		if err := result.Scan(&r.Number, &r.Data); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		rows = append(rows, r)
+	}
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query rows: %w", err)
+	}
+
+	return rows, nil
+}
+
+// getRow returns row n of the upload stored under id, or
+// storage.ErrNotFound.
+func getRow(ctx context.Context, db *sql.DB, d dialect, id, n int) (*storage.Row, error) {
+	if n < 1 {
+		return nil, storage.ErrNotFound
+	}
+	rows, err := getRows(ctx, db, d, id, n, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 || rows[0].Number != n {
+		return nil, storage.ErrNotFound
+	}
+	return &rows[0], nil
+}
+
+// getUpload returns the upload stored under id, or storage.ErrNotFound.
+func getUpload(ctx context.Context, db *sql.DB, d dialect, id int) (*storage.Upload, error) {
+	query := selectUploads + " WHERE id = " + d.placeholder(1)
+
+	upload, err := scanUpload(db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query data: %w", err)
+	}
+
+	return upload, nil
+}
+
+// deleteUpload removes the upload stored under id, or returns
+// storage.ErrNotFound.
+func deleteUpload(ctx context.Context, db *sql.DB, d dialect, id int) error {
+	result, err := db.ExecContext(ctx, "DELETE FROM csv_data WHERE id = "+d.placeholder(1), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete data: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete data: %w", err)
+	}
+	if n == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// queryUploads returns the uploads selected by q, in its order, filtering,
+// sorting and paging in SQL.
+func queryUploads(ctx context.Context, db *sql.DB, d dialect, q storage.Query) ([]storage.Upload, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return d.placeholder(len(args))
+	}
+
+	where := d.filters(q, arg)
+
+	// Keyset pagination: rows after the cursor's (key, id) in the order
+	op, direction := "<", "DESC"
+	if q.Ascending {
+		op, direction = ">", "ASC"
+	}
+	key := d.sortKey(q.Sort)
+	if c := q.After; c != nil {
+		switch c.Sort {
+		case storage.SortID:
+			where = append(where, "id "+op+" "+arg(c.ID))
+		case storage.SortCreatedAt:
+			where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)", key, op, arg(d.timestamp(c.CreatedAt)), arg(c.ID)))
+		case storage.SortFilename:
+			where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)", key, op, arg(c.Filename), arg(c.ID)))
+		}
+	}
+
+	query := selectUploads
+	if q.OmitData {
+		query = selectMetadata
+	}
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	if key != "id" {
+		query += " ORDER BY " + key + " " + direction + ", id " + direction
+	} else {
+		query += " ORDER BY id " + direction
+	}
+	if q.Limit > 0 {
+		query += " LIMIT " + arg(q.Limit)
+	} else if q.Offset > 0 {
+		// SQLite takes OFFSET only after a LIMIT
+		query += " LIMIT " + d.noLimit
+	}
+	if q.Offset > 0 {
+		query += " OFFSET " + arg(q.Offset)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query data: %w", err)
+	}
+	defer rows.Close()
+
+	var uploads []storage.Upload
+	for rows.Next() {
+		upload, err := scanUpload(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		uploads = append(uploads, *upload)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rows: %w", err)
+	}
+
+	return uploads, nil
+}
+
+// countUploads returns the number of uploads matching the filters of q.
+func countUploads(ctx context.Context, db *sql.DB, d dialect, q storage.Query) (int, error) {
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return d.placeholder(len(args))
+	}
+
+	query := `SELECT COUNT(*) FROM csv_data`
+	if where := d.filters(q, arg); len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var count int
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count data: %w", err)
+	}
+	return count, nil
+}
+
+// filters returns the conditions selecting the uploads that match the
+// filters of q, binding their values with arg.
+func (d dialect) filters(q storage.Query, arg func(interface{}) string) []string {
+	var where []string
+	if q.Filename != "" {
+		where = append(where, "filename = "+arg(q.Filename))
+	}
+	if !q.CreatedAfter.IsZero() {
+		where = append(where, "created_at >= "+arg(d.timestamp(q.CreatedAfter)))
+	}
+	if !q.CreatedBefore.IsZero() {
+		where = append(where, "created_at < "+arg(d.timestamp(q.CreatedBefore)))
+	}
+	return where
+}
+
+// sortKey returns the expression uploads are ordered by for s. Filenames
+// compare byte by byte, as storage.Query.Less does, with NULL as empty.
+func (d dialect) sortKey(s storage.Sort) string {
+	switch s {
+	case storage.SortID:
+		return "id"
+	case storage.SortFilename:
+		return "COALESCE(filename, '')" + d.binary
+	}
+	return "created_at"
+}
+
+// scanUpload reads a row of the columns in selectUploads.
+func scanUpload(row interface{ Scan(...interface{}) error }) (*storage.Upload, error) {
+	var u storage.Upload
+	var filename sql.NullString
+	var columns []byte
+
+	if err := row.Scan(&u.ID, &filename, &u.Data, &columns, &u.Schema, timestamp{&u.CreatedAt}); err != nil {
+		return nil, err
+	}
+	u.Filename = filename.String
+	if columns != nil {
+		if err := json.Unmarshal(columns, &u.Columns); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal columns: %w", err)
+		}
+	}
+
+	return &u, nil
+}
+
+// marshalColumns encodes the key order of an upload, keeping nil as NULL.
+func marshalColumns(columns []string) ([]byte, error) {
+	if columns == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(columns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal columns: %w", err)
+	}
+	return data, nil
+}
+
+// timestamp scans created_at, which Postgres returns as a time and SQLite
+// as text in timeFormat.
+type timestamp struct {
+	t *time.Time
+}
+
+func (ts timestamp) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case time.Time:
+		*ts.t = v
+		return nil
+	case string:
+		return ts.parse(v)
+	case []byte:
+		return ts.parse(string(v))
+	}
+	return fmt.Errorf("cannot scan %T into a timestamp", src)
+}
+
+func (ts timestamp) parse(s string) error {
+	t, err := time.Parse(timeFormat, s)
+	if err != nil {
+		return err
+	}
+	*ts.t = t
+	return nil
+}
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/storage"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLiteDB stores uploads in the csv_data table of an SQLite file, and the
+// rows of those inserted with rows in csv_rows, for deployments without
+// PostgreSQL. JSON is stored as text. It implements storage.Store.
+type SQLiteDB struct {
+	DB *sql.DB
+}
+
+var _ storage.Store = (*SQLiteDB)(nil)
+
+// timeFormat is how SQLiteDB stores created_at: UTC with microseconds, as
+// precise as Postgres, and fixed width so text order is time order.
+const timeFormat = "2006-01-02T15:04:05.000000Z"
+
+// sqlite binds arguments as ? and created_at as timeFormat text
+var sqlite = dialect{
+	placeholder: func(int) string { return "?" },
+	timestamp:   func(t time.Time) interface{} { return t.UTC().Format(timeFormat) },
+	noLimit:     "-1",
+}
+
+// ErrSQLiteUnavailable is returned by NewSQLiteDB when the binary was built
+// without cgo, which the SQLite driver needs
+var ErrSQLiteUnavailable = errors.New("SQLite storage is not available: build with CGO_ENABLED=1 and a C compiler")
+
+// NewSQLiteDB opens the SQLite database at path, creating the file if it
+// does not exist. It returns ErrSQLiteUnavailable in builds without cgo
+func NewSQLiteDB(path string) (*SQLiteDB, error) {
+	if !sqliteAvailable {
+		return nil, ErrSQLiteUnavailable
+	}
+
+	// WAL lets uploads be read while another is written; writers wait for
+	// each other rather than failing with "database is locked". Foreign
+	// keys delete the rows of an upload with it
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_busy_timeout", "5000")
+	params.Set("_txlock", "immediate")
+	params.Set("_foreign_keys", "1")
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// Test the connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+
+	return &SQLiteDB{DB: db}, nil
+}
+
+// InitSchema brings the schema up to date by applying pending migrations
+func (s *SQLiteDB) InitSchema() error {
+	return s.InitSchemaContext(context.Background())
+}
+
+// InitSchemaContext is InitSchema with a context
+func (s *SQLiteDB) InitSchemaContext(ctx context.Context) error {
+	if err := s.Migrator().Up(ctx); err != nil {
+		return fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	return nil
+}
+
+// Insert stores an upload, implementing storage.Store
+func (s *SQLiteDB) Insert(ctx context.Context, u *storage.Upload) (int, error) {
+	return s.insert(ctx, s.DB, u)
+}
+
+// InsertWithRows stores an upload and each of its records, implementing
+// storage.Store
+func (s *SQLiteDB) InsertWithRows(ctx context.Context, u *storage.Upload) (int, error) {
+	return insertWithRows(ctx, s.DB, sqlite, u, s.insert)
+}
+
+// insert stores an upload with db, which may be a transaction
+func (s *SQLiteDB) insert(ctx context.Context, db querier, u *storage.Upload) (int, error) {
+	columnsData, err := marshalColumns(u.Columns)
+	if err != nil {
+		return 0, err
+	}
+
+	createdAt := u.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	createdAt = createdAt.UTC().Truncate(time.Microsecond)
+
+	query := `
+		INSERT INTO csv_data (filename, data, columns, schema, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	// Text, not blobs, so that json_valid and SQLite's JSON functions apply
+	result, err := db.ExecContext(ctx, query, u.Filename, string(u.Data), text(columnsData), text(u.Schema), sqlite.timestamp(createdAt))
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert data: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert data: %w", err)
+	}
+
+	u.ID = int(id)
+	u.CreatedAt = createdAt
+	return u.ID, nil
+}
+
+// List returns every stored upload, newest first
+func (s *SQLiteDB) List(ctx context.Context) ([]storage.Upload, error) {
+	return s.Query(ctx, storage.Query{})
+}
+
+// Get returns the upload stored under id, or storage.ErrNotFound
+func (s *SQLiteDB) Get(ctx context.Context, id int) (*storage.Upload, error) {
+	return getUpload(ctx, s.DB, sqlite, id)
+}
+
+// Delete removes the upload stored under id, or returns storage.ErrNotFound
+func (s *SQLiteDB) Delete(ctx context.Context, id int) error {
+	return deleteUpload(ctx, s.DB, sqlite, id)
+}
+
+// Query returns the uploads selected by q, newest first unless it says
+// otherwise
+func (s *SQLiteDB) Query(ctx context.Context, q storage.Query) ([]storage.Upload, error) {
+	return queryUploads(ctx, s.DB, sqlite, q)
+}
+
+// Count returns the number of uploads matching the filters of q
+func (s *SQLiteDB) Count(ctx context.Context, q storage.Query) (int, error) {
+	return countUploads(ctx, s.DB, sqlite, q)
+}
+
+// GetRows returns the rows of the upload stored under id from start
+func (s *SQLiteDB) GetRows(ctx context.Context, id, start, limit int) ([]storage.Row, error) {
+	return getRows(ctx, s.DB, sqlite, id, start, limit)
+}
+
+// GetRow returns row n of the upload stored under id, or storage.ErrNotFound
+func (s *SQLiteDB) GetRow(ctx context.Context, id, n int) (*storage.Row, error) {
+	return getRow(ctx, s.DB, sqlite, id, n)
+}
+
+// Close closes the database
+func (s *SQLiteDB) Close() error {
+	return s.DB.Close()
+}
+
+// text binds JSON as SQLite text, keeping nil as NULL.
+func text(data []byte) interface{} {
+	if data == nil {
+		return nil
+	}
+	return string(data)
+}
@@ -0,0 +1,7 @@
+//go:build cgo
+
+package database
+
+// sqliteAvailable reports whether the SQLite driver, which needs cgo, works
+// in this build
+const sqliteAvailable = true
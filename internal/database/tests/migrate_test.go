@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/database"
+)
+
+func newSQLiteDB(t *testing.T, path string) *database.SQLiteDB {
+	t.Helper()
+
+	db, err := database.NewSQLiteDB(path)
+	if errors.Is(err, database.ErrSQLiteUnavailable) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count > 0
+}
+
+func version(t *testing.T, m *database.Migrator) int {
+	t.Helper()
+
+	v, err := m.Version(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestMigrator_UpDown(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteDB(t, filepath.Join(t.TempDir(), "csv2json.db"))
+	m := db.Migrator()
+
+	if v := version(t, m); v != 0 {
+		t.Fatalf("expected version 0, got %d", v)
+	}
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	latest := m.Migrations()[len(m.Migrations())-1].Version
+	if v := version(t, m); v != latest || !tableExists(t, db.DB, "csv_data") {
+		t.Fatalf("expected version %d with csv_data, got %d", latest, v)
+	}
+
+	// Running again applies nothing
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Down(ctx, len(m.Migrations())); err != nil {
+		t.Fatal(err)
+	}
+	if v := version(t, m); v != 0 || tableExists(t, db.DB, "csv_data") {
+		t.Errorf("expected version 0 without csv_data, got %d", v)
+	}
+}
+
+func TestMigrator_AdoptsInitSchemaDatabase(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "csv2json.db")
+	db := newSQLiteDB(t, path)
+
+	// The table as InitSchema created it before migrations
+	_, err := db.DB.Exec(`
+	CREATE TABLE IF NOT EXISTS csv_data (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		filename TEXT,
+		data TEXT NOT NULL CHECK (json_valid(data)),
+		columns TEXT CHECK (columns IS NULL OR json_valid(columns)),
+		schema TEXT CHECK (schema IS NULL OR json_valid(schema)),
+		created_at TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_csv_data_created_at ON csv_data(created_at);
+	CREATE INDEX IF NOT EXISTS idx_csv_data_filename ON csv_data(filename);
+	INSERT INTO csv_data (filename, data, created_at) VALUES ('old.csv', '[]', '2024-03-01T12:00:00.000000Z');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.InitSchema(); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := db.Migrator().Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[0].Applied || statuses[0].Version != 1 {
+		t.Errorf("expected the baseline to be recorded as applied, got %+v", statuses[0])
+	}
+	upload, err := db.Get(ctx, 1)
+	if err != nil || upload.Filename != "old.csv" {
+		t.Errorf("expected the existing upload to be kept, got %+v, %v", upload, err)
+	}
+}
+
+func TestMigrator_Custom(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteDB(t, filepath.Join(t.TempDir(), "csv2json.db"))
+	m := db.MigratorFrom(fstest.MapFS{
+		"0001_create_items.up.sql":   {Data: []byte(`CREATE TABLE items (id INTEGER PRIMARY KEY);`)},
+		"0001_create_items.down.sql": {Data: []byte(`DROP TABLE items;`)},
+		"0002_add_name.up.sql":       {Data: []byte(`ALTER TABLE items ADD COLUMN name TEXT;`)},
+		"0002_add_name.down.sql":     {Data: []byte(`ALTER TABLE items DROP COLUMN name;`)},
+		"0010_seed.up.sql":           {Data: []byte(`INSERT INTO items (name) VALUES ('a'), ('b');`)},
+	})
+
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if v := version(t, m); v != 10 {
+		t.Fatalf("expected version 10, got %d", v)
+	}
+
+	var count int
+	if err := db.DB.QueryRow(`SELECT COUNT(name) FROM items`).Scan(&count); err != nil || count != 2 {
+		t.Errorf("expected 2 seeded items, got %d, %v", count, err)
+	}
+
+	// 0010 has no down migration
+	err := m.Down(ctx, 1)
+	if err == nil || !strings.Contains(err.Error(), "cannot revert migration 0010_seed: no down migration") {
+		t.Errorf("expected no down migration error, got %v", err)
+	}
+	if v := version(t, m); v != 10 {
+		t.Errorf("expected version 10 to remain, got %d", v)
+	}
+}
+
+func TestMigrator_FailedMigrationRollsBack(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteDB(t, filepath.Join(t.TempDir(), "csv2json.db"))
+	m := db.MigratorFrom(fstest.MapFS{
+		"0001_create_items.up.sql": {Data: []byte(`CREATE TABLE items (id INTEGER PRIMARY KEY);`)},
+		"0002_broken.up.sql":       {Data: []byte(`CREATE TABLE other (id INTEGER); SELECT * FROM missing;`)},
+	})
+
+	err := m.Up(ctx)
+	if err == nil || !strings.Contains(err.Error(), "failed to apply migration 0002_broken") {
+		t.Fatalf("expected failure in 0002_broken, got %v", err)
+	}
+	if v := version(t, m); v != 1 {
+		t.Errorf("expected version 1, got %d", v)
+	}
+	if tableExists(t, db.DB, "other") {
+		t.Error("expected the failed migration to be rolled back")
+	}
+}
+
+func TestMigrator_InvalidMigrations(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{"bad name", fstest.MapFS{"create.sql": {}}, "name is not NNNN_name.up.sql"},
+		{"version zero", fstest.MapFS{"0000_init.up.sql": {}}, "version must be a positive number"},
+		{"no up", fstest.MapFS{"0001_init.down.sql": {Data: []byte("SELECT 1;")}}, "0001_init: no up migration"},
+		{"two names", fstest.MapFS{
+			"0001_init.up.sql":  {Data: []byte("SELECT 1;")},
+			"0001_other.up.sql": {Data: []byte("SELECT 1;")},
+		}, "version 1 is also named init"},
+	}
+
+	db := newSQLiteDB(t, filepath.Join(t.TempDir(), "csv2json.db"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := db.MigratorFrom(tt.files).Up(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestMigrator_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "csv2json.db")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		db := newSQLiteDB(t, path)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- db.InitSchema()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := newSQLiteDB(t, path)
+	var count int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != len(db.Migrator().Migrations()) {
+		t.Errorf("expected each migration recorded once, got %d rows", count)
+	}
+}
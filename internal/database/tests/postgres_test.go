@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/database"
+	"github.com/agileproject-gurpreet/csv2json/internal/storage"
+	"github.com/agileproject-gurpreet/csv2json/internal/storage/storagetest"
+)
+
+// postgresConfig returns the database named by the TEST_DB_* variables,
+// skipping the test without TEST_DB_HOST. The tests empty and drop its
+// tables, so point them at a scratch database.
+func postgresConfig(t *testing.T) database.Config {
+	host := os.Getenv("TEST_DB_HOST")
+	if host == "" {
+		t.Skip("TEST_DB_HOST not set")
+	}
+	return database.Config{
+		Host:     host,
+		Port:     getEnv("TEST_DB_PORT", "5432"),
+		User:     getEnv("TEST_DB_USER", "postgres"),
+		Password: getEnv("TEST_DB_PASSWORD", "postgres"),
+		DBName:   getEnv("TEST_DB_NAME", "csv2json_test"),
+		SSLMode:  getEnv("TEST_DB_SSLMODE", "disable"),
+	}
+}
+
+func TestPostgresDB(t *testing.T) {
+	config := postgresConfig(t)
+
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		db, err := database.NewPostgresDB(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.InitSchema(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.DB.Exec(`TRUNCATE csv_data, csv_rows RESTART IDENTITY`); err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
+
+func TestPostgresDB_AdoptsInitSchemaDatabase(t *testing.T) {
+	config := postgresConfig(t)
+	db, err := database.NewPostgresDB(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// csv_data as the first InitSchema created it, before columns and schema
+	_, err = db.DB.Exec(`
+	DROP TABLE IF EXISTS schema_migrations;
+	DROP TABLE IF EXISTS csv_rows;
+	DROP TABLE IF EXISTS csv_data;
+	CREATE TABLE csv_data (
+		id SERIAL PRIMARY KEY,
+		filename VARCHAR(255),
+		data JSONB NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO csv_data (filename, data) VALUES ('old.csv', '[{"a": 1}]');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Servers starting together wait for each other on the advisory lock
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- db.InitSchema()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	migrations := db.Migrator().Migrations()
+	if v, err := db.Migrator().Version(ctx); err != nil || v != migrations[len(migrations)-1].Version {
+		t.Errorf("expected the latest version, got %d, %v", v, err)
+	}
+	upload, err := db.Get(ctx, 1)
+	if err != nil || upload.Filename != "old.csv" || upload.Columns != nil {
+		t.Errorf("expected the existing upload to be kept, got %+v, %v", upload, err)
+	}
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/database"
+	"github.com/agileproject-gurpreet/csv2json/internal/storage"
+	"github.com/agileproject-gurpreet/csv2json/internal/storage/storagetest"
+)
+
+func openSQLite(t *testing.T, path string) *database.SQLiteDB {
+	t.Helper()
+
+	db, err := database.NewSQLiteDB(path)
+	if errors.Is(err, database.ErrSQLiteUnavailable) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InitSchema(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSQLiteDB(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		return openSQLite(t, filepath.Join(t.TempDir(), "csv2json.db"))
+	})
+}
+
+func TestSQLiteDB_PersistsAcrossOpens(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "csv2json.db")
+
+	db := openSQLite(t, path)
+	u := &storage.Upload{Filename: "people.csv", Data: []byte(`[{"name":"Alice"}]`), Columns: []string{"name"}}
+	if _, err := db.Insert(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// Reopening runs InitSchema again over the existing table
+	db = openSQLite(t, path)
+	defer db.Close()
+
+	got, err := db.Get(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Filename != "people.csv" || string(got.Data) != `[{"name":"Alice"}]` || !got.CreatedAt.Equal(u.CreatedAt) {
+		t.Errorf("unexpected upload %+v", got)
+	}
+
+	var kind string
+	if err := db.DB.QueryRow(`SELECT typeof(data) FROM csv_data WHERE id = ?`, u.ID).Scan(&kind); err != nil {
+		t.Fatal(err)
+	}
+	if kind != "text" {
+		t.Errorf("expected data stored as text, got %s", kind)
+	}
+}
+
+func TestSQLiteDB_RejectsInvalidJSON(t *testing.T) {
+	db := openSQLite(t, filepath.Join(t.TempDir(), "csv2json.db"))
+	defer db.Close()
+
+	_, err := db.Insert(context.Background(), &storage.Upload{Data: []byte(`[{"name":`)})
+	if err == nil || !strings.Contains(err.Error(), "CHECK constraint failed") {
+		t.Errorf("expected CHECK constraint error, got %v", err)
+	}
+}
+
+func TestSQLiteDB_Indexes(t *testing.T) {
+	db := openSQLite(t, filepath.Join(t.TempDir(), "csv2json.db"))
+	defer db.Close()
+
+	rows, err := db.DB.Query(`SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'csv_data' ORDER BY name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	want := "idx_csv_data_created_at,idx_csv_data_filename,idx_csv_data_filename_id"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("unexpected indexes %s", got)
+	}
+}
+
+func TestNewSQLiteDB_BadPath(t *testing.T) {
+	if _, err := database.NewSQLiteDB(filepath.Join(t.TempDir(), "missing", "csv2json.db")); err == nil {
+		t.Error("expected error for a directory that does not exist")
+	}
+}
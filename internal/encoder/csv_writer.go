@@ -0,0 +1,75 @@
+package encoder
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CSVWriter writes CSV records. Unlike encoding/csv it supports any quote
+// character and quoting every field.
+type CSVWriter struct {
+	w         *bufio.Writer
+	delimiter rune
+	quote     rune
+	quoteAll  bool
+}
+
+// NewCSVWriter returns a CSVWriter that writes to w. A zero delimiter or
+// quote means ',' or '"'.
+func NewCSVWriter(w io.Writer, delimiter, quote rune, quoteAll bool) *CSVWriter {
+	if delimiter == 0 {
+		delimiter = ','
+	}
+	if quote == 0 {
+		quote = '"'
+	}
+	return &CSVWriter{w: bufio.NewWriter(w), delimiter: delimiter, quote: quote, quoteAll: quoteAll}
+}
+
+// Write writes one record, quoting fields where needed.
+func (c *CSVWriter) Write(fields []string) error {
+	for i, field := range fields {
+		if i > 0 {
+			c.w.WriteRune(c.delimiter)
+		}
+		if !c.needsQuotes(field) {
+			c.w.WriteString(field)
+			continue
+		}
+
+		c.w.WriteRune(c.quote)
+		for _, r := range field {
+			if r == c.quote {
+				c.w.WriteRune(c.quote)
+			}
+			c.w.WriteRune(r)
+		}
+		c.w.WriteRune(c.quote)
+	}
+	_, err := c.w.WriteString("\n")
+	return err
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (c *CSVWriter) Flush() error {
+	return c.w.Flush()
+}
+
+// needsQuotes follows encoding/csv: fields containing the delimiter, the
+// quote or a line break, or starting with a space, are quoted.
+func (c *CSVWriter) needsQuotes(field string) bool {
+	if c.quoteAll {
+		return true
+	}
+	if field == "" {
+		return false
+	}
+	if strings.ContainsRune(field, c.delimiter) || strings.ContainsRune(field, c.quote) || strings.ContainsAny(field, "\r\n") {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(field)
+	return unicode.IsSpace(r)
+}
@@ -0,0 +1,116 @@
+package encoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Flatten turns a nested JSON value into an Object of its scalar leaves,
+// keyed by path: object members are joined with sep, DefaultSeparator if
+// empty, and array elements are addressed as "[n]". This is the reverse of
+// Nester. Empty objects and arrays have no leaves.
+func Flatten(v interface{}, sep string) Object {
+	if sep == "" {
+		sep = DefaultSeparator
+	}
+
+	var leaves Object
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		join := func(key string) string {
+			if prefix == "" {
+				return key
+			}
+			return prefix + sep + key
+		}
+
+		switch v := v.(type) {
+		case Object:
+			for _, m := range v {
+				walk(join(m.Key), m.Value)
+			}
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(join(k), v[k])
+			}
+		case []interface{}:
+			for i, e := range v {
+				walk(prefix+"["+strconv.Itoa(i)+"]", e)
+			}
+		default:
+			leaves = append(leaves, Member{Key: prefix, Value: v})
+		}
+	}
+	walk("", v)
+	return leaves
+}
+
+// FormatScalar renders a JSON scalar as CSV text. Null is empty.
+func FormatScalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// DecodeOrdered decodes the next JSON value from dec, returning objects as
+// Object so their key order is kept. dec should use UseNumber.
+func DecodeOrdered(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		var object Object
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := DecodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			object = append(object, Member{Key: key.(string), Value: value})
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		if object == nil {
+			object = Object{}
+		}
+		return object, nil
+	case json.Delim('['):
+		array := []interface{}{}
+		for dec.More() {
+			value, err := DecodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return array, nil
+	}
+	return token, nil
+}
@@ -0,0 +1,74 @@
+package encoder
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is an output format for converted records.
+type Format string
+
+const (
+	// FormatJSON writes a single JSON array.
+	FormatJSON Format = "json"
+	// FormatNDJSON writes one JSON value per line (JSON Lines).
+	FormatNDJSON Format = "ndjson"
+)
+
+// ParseFormat returns the Format named by s. "jsonl" is accepted for NDJSON.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "json":
+		return FormatJSON, nil
+	case "ndjson", "jsonl":
+		return FormatNDJSON, nil
+	}
+	return "", fmt.Errorf("unknown format %q", s)
+}
+
+// ContentType returns the media type of f.
+func (f Format) ContentType() string {
+	if f == FormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "application/json"
+}
+
+// Writer streams values to an output one at a time.
+type Writer interface {
+	// Write appends v to the output.
+	Write(v interface{}) error
+	// Close terminates the output; it does not close the underlying writer.
+	Close() error
+	// Count returns the number of values written so far.
+	Count() int
+}
+
+// NewWriter returns a Writer for format writing to w. The empty Format is
+// FormatJSON.
+func NewWriter(w io.Writer, format Format) (Writer, error) {
+	switch format {
+	case "", FormatJSON:
+		return NewArrayWriter(w), nil
+	case FormatNDJSON:
+		return NewLineWriter(w), nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// LinesToArray rewrites NDJSON produced by a LineWriter as a JSON array.
+func LinesToArray(data []byte) []byte {
+	lines := bytes.Split(bytes.TrimSuffix(data, []byte{'\n'}), []byte{'\n'})
+	if len(data) == 0 {
+		lines = nil
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(data) + 2)
+	buf.WriteByte('[')
+	buf.Write(bytes.Join(lines, []byte{','}))
+	buf.WriteByte(']')
+	return buf.Bytes()
+}
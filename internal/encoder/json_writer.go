@@ -0,0 +1,70 @@
+package encoder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ArrayWriter streams values to an io.Writer as the elements of a single
+// JSON array, so callers never have to hold the full result in memory.
+type ArrayWriter struct {
+	// Indent pretty-prints the array with one element per line, nested by
+	// Indent per level. Empty writes compact JSON.
+	Indent string
+
+	w     io.Writer
+	count int
+}
+
+// NewArrayWriter returns an ArrayWriter that writes to w.
+func NewArrayWriter(w io.Writer) *ArrayWriter {
+	return &ArrayWriter{w: w}
+}
+
+// Write marshals v and appends it to the array.
+func (a *ArrayWriter) Write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal to JSON: %w", err)
+	}
+
+	sep := ","
+	if a.count == 0 {
+		sep = "["
+	}
+	if a.Indent != "" {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, a.Indent, a.Indent); err != nil {
+			return fmt.Errorf("failed to indent JSON: %w", err)
+		}
+		data = buf.Bytes()
+		sep += "\n" + a.Indent
+	}
+	if _, err := io.WriteString(a.w, sep); err != nil {
+		return err
+	}
+	if _, err := a.w.Write(data); err != nil {
+		return err
+	}
+	a.count++
+	return nil
+}
+
+// Close terminates the array. An array with no elements is written as [].
+func (a *ArrayWriter) Close() error {
+	end := "]"
+	if a.count == 0 {
+		end = "[]"
+	} else if a.Indent != "" {
+		end = "\n]"
+	}
+	_, err := io.WriteString(a.w, end)
+	return err
+}
+
+// Count returns the number of values written so far.
+func (a *ArrayWriter) Count() int {
+	return a.count
+}
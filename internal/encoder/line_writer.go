@@ -0,0 +1,44 @@
+package encoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// LineWriter streams values to an io.Writer as newline-delimited JSON, one
+// value per line. encoding/json escapes newlines inside strings, so every
+// line is a complete value.
+type LineWriter struct {
+	w     io.Writer
+	count int
+}
+
+// NewLineWriter returns a LineWriter that writes to w.
+func NewLineWriter(w io.Writer) *LineWriter {
+	return &LineWriter{w: w}
+}
+
+// Write marshals v and writes it as a line.
+func (l *LineWriter) Write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal to JSON: %w", err)
+	}
+
+	if _, err := l.w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	l.count++
+	return nil
+}
+
+// Close does nothing; NDJSON has no terminator. It is there to satisfy Writer.
+func (l *LineWriter) Close() error {
+	return nil
+}
+
+// Count returns the number of values written so far.
+func (l *LineWriter) Count() int {
+	return l.count
+}
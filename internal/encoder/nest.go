@@ -0,0 +1,210 @@
+package encoder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultSeparator separates the segments of a nested header path.
+const DefaultSeparator = "."
+
+// maxArrayIndex bounds the arrays a header path may create.
+const maxArrayIndex = 9999
+
+// PathError reports a header that cannot be nested: either it conflicts
+// with Other, as "a" does with "a.b" and "a[0]" with "a.b", or it is not
+// a valid path.
+type PathError struct {
+	Key string
+	// Other is the conflicting header, if any.
+	Other string
+	// Reason explains why Key is not a valid path.
+	Reason string
+}
+
+func (e *PathError) Error() string {
+	if e.Other != "" {
+		return fmt.Sprintf("header %q conflicts with %q", e.Key, e.Other)
+	}
+	return fmt.Sprintf("header %q: %s", e.Key, e.Reason)
+}
+
+// Nester rebuilds nested objects and arrays from flat records whose keys
+// are paths such as "address.city" or "tags[0]".
+type Nester struct {
+	root *node
+	keys []string
+}
+
+// node is an object member or array element in the nested layout. Leaves
+// take the value of a record field; containers are objects or arrays.
+type node struct {
+	name     string
+	index    int
+	key      string
+	column   int
+	array    bool
+	children []*node
+}
+
+type segment struct {
+	name  string
+	index int
+}
+
+// NewNester plans the nesting of records with the given keys, in order.
+// Segments are split on sep, DefaultSeparator if empty, and a trailing
+// "[n]" addresses element n of an array. Keys that would need to be both a
+// value and a container, or both an object and an array, are reported as a
+// *PathError.
+func NewNester(keys []string, sep string) (*Nester, error) {
+	if sep == "" {
+		sep = DefaultSeparator
+	}
+
+	n := &Nester{root: &node{column: -1}}
+	for column, key := range keys {
+		path, err := parsePath(key, sep)
+		if err != nil {
+			return nil, err
+		}
+		if err := n.insert(key, column, path); err != nil {
+			return nil, err
+		}
+	}
+	for _, child := range n.root.children {
+		n.keys = append(n.keys, child.name)
+	}
+	return n, nil
+}
+
+func (n *Nester) insert(key string, column int, path []segment) error {
+	parent := n.root
+	for i, seg := range path {
+		array := seg.name == ""
+		if len(parent.children) > 0 && parent.array != array {
+			return &PathError{Key: key, Other: parent.children[0].key}
+		}
+		parent.array = array
+
+		var child *node
+		for _, c := range parent.children {
+			if c.name == seg.name && c.index == seg.index {
+				child = c
+				break
+			}
+		}
+
+		last := i == len(path)-1
+		if child == nil {
+			child = &node{name: seg.name, index: seg.index, key: key, column: -1}
+			parent.children = append(parent.children, child)
+		} else if last || child.column >= 0 {
+			return &PathError{Key: key, Other: child.key}
+		}
+		if last {
+			child.column = column
+		}
+		parent = child
+	}
+	return nil
+}
+
+// Keys returns the top-level keys of the nested objects.
+func (n *Nester) Keys() []string {
+	return n.keys
+}
+
+// Pointers returns a JSON Pointer, such as "/address/city", for each member
+// of the objects below the top level, in the order Nest writes them. Array
+// elements are addressed by index.
+func (n *Nester) Pointers() []string {
+	var pointers []string
+	var walk func(nd *node, pointer string)
+	walk = func(nd *node, pointer string) {
+		for _, c := range nd.children {
+			var child string
+			if nd.array {
+				child = pointer + "/" + strconv.Itoa(c.index)
+			} else {
+				child = pointer + "/" + pointerToken.Replace(c.name)
+				if nd != n.root {
+					pointers = append(pointers, child)
+				}
+			}
+			walk(c, child)
+		}
+	}
+	walk(n.root, "")
+	return pointers
+}
+
+// Nest builds the nested object for values, given in the order of the
+// keys passed to NewNester. Array elements with no column are null.
+func (n *Nester) Nest(values []interface{}) Object {
+	return n.build(n.root, values).(Object)
+}
+
+func (n *Nester) build(nd *node, values []interface{}) interface{} {
+	if nd.column >= 0 {
+		return values[nd.column]
+	}
+
+	if nd.array {
+		size := 0
+		for _, c := range nd.children {
+			size = max(size, c.index+1)
+		}
+		elements := make([]interface{}, size)
+		for _, c := range nd.children {
+			elements[c.index] = n.build(c, values)
+		}
+		return elements
+	}
+
+	object := make(Object, len(nd.children))
+	for i, c := range nd.children {
+		object[i] = Member{Key: c.name, Value: n.build(c, values)}
+	}
+	return object
+}
+
+// parsePath splits key into object member names and array indices. Only
+// "[digits]" after a name or another index is an index; other brackets are
+// part of the name.
+func parsePath(key, sep string) ([]segment, error) {
+	var path []segment
+	for _, part := range strings.Split(key, sep) {
+		name, indices := splitIndices(part)
+		if name == "" {
+			return nil, &PathError{Key: key, Reason: "empty path segment"}
+		}
+		path = append(path, segment{name: name})
+		for _, i := range indices {
+			if i > maxArrayIndex {
+				return nil, &PathError{Key: key, Reason: fmt.Sprintf("array index %d exceeds %d", i, maxArrayIndex)}
+			}
+			path = append(path, segment{index: i})
+		}
+	}
+	return path, nil
+}
+
+// splitIndices removes trailing "[n]" suffixes from part.
+func splitIndices(part string) (string, []int) {
+	var indices []int
+	for strings.HasSuffix(part, "]") {
+		open := strings.LastIndexByte(part, '[')
+		if open <= 0 {
+			break
+		}
+		i, err := strconv.Atoi(part[open+1 : len(part)-1])
+		if err != nil || strings.ContainsAny(part[open+1:len(part)-1], "+-") {
+			break
+		}
+		indices = append([]int{i}, indices...)
+		part = part[:open]
+	}
+	return part, indices
+}
@@ -0,0 +1,179 @@
+package encoder
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Member is a key/value pair of an Object.
+type Member struct {
+	Key   string
+	Value interface{}
+}
+
+// Object is a JSON object that marshals its members in order, unlike a map
+// whose keys encoding/json sorts.
+type Object []Member
+
+// MarshalJSON implements json.Marshaler.
+func (o Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(m.Key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+
+		value, err := json.Marshal(m.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// OrderObjects decodes data and, if it is an array of objects, returns each
+// object as an Object with its keys in columns order. Keys missing from
+// columns follow in sorted order. Columns may also hold JSON Pointers of
+// nested members, as from Nester.Pointers, which order the objects nested
+// in each record the same way; other nested objects have sorted keys. Any
+// other JSON is returned decoded as is. Numbers are decoded as json.Number
+// so they round-trip exactly.
+func OrderObjects(data []byte, columns []string) (interface{}, error) {
+	v, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	items, ok := v.([]interface{})
+	if !ok {
+		return v, nil
+	}
+
+	position := positions(columns)
+	ordered := make([]interface{}, len(items))
+	for i, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			ordered[i] = item
+			continue
+		}
+		ordered[i] = position.order(m, "")
+	}
+	return ordered, nil
+}
+
+// OrderObject is OrderObjects for a single object rather than an array of
+// them.
+func OrderObject(data []byte, columns []string) (interface{}, error) {
+	v, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if m, ok := v.(map[string]interface{}); ok {
+		return positions(columns).order(m, ""), nil
+	}
+	return v, nil
+}
+
+func decode(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// pointerToken escapes an object member name as a JSON Pointer token.
+var pointerToken = strings.NewReplacer("~", "~0", "/", "~1")
+
+// layout holds the key order of the objects of a record, by the JSON
+// Pointer of each object; "" is the record itself.
+type layout map[string]map[string]int
+
+// positions maps each column to its first index. A column that is a JSON
+// Pointer of two or more tokens also places its last token among the
+// members of the object its other tokens point to.
+func positions(columns []string) layout {
+	l := layout{"": make(map[string]int, len(columns))}
+	for _, c := range columns {
+		l.add("", c)
+		if i := strings.LastIndexByte(c, '/'); i > 0 && c[0] == '/' {
+			name := strings.NewReplacer("~1", "/", "~0", "~").Replace(c[i+1:])
+			l.add(c[:i], name)
+		}
+	}
+	return l
+}
+
+func (l layout) add(pointer, key string) {
+	position := l[pointer]
+	if position == nil {
+		position = make(map[string]int)
+		l[pointer] = position
+	}
+	if _, seen := position[key]; !seen {
+		position[key] = len(position)
+	}
+}
+
+// order returns m, the object at pointer, as an Object in layout order,
+// with the objects nested in it ordered too.
+func (l layout) order(m map[string]interface{}, pointer string) Object {
+	o := orderMap(m, l[pointer])
+	for i, member := range o {
+		o[i].Value = l.orderValue(member.Value, pointer+"/"+pointerToken.Replace(member.Key))
+	}
+	return o
+}
+
+func (l layout) orderValue(v interface{}, pointer string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return l.order(v, pointer)
+	case []interface{}:
+		for i, element := range v {
+			v[i] = l.orderValue(element, pointer+"/"+strconv.Itoa(i))
+		}
+	}
+	return v
+}
+
+func orderMap(m map[string]interface{}, position map[string]int) Object {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		pi, iKnown := position[keys[i]]
+		pj, jKnown := position[keys[j]]
+		switch {
+		case iKnown && jKnown:
+			return pi < pj
+		case iKnown != jKnown:
+			return iKnown
+		}
+		return keys[i] < keys[j]
+	})
+
+	o := make(Object, len(keys))
+	for i, k := range keys {
+		o[i] = Member{Key: k, Value: m[k]}
+	}
+	return o
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+)
+
+func TestDecodeOrderedAndFlatten(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(`{"z":1,"a":{"y":[true,null],"b":{}},"m":"x"}`))
+	dec.UseNumber()
+
+	v, err := encoder.DecodeOrdered(dec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys, values []string
+	for _, m := range encoder.Flatten(v, "/") {
+		keys = append(keys, m.Key)
+		values = append(values, encoder.FormatScalar(m.Value))
+	}
+	if got := strings.Join(keys, ","); got != "z,a/y[0],a/y[1],m" {
+		t.Errorf("unexpected keys %s", got)
+	}
+	if got := strings.Join(values, ","); got != "1,true,,x" {
+		t.Errorf("unexpected values %s", got)
+	}
+}
+
+func TestFlatten_MapSortsKeys(t *testing.T) {
+	flat := encoder.Flatten(map[string]interface{}{"b": 2.5, "a": map[string]interface{}{"c": "x"}}, "")
+
+	if len(flat) != 2 || flat[0].Key != "a.c" || flat[1].Key != "b" || encoder.FormatScalar(flat[1].Value) != "2.5" {
+		t.Errorf("unexpected result %v", flat)
+	}
+}
+
+func TestCSVWriter_Quoting(t *testing.T) {
+	var buf bytes.Buffer
+	w := encoder.NewCSVWriter(&buf, '\t', 0, false)
+
+	if err := w.Write([]string{"plain", "tab\there", `say "hi"`, " lead", "line\nbreak", ""}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "plain\t\"tab\there\"\t\"say \"\"hi\"\"\"\t\" lead\"\t\"line\nbreak\"\t\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
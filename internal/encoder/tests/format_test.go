@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := map[string]encoder.Format{
+		"":       encoder.FormatJSON,
+		"json":   encoder.FormatJSON,
+		"NDJSON": encoder.FormatNDJSON,
+		"jsonl":  encoder.FormatNDJSON,
+	}
+	for input, want := range tests {
+		got, err := encoder.ParseFormat(input)
+		if err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+
+	if _, err := encoder.ParseFormat("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := encoder.NewLineWriter(&buf)
+
+	for _, v := range []interface{}{
+		encoder.Object{{Key: "b", Value: "line\nbreak"}, {Key: "a", Value: 1}},
+		[]string{"x"},
+	} {
+		if err := w.Write(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "{\"b\":\"line\\nbreak\",\"a\":1}\n[\"x\"]\n"; buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+	if w.Count() != 2 {
+		t.Errorf("expected count 2, got %d", w.Count())
+	}
+}
+
+func TestNewWriter_EmptyOutput(t *testing.T) {
+	for format, want := range map[encoder.Format]string{encoder.FormatJSON: "[]", encoder.FormatNDJSON: ""} {
+		var buf bytes.Buffer
+		w, err := encoder.NewWriter(&buf, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != want {
+			t.Errorf("%s: expected %q, got %q", format, want, buf.String())
+		}
+	}
+}
+
+func TestLinesToArray(t *testing.T) {
+	tests := map[string]string{
+		"":                     "[]",
+		"{\"a\":1}\n":          `[{"a":1}]`,
+		"{\"a\":1}\n[\"b\"]\n": `[{"a":1},["b"]]`,
+	}
+	for input, want := range tests {
+		if got := string(encoder.LinesToArray([]byte(input))); got != want {
+			t.Errorf("LinesToArray(%q) = %s, want %s", input, got, want)
+		}
+	}
+}
+
+func TestArrayWriter_Indent(t *testing.T) {
+	var buf bytes.Buffer
+	w := encoder.NewArrayWriter(&buf)
+	w.Indent = "  "
+
+	for _, v := range []interface{}{
+		encoder.Object{{Key: "b", Value: "x"}, {Key: "a", Value: []int{1}}},
+		encoder.Object{},
+	} {
+		if err := w.Write(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "[\n  {\n    \"b\": \"x\",\n    \"a\": [\n      1\n    ]\n  },\n  {}\n]"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+
+	buf.Reset()
+	empty := encoder.NewArrayWriter(&buf)
+	empty.Indent = "  "
+	empty.Close()
+	if buf.String() != "[]" {
+		t.Errorf("expected [], got %q", buf.String())
+	}
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+)
+
+func nest(t *testing.T, keys []string, sep string, values ...interface{}) string {
+	t.Helper()
+
+	n, err := encoder.NewNester(keys, sep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(n.Nest(values))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestNester_Objects(t *testing.T) {
+	got := nest(t, []string{"id", "address.city", "address.zip", "name"}, "", "1", "Paris", "75001", "Ann")
+
+	if want := `{"id":"1","address":{"city":"Paris","zip":"75001"},"name":"Ann"}`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestNester_Arrays(t *testing.T) {
+	got := nest(t, []string{"tags[1]", "tags[0]", "points[0].x", "points[0].y", "grid[0][1]"}, "", "b", "a", 1, 2, nil)
+
+	if want := `{"tags":["a","b"],"points":[{"x":1,"y":2}],"grid":[[null,null]]}`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestNester_Separator(t *testing.T) {
+	got := nest(t, []string{"a.b", "a__c"}, "__", "1", "2")
+
+	if want := `{"a.b":"1","a":{"c":"2"}}`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestNester_BracketsInNames(t *testing.T) {
+	got := nest(t, []string{"price [USD]", "size[m]"}, "", "1", "2")
+
+	if want := `{"price [USD]":"1","size[m]":"2"}`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestNester_Keys(t *testing.T) {
+	n, err := encoder.NewNester([]string{"b.x", "a", "b.y"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keys := n.Keys(); len(keys) != 2 || keys[0] != "b" || keys[1] != "a" {
+		t.Errorf("expected [b a], got %v", keys)
+	}
+}
+
+func TestNester_Pointers(t *testing.T) {
+	n, err := encoder.NewNester([]string{"id", "a.b", "a.a", "p[0].y", "p[0].x", "a/b.c~d"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"/a/b", "/a/a", "/p/0/y", "/p/0/x", "/a~1b/c~0d"}
+	if got := n.Pointers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestNester_Conflicts(t *testing.T) {
+	tests := []struct {
+		keys  []string
+		key   string
+		other string
+	}{
+		{[]string{"a", "a.b"}, "a.b", "a"},
+		{[]string{"a.b", "a"}, "a", "a.b"},
+		{[]string{"a[0]", "a.b"}, "a.b", "a[0]"},
+		{[]string{"a.b", "a[0]"}, "a[0]", "a.b"},
+		{[]string{"a[0]", "a[00]"}, "a[00]", "a[0]"},
+	}
+
+	for _, tt := range tests {
+		_, err := encoder.NewNester(tt.keys, "")
+
+		var pathErr *encoder.PathError
+		if !errors.As(err, &pathErr) {
+			t.Errorf("%v: expected PathError, got %v", tt.keys, err)
+			continue
+		}
+		if pathErr.Key != tt.key || pathErr.Other != tt.other {
+			t.Errorf("%v: expected %q to conflict with %q, got %+v", tt.keys, tt.key, tt.other, pathErr)
+		}
+	}
+}
+
+func TestNester_InvalidPaths(t *testing.T) {
+	for _, key := range []string{"a..b", ".a", "a.", "a[99999]"} {
+		_, err := encoder.NewNester([]string{key}, "")
+
+		var pathErr *encoder.PathError
+		if !errors.As(err, &pathErr) || pathErr.Other != "" {
+			t.Errorf("%q: expected invalid path error, got %v", key, err)
+		}
+	}
+}
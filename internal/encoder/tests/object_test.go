@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+)
+
+func TestObject_MarshalJSONKeepsOrder(t *testing.T) {
+	o := encoder.Object{
+		{Key: "zeta", Value: "1"},
+		{Key: "alpha", Value: 2},
+		{Key: "a\"b", Value: nil},
+	}
+
+	data, err := json.Marshal(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"zeta":"1","alpha":2,"a\"b":null}`
+	if string(data) != want {
+		t.Errorf("expected %s, got %s", want, data)
+	}
+}
+
+func TestOrderObjects(t *testing.T) {
+	data := []byte(`[{"alpha":"2","extra":3,"zeta":"1"},{"zeta":"4","alpha":"5"}]`)
+
+	ordered, err := encoder.OrderObjects(data, []string{"zeta", "alpha"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := json.Marshal(ordered)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `[{"zeta":"1","alpha":"2","extra":3},{"zeta":"4","alpha":"5"}]`
+	if string(out) != want {
+		t.Errorf("expected %s, got %s", want, out)
+	}
+}
+
+func TestOrderObjects_Nested(t *testing.T) {
+	keys := []string{"id", "a.b", "a.a", "p[0].y", "p[0].x"}
+	n, err := encoder.NewNester(keys, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns := append(n.Keys(), n.Pointers()...)
+
+	// As a store that reformats JSON would return it, with sorted keys
+	data, err := json.Marshal([]map[string]interface{}{{
+		"id": "1",
+		"a":  map[string]interface{}{"b": "2", "a": "3"},
+		"p":  []interface{}{map[string]interface{}{"y": 4, "x": 5}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ordered, err := encoder.OrderObjects(data, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := json.Marshal(ordered)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `[{"id":"1","a":{"b":"2","a":"3"},"p":[{"y":4,"x":5}]}]`
+	if string(out) != want {
+		t.Errorf("expected %s, got %s", want, out)
+	}
+}
+
+func TestOrderObjects_NonArray(t *testing.T) {
+	ordered, err := encoder.OrderObjects([]byte(`{"b":1,"a":2}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := ordered.(map[string]interface{}); !ok {
+		t.Errorf("expected a plain object, got %T", ordered)
+	}
+}
+
+func TestOrderObject(t *testing.T) {
+	ordered, err := encoder.OrderObject([]byte(`{"alpha":"2","extra":3,"zeta":"1"}`), []string{"zeta", "alpha"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := json.Marshal(ordered)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"zeta":"1","alpha":"2","extra":3}`
+	if string(out) != want {
+		t.Errorf("expected %s, got %s", want, out)
+	}
+}
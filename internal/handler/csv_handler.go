@@ -1,12 +1,27 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/agileproject-gurpreet/csv2json/internal/converter"
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+	"github.com/agileproject-gurpreet/csv2json/internal/infer"
+	"github.com/agileproject-gurpreet/csv2json/internal/parser"
 	"github.com/agileproject-gurpreet/csv2json/internal/service"
+	"github.com/agileproject-gurpreet/csv2json/internal/storage"
+	"github.com/agileproject-gurpreet/csv2json/internal/validate"
 )
 
 type CSVHandler struct {
@@ -50,22 +65,527 @@ func (h *CSVHandler) UploadCSV(w http.ResponseWriter, r *http.Request) {
 
 	h.logger.Printf("Processing file: %s (size: %d bytes)", header.Filename, header.Size)
 
+	opts, err := conversionOptions(r)
+	if err != nil {
+		h.logger.Printf("Invalid conversion options: %v", err)
+		http.Error(w, fmt.Sprintf("Invalid conversion options: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Rejected uploads must not send any records, so they are not streamed
+	rejecting := opts.Validation != nil && opts.ValidationMode != validate.ModeReport
+	if opts.Format == encoder.FormatNDJSON && !rejecting {
+		h.streamCSV(w, r, file, header.Filename, opts)
+		return
+	}
+
 	// Process the CSV file
-	jsonData, err := h.service.ProcessCSVReaderWithFilename(file, header.Filename)
+	jsonData, result, err := h.service.ProcessCSVReaderWithOptionsContext(r.Context(), file, header.Filename, opts)
 	if err != nil {
-		h.logger.Printf("Failed to process CSV file '%s': %v", header.Filename, err)
-		http.Error(w, fmt.Sprintf("Failed to process CSV: %v", err), http.StatusInternalServerError)
+		h.writeProcessError(w, header.Filename, err)
 		return
 	}
 
 	h.logger.Printf("Successfully processed CSV file: %s, converted %d bytes to JSON", header.Filename, len(jsonData))
 
+	if reporting(opts) {
+		if jsonData, err = json.Marshal(reportBody{Data: jsonData, Validation: result.Validation}); err != nil {
+			h.writeProcessError(w, header.Filename, err)
+			return
+		}
+	}
+
 	// Send JSON data response
-	w.Header().Set("Content-Type", "application/json")
+	setMetadataHeaders(w, result)
+	w.Header().Set("Content-Type", opts.Format.ContentType())
 	w.WriteHeader(http.StatusOK)
 	w.Write(jsonData)
 }
 
+// InferSchema handles a CSV upload by returning the JSON Schema of its
+// converted records. Types are inferred unless infer_types=false; the other
+// upload parameters apply as for UploadCSV. Nothing is stored.
+func (h *CSVHandler) InferSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.logger.Printf("Method not allowed: %s", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.logger.Println("Received schema request")
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		h.logger.Printf("Failed to parse form: %v", err)
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		h.logger.Printf("Failed to get file from form: %v", err)
+		http.Error(w, "Failed to get file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	opts, err := conversionOptions(r)
+	if err != nil {
+		h.logger.Printf("Invalid conversion options: %v", err)
+		http.Error(w, fmt.Sprintf("Invalid conversion options: %v", err), http.StatusBadRequest)
+		return
+	}
+	if r.FormValue("infer_types") == "" {
+		opts.InferTypes = true
+	}
+
+	result, err := h.service.InferSchemaContext(r.Context(), file, opts)
+	if err != nil {
+		h.writeProcessError(w, header.Filename, err)
+		return
+	}
+
+	h.logger.Printf("Successfully inferred schema of CSV file: %s (%d rows)", header.Filename, result.Rows)
+
+	setMetadataHeaders(w, result)
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result.Schema)
+}
+
+// metadataTrailers lists the headers sent as trailers when streaming, since
+// they are only known once the whole file has been read.
+var metadataTrailers = []string{
+	"X-CSV-Encoding", "X-CSV-Delimiter", "X-CSV-Quote", "X-CSV-Header",
+	"X-CSV-Ragged-Policy", "X-CSV-Ragged-Count", "X-CSV-Ragged-Lines",
+	"X-CSV-Header-Mapping", "X-CSV-Invalid-Rows", "X-CSV-Violation-Count",
+	"X-CSV-Error",
+}
+
+// streamCSV writes each record to the response as soon as it is converted.
+// Errors found before the first record are reported as usual; later ones
+// end the response early with the X-CSV-Error trailer.
+func (h *CSVHandler) streamCSV(w http.ResponseWriter, r *http.Request, file io.Reader, filename string, opts converter.Options) {
+	w.Header().Set("Trailer", strings.Join(metadataTrailers, ", "))
+	out := &streamWriter{w: w, contentType: opts.Format.ContentType()}
+
+	result, err := h.service.ProcessCSVStreamContext(r.Context(), file, out, filename, opts)
+	if err != nil {
+		if !out.started {
+			w.Header().Del("Trailer")
+			h.writeProcessError(w, filename, err)
+			return
+		}
+		h.logger.Printf("Failed to stream CSV file '%s': %v", filename, err)
+		w.Header().Set("X-CSV-Error", err.Error())
+		return
+	}
+
+	out.start()
+	setMetadataHeaders(w, result)
+	if reporting(opts) {
+		line, err := json.Marshal(reportBody{Validation: result.Validation})
+		if err != nil {
+			w.Header().Set("X-CSV-Error", err.Error())
+			return
+		}
+		out.Write(append(line, '\n'))
+	}
+	h.logger.Printf("Successfully streamed CSV file: %s, %d records", filename, result.Rows)
+}
+
+// reportBody is the response to an upload accepted in report mode: the
+// records as a JSON array with the full validation report, or for NDJSON a
+// last line with only the report.
+type reportBody struct {
+	Data       json.RawMessage  `json:"data,omitempty"`
+	Validation *validate.Report `json:"validation"`
+}
+
+// reporting reports whether opts validate in report mode.
+func reporting(opts converter.Options) bool {
+	return opts.Validation != nil && opts.ValidationMode == validate.ModeReport
+}
+
+// streamWriter commits a 200 response on its first write, so that errors
+// found before any output can still be sent with their own status.
+type streamWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	started     bool
+}
+
+func (s *streamWriter) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.w.Header().Set("Content-Type", s.contentType)
+	s.w.WriteHeader(http.StatusOK)
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	s.start()
+	return s.w.Write(p)
+}
+
+// writeProcessError reports a failed conversion: located problems in the
+// upload and rejected uploads as 422 with a JSON body, anything else as 500.
+func (h *CSVHandler) writeProcessError(w http.ResponseWriter, filename string, err error) {
+	if stopped(err) {
+		h.logger.Printf("Stopped processing CSV file '%s': %v", filename, err)
+		http.Error(w, fmt.Sprintf("Request cancelled: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+	var validationErr *validate.Error
+	if errors.As(err, &validationErr) {
+		h.logger.Printf("Rejected CSV file '%s': %v", filename, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(struct {
+			Error string `json:"error"`
+			*validate.Report
+		}{err.Error(), validationErr.Report})
+		return
+	}
+	var parseErr *parser.ParseError
+	if errors.As(err, &parseErr) {
+		h.logger.Printf("Failed to parse CSV file '%s': %v", filename, err)
+		writeParseError(w, err, parseErr)
+		return
+	}
+	var pathErr *encoder.PathError
+	if errors.As(err, &pathErr) {
+		h.logger.Printf("Failed to nest CSV file '%s': %v", filename, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	h.logger.Printf("Failed to process CSV file '%s': %v", filename, err)
+	http.Error(w, fmt.Sprintf("Failed to process CSV: %v", err), http.StatusInternalServerError)
+}
+
+// stopped reports whether err is due to the request being cancelled or
+// timing out.
+func stopped(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// errorStatus is the status for err: 503 if the request was stopped, or
+// else status.
+func errorStatus(err error, status int) int {
+	if stopped(err) {
+		return http.StatusServiceUnavailable
+	}
+	return status
+}
+
+// setMetadataHeaders reports how the upload was read.
+func setMetadataHeaders(w http.ResponseWriter, result *converter.Result) {
+	w.Header().Set("X-CSV-Encoding", result.Encoding)
+	setDialectHeaders(w, result.Dialect)
+	setRaggedHeaders(w, result)
+	setHeaderMappingHeader(w, result.Headers)
+	setValidationHeaders(w, result.Validation)
+}
+
+// writeParseError responds 422 with the location of a problem in the upload.
+func writeParseError(w http.ResponseWriter, err error, parseErr *parser.ParseError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(struct {
+		Error   string `json:"error"`
+		Line    int    `json:"line"`
+		Column  int    `json:"column"`
+		Snippet string `json:"snippet"`
+		Reason  string `json:"reason"`
+	}{err.Error(), parseErr.Line, parseErr.Column, parseErr.Snippet, parseErr.Reason})
+}
+
+// maxRaggedLinesHeader caps the line numbers listed in X-CSV-Ragged-Lines;
+// X-CSV-Ragged-Count always has the total.
+const maxRaggedLinesHeader = 100
+
+// setRaggedHeaders reports the ragged row policy and the rows it affected.
+func setRaggedHeaders(w http.ResponseWriter, result *converter.Result) {
+	w.Header().Set("X-CSV-Ragged-Policy", result.Ragged.String())
+	w.Header().Set("X-CSV-Ragged-Count", strconv.Itoa(len(result.RaggedLines)))
+	if len(result.RaggedLines) == 0 {
+		return
+	}
+
+	lines := result.RaggedLines
+	if len(lines) > maxRaggedLinesHeader {
+		lines = lines[:maxRaggedLinesHeader]
+	}
+	parts := make([]string, len(lines))
+	for i, line := range lines {
+		parts[i] = strconv.Itoa(line)
+	}
+	w.Header().Set("X-CSV-Ragged-Lines", strings.Join(parts, ","))
+}
+
+// setValidationHeaders summarises the violations of a validated upload; the
+// violations themselves are in the body. They are omitted when no
+// validation was requested.
+func setValidationHeaders(w http.ResponseWriter, report *validate.Report) {
+	if report == nil {
+		return
+	}
+	w.Header().Set("X-CSV-Invalid-Rows", strconv.Itoa(report.InvalidRows))
+	w.Header().Set("X-CSV-Violation-Count", strconv.Itoa(report.Total))
+}
+
+// setHeaderMappingHeader reports, as a JSON array, how header cells were
+// renamed. It is omitted when every column kept its name.
+func setHeaderMappingHeader(w http.ResponseWriter, headers []parser.Header) {
+	renamed := false
+	for _, h := range headers {
+		if h.Name != h.Original {
+			renamed = true
+			break
+		}
+	}
+	if !renamed {
+		return
+	}
+
+	mapping, err := json.Marshal(headers)
+	if err != nil {
+		return
+	}
+	w.Header().Set("X-CSV-Header-Mapping", string(mapping))
+}
+
+// conversionOptions reads the optional conversion parameters of an upload.
+// Omitted encoding and dialect parameters are detected from the file.
+func conversionOptions(r *http.Request) (converter.Options, error) {
+	var opts converter.Options
+
+	if v := r.FormValue("encoding"); v != "" {
+		encoding, err := parser.ParseEncoding(v)
+		if err != nil {
+			return opts, fmt.Errorf("encoding: %w", err)
+		}
+		opts.Parser.Encoding = encoding
+	}
+
+	if v := r.FormValue("delimiter"); v != "" {
+		d, err := parseDialectChar(v)
+		if err != nil {
+			return opts, fmt.Errorf("delimiter: %w", err)
+		}
+		opts.Parser.Delimiter = d
+	}
+
+	if v := r.FormValue("quote"); v != "" {
+		q, err := parseDialectChar(v)
+		if err != nil {
+			return opts, fmt.Errorf("quote: %w", err)
+		}
+		opts.Parser.Quote = q
+	}
+
+	switch v := r.FormValue("header"); v {
+	case "auto":
+		opts.Parser.Header = parser.HeaderAuto
+	case "", "true", "present":
+		opts.Parser.Header = parser.HeaderPresent
+	case "false", "absent":
+		opts.Parser.Header = parser.HeaderAbsent
+	default:
+		return opts, fmt.Errorf("header: unknown value %q", v)
+	}
+
+	if v := r.FormValue("columns"); v != "" {
+		names, err := parseColumnNames(v)
+		if err != nil {
+			return opts, fmt.Errorf("columns: %w", err)
+		}
+		opts.Parser.ColumnNames = names
+	}
+
+	if v := r.FormValue("arrays"); v != "" {
+		arrays, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("arrays: %w", err)
+		}
+		opts.Arrays = arrays
+	}
+
+	if v := r.FormValue("unflatten"); v != "" {
+		unflatten, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("unflatten: %w", err)
+		}
+		opts.Unflatten = unflatten
+	}
+	opts.Separator = r.FormValue("separator")
+
+	if v := r.FormValue("ragged"); v != "" {
+		policy, err := parser.ParseRaggedPolicy(v)
+		if err != nil {
+			return opts, fmt.Errorf("ragged: %w", err)
+		}
+		opts.Parser.Ragged = policy
+	}
+
+	if v := r.FormValue("trim_headers"); v != "" {
+		trim, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("trim_headers: %w", err)
+		}
+		opts.Parser.TrimHeaders = trim
+	}
+
+	if v := r.FormValue("header_case"); v != "" {
+		c, err := parser.ParseHeaderCase(v)
+		if err != nil {
+			return opts, fmt.Errorf("header_case: %w", err)
+		}
+		opts.Parser.HeaderCase = c
+	}
+
+	if v := r.FormValue("infer_types"); v != "" {
+		infer, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("infer_types: %w", err)
+		}
+		opts.InferTypes = infer
+	}
+
+	if v := r.FormValue("column_types"); v != "" {
+		types, err := parseColumnTypes(v)
+		if err != nil {
+			return opts, fmt.Errorf("column_types: %w", err)
+		}
+		opts.ColumnTypes = types
+	}
+
+	if opts.Unflatten && opts.Arrays {
+		return opts, fmt.Errorf("unflatten cannot be combined with arrays")
+	}
+
+	spec, err := validationSpec(r)
+	if err != nil {
+		return opts, fmt.Errorf("validation: %w", err)
+	}
+	if spec != nil {
+		if opts.Validation, err = validate.ParseSpec(spec); err != nil {
+			return opts, fmt.Errorf("validation: %w", err)
+		}
+	}
+	if v := r.FormValue("validation_mode"); v != "" {
+		mode, err := validate.ParseMode(v)
+		if err != nil {
+			return opts, fmt.Errorf("validation_mode: %w", err)
+		}
+		opts.ValidationMode = mode
+	}
+
+	// An explicit format wins over content negotiation
+	if v := r.FormValue("format"); v != "" {
+		format, err := encoder.ParseFormat(v)
+		if err != nil {
+			return opts, fmt.Errorf("format: %w", err)
+		}
+		opts.Format = format
+	} else if acceptsNDJSON(r) {
+		opts.Format = encoder.FormatNDJSON
+	}
+
+	return opts, nil
+}
+
+// validationSpec returns the validation spec of an upload, given as the
+// validation field or as a file part of that name, or nil.
+func validationSpec(r *http.Request) ([]byte, error) {
+	if v := r.FormValue("validation"); v != "" {
+		return []byte(v), nil
+	}
+	if r.MultipartForm == nil || len(r.MultipartForm.File["validation"]) == 0 {
+		return nil, nil
+	}
+
+	f, err := r.MultipartForm.File["validation"][0].Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// acceptsNDJSON reports whether the Accept header asks for NDJSON.
+func acceptsNDJSON(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(part)
+			if err == nil && mediaType == encoder.FormatNDJSON.ContentType() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseColumnNames accepts a comma-separated list, or a JSON array of
+// strings for names that contain commas.
+func parseColumnNames(v string) ([]string, error) {
+	if strings.HasPrefix(strings.TrimSpace(v), "[") {
+		var names []string
+		if err := json.Unmarshal([]byte(v), &names); err != nil {
+			return nil, err
+		}
+		return names, nil
+	}
+	return strings.Split(v, ","), nil
+}
+
+// parseColumnTypes parses a list such as "age:integer,active:boolean".
+func parseColumnTypes(v string) (map[string]infer.Type, error) {
+	types := make(map[string]infer.Type)
+	for _, entry := range strings.Split(v, ",") {
+		i := strings.LastIndex(entry, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("expected column:type, got %q", entry)
+		}
+		t, err := infer.ParseType(entry[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		types[entry[:i]] = t
+	}
+	return types, nil
+}
+
+// parseDialectChar accepts a single character, or "tab" since a literal tab
+// is awkward to pass in a URL.
+func parseDialectChar(v string) (rune, error) {
+	if v == "tab" || v == `\t` {
+		return '\t', nil
+	}
+	runes := []rune(v)
+	if len(runes) != 1 {
+		return 0, fmt.Errorf("expected a single character, got %q", v)
+	}
+	return runes[0], nil
+}
+
+func formatDialectChar(c rune) string {
+	if c == '\t' {
+		return "tab"
+	}
+	return string(c)
+}
+
+// setDialectHeaders reports the dialect the upload was read with.
+func setDialectHeaders(w http.ResponseWriter, d parser.Dialect) {
+	w.Header().Set("X-CSV-Delimiter", formatDialectChar(d.Delimiter))
+	w.Header().Set("X-CSV-Quote", formatDialectChar(d.Quote))
+	w.Header().Set("X-CSV-Header", strconv.FormatBool(d.HasHeader))
+}
+
 // Health check endpoint
 func (h *CSVHandler) Health(w http.ResponseWriter, r *http.Request) {
 	h.logger.Println("Health check requested")
@@ -76,7 +596,8 @@ func (h *CSVHandler) Health(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GetAllData retrieves all CSV data from the database
+// GetAllData lists stored CSV data a page at a time, filtered, sorted and
+// paged by the query parameters read by dataQuery
 func (h *CSVHandler) GetAllData(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		h.logger.Printf("Method not allowed: %s", r.Method)
@@ -86,18 +607,122 @@ func (h *CSVHandler) GetAllData(w http.ResponseWriter, r *http.Request) {
 
 	h.logger.Println("Received get all data request")
 
-	data, err := h.service.GetAllData()
+	q, err := dataQuery(r)
+	if err != nil {
+		h.logger.Printf("Invalid query: %v", err)
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.service.ListDataContext(r.Context(), q)
 	if err != nil {
 		h.logger.Printf("Failed to retrieve data: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to retrieve data: %v", err), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if errors.Is(err, storage.ErrCursorMismatch) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, fmt.Sprintf("Failed to retrieve data: %v", err), errorStatus(err, status))
 		return
 	}
 
-	h.logger.Printf("Successfully retrieved %d records", len(data))
+	h.logger.Printf("Successfully retrieved %d of %d records", len(page.Items), page.Total)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(data)
+	json.NewEncoder(w).Encode(page)
+}
+
+const (
+	// defaultPageSize is the limit of a listing that does not set one
+	defaultPageSize = 50
+	// maxPageSize is the largest limit a listing may set
+	maxPageSize = 1000
+)
+
+// dataQuery reads the listing parameters of GET /api/data: limit, offset,
+// cursor, filename, created_after, created_before, sort, order and
+// metadata. A cursor continues in its own sort order, so sort and order may
+// be left out alongside it.
+func dataQuery(r *http.Request) (storage.Query, error) {
+	get := r.URL.Query().Get
+	q := storage.Query{Limit: defaultPageSize, Filename: get("filename")}
+
+	if v := get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return q, fmt.Errorf("limit: must be a number from 1 to %d", maxPageSize)
+		}
+		q.Limit = limit
+	}
+
+	if v := get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return q, fmt.Errorf("offset: must be a number from 0")
+		}
+		q.Offset = offset
+	}
+
+	if v := get("cursor"); v != "" {
+		cursor, err := storage.DecodeCursor(v)
+		if err != nil {
+			return q, fmt.Errorf("cursor: %w", err)
+		}
+		q.After = cursor
+		q.Sort, q.Ascending = cursor.Sort, cursor.Ascending
+	}
+
+	if v := get("sort"); v != "" {
+		sort, err := storage.ParseSort(v)
+		if err != nil {
+			return q, fmt.Errorf("sort: %w", err)
+		}
+		q.Sort = sort
+	}
+
+	switch v := get("order"); v {
+	case "":
+	case "asc":
+		q.Ascending = true
+	case "desc":
+		q.Ascending = false
+	default:
+		return q, fmt.Errorf("order: unknown value %q (want asc or desc)", v)
+	}
+
+	var err error
+	if q.CreatedAfter, err = parseTime(get("created_after")); err != nil {
+		return q, fmt.Errorf("created_after: %w", err)
+	}
+	if q.CreatedBefore, err = parseTime(get("created_before")); err != nil {
+		return q, fmt.Errorf("created_before: %w", err)
+	}
+
+	if v := get("metadata"); v != "" {
+		metadata, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, fmt.Errorf("metadata: %w", err)
+		}
+		q.OmitData = metadata
+	}
+
+	return q, nil
+}
+
+// parseTime parses an RFC 3339 time or a date, which is midnight UTC. An
+// empty string is the zero time.
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q (want RFC 3339 or YYYY-MM-DD)", v)
+	}
+	return t, nil
 }
 
 // GetDataByID retrieves a specific CSV data record by ID
@@ -123,10 +748,15 @@ func (h *CSVHandler) GetDataByID(w http.ResponseWriter, r *http.Request) {
 
 	h.logger.Printf("Received get data by ID request: %d", id)
 
-	data, err := h.service.GetDataByID(id)
+	if wantsCSV(r) {
+		h.getDataByIDAsCSV(w, r, id)
+		return
+	}
+
+	data, err := h.service.GetDataByIDContext(r.Context(), id)
 	if err != nil {
 		h.logger.Printf("Failed to retrieve data for ID %d: %v", id, err)
-		http.Error(w, fmt.Sprintf("Failed to retrieve data: %v", err), http.StatusNotFound)
+		http.Error(w, fmt.Sprintf("Failed to retrieve data: %v", err), errorStatus(err, http.StatusNotFound))
 		return
 	}
 
@@ -136,3 +766,256 @@ func (h *CSVHandler) GetDataByID(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(data)
 }
+
+// defaultRowCount is the number of rows GetRows returns without a limit
+const defaultRowCount = 100
+
+// GetRows retrieves the rows of an upload saved with rows, from the row
+// numbered start, at most limit of them
+func (h *CSVHandler) GetRows(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.logger.Printf("Method not allowed: %s", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := idParam(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	start, limit := 1, defaultRowCount
+	get := r.URL.Query().Get
+	if v := get("start"); v != "" {
+		if start, err = strconv.Atoi(v); err != nil || start < 1 {
+			http.Error(w, "Invalid query: start: must be a number from 1", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxPageSize {
+			http.Error(w, fmt.Sprintf("Invalid query: limit: must be a number from 1 to %d", maxPageSize), http.StatusBadRequest)
+			return
+		}
+	}
+
+	h.logger.Printf("Received get rows request: ID %d, rows %d+%d", id, start, limit)
+
+	rows, err := h.service.GetRowsContext(r.Context(), id, start, limit)
+	if err != nil {
+		h.logger.Printf("Failed to retrieve rows for ID %d: %v", id, err)
+		http.Error(w, fmt.Sprintf("Failed to retrieve rows: %v", err), errorStatus(err, rowErrorStatus(err)))
+		return
+	}
+
+	h.logger.Printf("Successfully retrieved %d rows of record ID %d", len(rows), id)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(rows)
+}
+
+// GetRow retrieves a single row of an upload saved with rows by its number
+func (h *CSVHandler) GetRow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.logger.Printf("Method not allowed: %s", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := idParam(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	n, err := strconv.Atoi(r.URL.Query().Get("row"))
+	if err != nil || n < 1 {
+		http.Error(w, "Invalid query: row: must be a number from 1", http.StatusBadRequest)
+		return
+	}
+
+	h.logger.Printf("Received get row request: ID %d, row %d", id, n)
+
+	row, err := h.service.GetRowContext(r.Context(), id, n)
+	if err != nil {
+		h.logger.Printf("Failed to retrieve row %d of ID %d: %v", n, id, err)
+		http.Error(w, fmt.Sprintf("Failed to retrieve row: %v", err), errorStatus(err, rowErrorStatus(err)))
+		return
+	}
+
+	h.logger.Printf("Successfully retrieved row %d of record ID %d", n, id)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(row)
+}
+
+// idParam reads the required id query parameter of a stored upload.
+func idParam(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("id")
+	if v == "" {
+		return 0, fmt.Errorf("id: required")
+	}
+	id, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("id: must be a number")
+	}
+	return id, nil
+}
+
+// rowErrorStatus is 404 for a missing upload or row and 500 otherwise.
+func rowErrorStatus(err error) int {
+	if errors.Is(err, storage.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// getDataByIDAsCSV sends the data stored under id as a CSV download.
+func (h *CSVHandler) getDataByIDAsCSV(w http.ResponseWriter, r *http.Request, id int) {
+	opts, err := csvOptions(r.URL.Query().Get)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid CSV options: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var buf bytes.Buffer
+	filename, err := h.service.GetDataByIDAsCSVContext(r.Context(), id, &buf, opts)
+	if err != nil {
+		h.logger.Printf("Failed to export data for ID %d as CSV: %v", id, err)
+		http.Error(w, fmt.Sprintf("Failed to retrieve data: %v", err), errorStatus(err, http.StatusNotFound))
+		return
+	}
+
+	h.logger.Printf("Successfully exported record ID %d as CSV", id)
+	writeCSV(w, csvFilename(filename, fmt.Sprintf("data-%d", id)), buf.Bytes())
+}
+
+// ConvertJSONToCSV converts an uploaded JSON array of objects, or NDJSON, to
+// CSV. The JSON is read from the "file" form field of a multipart request,
+// or else from the request body.
+func (h *CSVHandler) ConvertJSONToCSV(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.logger.Printf("Method not allowed: %s", r.Method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.logger.Println("Received JSON to CSV request")
+
+	var body io.Reader = r.Body
+	filename := "data"
+	get := r.URL.Query().Get
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			h.logger.Printf("Failed to parse form: %v", err)
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			h.logger.Printf("Failed to get file from form: %v", err)
+			http.Error(w, "Failed to get file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body, filename, get = file, header.Filename, r.FormValue
+	}
+
+	opts, err := csvOptions(get)
+	if err != nil {
+		h.logger.Printf("Invalid CSV options: %v", err)
+		http.Error(w, fmt.Sprintf("Invalid CSV options: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var buf bytes.Buffer
+	result, err := h.service.ConvertJSONToCSVContext(r.Context(), body, &buf, opts)
+	if err != nil {
+		h.logger.Printf("Failed to convert JSON to CSV: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to convert JSON: %v", err), errorStatus(err, http.StatusUnprocessableEntity))
+		return
+	}
+
+	h.logger.Printf("Successfully converted %d JSON records to CSV", result.Rows)
+	writeCSV(w, csvFilename(filename, "data"), buf.Bytes())
+}
+
+// csvOptions reads the optional output parameters of a CSV download using
+// get, which is r.FormValue or, when the body is not a form, the query.
+func csvOptions(get func(string) string) (converter.CSVOptions, error) {
+	var opts converter.CSVOptions
+
+	if v := get("delimiter"); v != "" {
+		d, err := parseDialectChar(v)
+		if err != nil {
+			return opts, fmt.Errorf("delimiter: %w", err)
+		}
+		opts.Delimiter = d
+	}
+
+	if v := get("quote"); v != "" {
+		q, err := parseDialectChar(v)
+		if err != nil {
+			return opts, fmt.Errorf("quote: %w", err)
+		}
+		opts.Quote = q
+	}
+
+	if v := get("quote_all"); v != "" {
+		quoteAll, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("quote_all: %w", err)
+		}
+		opts.QuoteAll = quoteAll
+	}
+
+	opts.Separator = get("separator")
+
+	if v := get("columns"); v != "" {
+		names, err := parseColumnNames(v)
+		if err != nil {
+			return opts, fmt.Errorf("columns: %w", err)
+		}
+		opts.Columns = names
+	}
+
+	return opts, nil
+}
+
+// wantsCSV reports whether a stored record is requested as CSV, by the
+// format parameter or the Accept header.
+func wantsCSV(r *http.Request) bool {
+	if v := r.URL.Query().Get("format"); v != "" {
+		return strings.EqualFold(v, "csv")
+	}
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(part)
+			if err == nil && mediaType == "text/csv" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// csvFilename names a CSV download after name, or fallback if it is empty.
+func csvFilename(name, fallback string) string {
+	name = filepath.Base(name)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	if name == "" || name == "." || name == string(filepath.Separator) {
+		name = fallback
+	}
+	return name + ".csv"
+}
+
+// writeCSV sends data as a CSV attachment.
+func writeCSV(w http.ResponseWriter, filename string, data []byte) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
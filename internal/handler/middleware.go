@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// WithTimeout gives each request handled by next a context that expires
+// after timeout, so conversions and queries running longer are stopped.
+// Unlike http.TimeoutHandler it does not buffer responses, so streamed
+// output and trailers still work. A zero timeout returns next unchanged.
+func WithTimeout(next http.Handler, timeout time.Duration) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
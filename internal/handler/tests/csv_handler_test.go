@@ -2,17 +2,24 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/agileproject-gurpreet/csv2json/internal/handler"
 	"github.com/agileproject-gurpreet/csv2json/internal/service"
+	"github.com/agileproject-gurpreet/csv2json/internal/storage"
 )
 
 // TestUploadCSV_Success tests successful CSV file upload
@@ -415,3 +422,1098 @@ func TestUploadCSV_UTF8Characters(t *testing.T) {
 		t.Errorf("expected status 200, got %d", w.Code)
 	}
 }
+
+// newUploadRequest builds a multipart upload of content to target
+func newUploadRequest(t *testing.T, target, filename, content string) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(part, content)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, target, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+// TestUploadCSV_DetectedDialect tests that the detected dialect is reported
+func TestUploadCSV_DetectedDialect(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/upload", "eu.csv", "name;price\nfoo;1,5\nbar;2,25")
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("X-CSV-Delimiter"); got != ";" {
+		t.Errorf("expected delimiter ';', got %q", got)
+	}
+	if got := w.Header().Get("X-CSV-Header"); got != "true" {
+		t.Errorf("expected header true, got %q", got)
+	}
+
+	var result []map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to parse JSON response: %v", err)
+	}
+	if len(result) != 2 || result[0]["price"] != "1,5" {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+// TestUploadCSV_DialectOverride tests explicit delimiter and header parameters
+func TestUploadCSV_DialectOverride(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/upload?delimiter=tab&header=false", "dump.tsv", "a\tb\nc\td")
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("X-CSV-Delimiter"); got != "tab" {
+		t.Errorf("expected delimiter tab, got %q", got)
+	}
+
+	var result []map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to parse JSON response: %v", err)
+	}
+	if len(result) != 2 || result[0]["column_1"] != "a" {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+// TestUploadCSV_InvalidDialectOverride tests rejection of bad dialect parameters
+func TestUploadCSV_InvalidDialectOverride(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/upload?delimiter=;;", "test.csv", "a,b\n1,2")
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+// TestUploadCSV_InferTypes tests the infer_types and column_types parameters
+func TestUploadCSV_InferTypes(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/upload?infer_types=true&column_types=id:string", "test.csv", "id,age,active\n7,30,true")
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var result []map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("failed to parse JSON response: %v", err)
+	}
+	if result[0]["id"] != "7" || result[0]["age"] != float64(30) || result[0]["active"] != true {
+		t.Errorf("unexpected record: %v", result[0])
+	}
+}
+
+// TestUploadCSV_InvalidColumnTypes tests rejection of unknown column types
+func TestUploadCSV_InvalidColumnTypes(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/upload?column_types=id:decimal", "test.csv", "id\n7")
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+// TestUploadCSV_RaggedSkip tests the ragged parameter and its response headers
+func TestUploadCSV_RaggedSkip(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/upload?ragged=skip", "test.csv", "a,b\n1,2\n3\n4,5,6\n")
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("X-CSV-Ragged-Policy"); got != "skip" {
+		t.Errorf("expected policy skip, got %q", got)
+	}
+	if got := w.Header().Get("X-CSV-Ragged-Lines"); got != "3,4" {
+		t.Errorf("expected ragged lines 3,4, got %q", got)
+	}
+}
+
+// TestUploadCSV_HeaderNormalization tests header options and the reported mapping
+func TestUploadCSV_HeaderNormalization(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/upload?header_case=snake&trim_headers=true", "test.csv", " First Name ,First Name\nAlice,Bob")
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if want := `[{"first_name":"Alice","first_name_2":"Bob"}]`; w.Body.String() != want {
+		t.Errorf("expected %s, got %s", want, w.Body.String())
+	}
+
+	var mapping []map[string]interface{}
+	if err := json.Unmarshal([]byte(w.Header().Get("X-CSV-Header-Mapping")), &mapping); err != nil {
+		t.Fatalf("failed to parse header mapping: %v", err)
+	}
+	if len(mapping) != 2 || mapping[0]["original"] != " First Name " || mapping[1]["name"] != "first_name_2" {
+		t.Errorf("unexpected mapping: %v", mapping)
+	}
+}
+
+// TestUploadCSV_HeaderlessWithColumnNames tests caller-supplied column names as form fields
+func TestUploadCSV_HeaderlessWithColumnNames(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("header", "false")
+	writer.WriteField("columns", "sku,name")
+	part, err := writer.CreateFormFile("file", "feed.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(part, "A1,Widget\nB2,Gadget")
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if want := `[{"sku":"A1","name":"Widget"},{"sku":"B2","name":"Gadget"}]`; w.Body.String() != want {
+		t.Errorf("expected %s, got %s", want, w.Body.String())
+	}
+}
+
+// TestUploadCSV_Arrays tests the arrays parameter
+func TestUploadCSV_Arrays(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/upload?header=false&arrays=true", "feed.csv", "A1,Widget")
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	if want := `[["A1","Widget"]]`; w.Body.String() != want {
+		t.Errorf("expected %s, got %s", want, w.Body.String())
+	}
+}
+
+// TestUploadCSV_ExcelBOM tests that a UTF-8 BOM does not end up in the first header
+func TestUploadCSV_ExcelBOM(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/upload", "excel.csv", "\xef\xbb\xbfname,age\nAlice,30")
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	if want := `[{"name":"Alice","age":"30"}]`; w.Body.String() != want {
+		t.Errorf("expected %s, got %s", want, w.Body.String())
+	}
+	if got := w.Header().Get("X-CSV-Encoding"); got != "utf-8" {
+		t.Errorf("expected encoding utf-8, got %q", got)
+	}
+}
+
+// TestUploadCSV_Encoding tests the encoding parameter
+func TestUploadCSV_Encoding(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/upload?encoding=windows-1252", "legacy.csv", "name\nCaf\xe9")
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	if want := `[{"name":"Café"}]`; w.Body.String() != want {
+		t.Errorf("expected %s, got %s", want, w.Body.String())
+	}
+}
+
+// TestUploadCSV_ParseErrorLocation tests that malformed CSV is reported as 422 with its location
+func TestUploadCSV_ParseErrorLocation(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/upload", "broken.csv", "name,age\nAlice,30\nBob,3\"1\n")
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected Content-Type application/json, got %s", ct)
+	}
+
+	var body struct {
+		Error   string `json:"error"`
+		Line    int    `json:"line"`
+		Column  int    `json:"column"`
+		Snippet string `json:"snippet"`
+		Reason  string `json:"reason"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Line != 3 || body.Column != 6 || body.Snippet != `Bob,3"1` {
+		t.Errorf("unexpected location: %+v", body)
+	}
+	if body.Reason == "" || body.Error == "" {
+		t.Errorf("expected error and reason, got %+v", body)
+	}
+}
+
+// TestUploadCSV_ParseErrorLocationMidFile tests that malformed CSV well past
+// the first records is still reported as 422 with its location
+func TestUploadCSV_ParseErrorLocationMidFile(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	var csv strings.Builder
+	csv.WriteString("name,age\n")
+	for i := 2; i < 50; i++ {
+		fmt.Fprintf(&csv, "user-%d,%d\n", i, i)
+	}
+	csv.WriteString("Bob,3\"1\nAlice,30\n")
+
+	req := newUploadRequest(t, "/api/upload", "broken.csv", csv.String())
+	w := httptest.NewRecorder()
+	h.UploadCSV(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d", w.Code)
+	}
+	var body struct {
+		Line    int    `json:"line"`
+		Column  int    `json:"column"`
+		Snippet string `json:"snippet"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Line != 50 || body.Column != 6 || body.Snippet != `Bob,3"1` {
+		t.Errorf("unexpected location: %+v", body)
+	}
+}
+
+// TestUploadCSV_ColumnTypeErrorLocation tests that a value not matching its column type is located
+func TestUploadCSV_ColumnTypeErrorLocation(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/upload?column_types=age:integer", "test.csv", "name,age\nAlice,30\nBob,old\n")
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d", w.Code)
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body["line"] != float64(3) || body["column"] != float64(5) || body["snippet"] != "old" {
+		t.Errorf("unexpected location: %v", body)
+	}
+}
+
+// TestUploadCSV_Unflatten tests the unflatten and separator parameters
+func TestUploadCSV_Unflatten(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/upload?unflatten=true&separator=_", "nested.csv", "id,address_city,tags[0]\n1,Paris,a")
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	if want := `[{"id":"1","address":{"city":"Paris"},"tags":["a"]}]`; w.Body.String() != want {
+		t.Errorf("expected %s, got %s", want, w.Body.String())
+	}
+}
+
+// TestUploadCSV_UnflattenConflict tests that conflicting header paths are rejected with 422
+func TestUploadCSV_UnflattenConflict(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/upload?unflatten=true", "nested.csv", "a,a.b\n1,2")
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d", w.Code)
+	}
+
+	var body map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if want := `failed to convert CSV: header "a.b" conflicts with "a"`; body["error"] != want {
+		t.Errorf("expected error %q, got %q", want, body["error"])
+	}
+}
+
+// gatedRecorder holds the handler at its first body write until released,
+// passing the test a copy of what was written
+type gatedRecorder struct {
+	*httptest.ResponseRecorder
+	first   chan []byte
+	release chan struct{}
+	written bool
+}
+
+func newGatedRecorder() *gatedRecorder {
+	return &gatedRecorder{
+		ResponseRecorder: httptest.NewRecorder(),
+		first:            make(chan []byte),
+		release:          make(chan struct{}),
+	}
+}
+
+func (g *gatedRecorder) Write(p []byte) (int, error) {
+	if !g.written {
+		g.written = true
+		g.first <- append([]byte(nil), p...)
+		<-g.release
+	}
+	return g.ResponseRecorder.Write(p)
+}
+
+// TestUploadCSV_NDJSONAccept tests that Accept: application/x-ndjson streams one object per line
+func TestUploadCSV_NDJSONAccept(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	var csv strings.Builder
+	csv.WriteString("id,name\n")
+	const rows = 50000
+	for i := 1; i <= rows; i++ {
+		fmt.Fprintf(&csv, "%d,user-%d\n", i, i)
+	}
+
+	req := newUploadRequest(t, "/api/upload", "big.csv", csv.String())
+	req.Header.Set("Accept", "application/x-ndjson; q=1.0, application/json; q=0.5")
+	w := newGatedRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		h.UploadCSV(w, req)
+		close(done)
+	}()
+
+	// The handler is held at its first write, so the rows converted so far
+	// are the ones in it
+	select {
+	case first := <-w.first:
+		const maxLines = 100
+		if n := bytes.Count(first, []byte{'\n'}); n == 0 || n > maxLines {
+			t.Errorf("expected the first write to hold 1 to %d lines, got %d", maxLines, n)
+		}
+	case <-done:
+		t.Fatal("expected output while the upload was being converted")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the first write")
+	}
+	close(w.release)
+	<-done
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("expected Content-Type application/x-ndjson, got %s", ct)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if len(lines) != rows {
+		t.Fatalf("expected %d lines, got %d", rows, len(lines))
+	}
+	if want := `{"id":"50000","name":"user-50000"}`; lines[rows-1] != want {
+		t.Errorf("expected last line %s, got %s", want, lines[rows-1])
+	}
+
+	trailer := w.Result().Trailer
+	if got := trailer.Get("X-CSV-Delimiter"); got != "," {
+		t.Errorf("expected delimiter trailer \",\", got %q", got)
+	}
+	if got := trailer.Get("X-CSV-Error"); got != "" {
+		t.Errorf("expected no error trailer, got %q", got)
+	}
+}
+
+// TestUploadCSV_NDJSONFormatParameter tests the format parameter
+func TestUploadCSV_NDJSONFormatParameter(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/upload?format=ndjson&infer_types=true", "test.csv", "name,age\nAlice,30\nBob,25")
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	if want := "{\"name\":\"Alice\",\"age\":30}\n{\"name\":\"Bob\",\"age\":25}\n"; w.Body.String() != want {
+		t.Errorf("expected %q, got %q", want, w.Body.String())
+	}
+}
+
+// TestUploadCSV_NDJSONErrors tests errors before and after streaming has started
+func TestUploadCSV_NDJSONErrors(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	// The first row is broken, so nothing has been sent yet
+	req := newUploadRequest(t, "/api/upload?format=ndjson", "test.csv", "name,age\nAlice\n")
+	w := httptest.NewRecorder()
+	h.UploadCSV(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422, got %d", w.Code)
+	}
+
+	// A later row is broken after the first one was streamed
+	req = newUploadRequest(t, "/api/upload?format=ndjson", "test.csv", "name,age\nAlice,30\nBob\n")
+	w = httptest.NewRecorder()
+	h.UploadCSV(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+	if want := "{\"name\":\"Alice\",\"age\":\"30\"}\n"; w.Body.String() != want {
+		t.Errorf("expected %q, got %q", want, w.Body.String())
+	}
+	if got := w.Result().Trailer.Get("X-CSV-Error"); !strings.Contains(got, "line 3") {
+		t.Errorf("expected error trailer for line 3, got %q", got)
+	}
+}
+
+// TestUploadCSV_JSONLateRowError tests that a JSON array upload with a row
+// broken after the first record gets an error response, not a partial array
+func TestUploadCSV_JSONLateRowError(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/upload", "test.csv", "name,age\nAlice,30\nBob,3\"1\n")
+	w := httptest.NewRecorder()
+	h.UploadCSV(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected Content-Type application/json, got %s", ct)
+	}
+	var body struct {
+		Error string `json:"error"`
+		Line  int    `json:"line"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Line != 3 || body.Error == "" {
+		t.Errorf("expected an error on line 3, got %+v", body)
+	}
+	if got := w.Result().Trailer.Get("X-CSV-Error"); got != "" {
+		t.Errorf("expected no error trailer, got %q", got)
+	}
+}
+
+// TestUploadCSV_InvalidFormat tests that an unknown format is rejected
+func TestUploadCSV_InvalidFormat(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/upload?format=xml", "test.csv", "name\nAlice")
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+// TestConvertJSONToCSV_Body tests converting a raw JSON request body to CSV
+func TestConvertJSONToCSV_Body(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	body := strings.NewReader(`[{"name":"Alice","address":{"city":"Paris"}},{"name":"Bob","age":25}]`)
+	req := httptest.NewRequest(http.MethodPost, "/api/json2csv?delimiter=%3B", body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	h.ConvertJSONToCSV(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("expected Content-Type text/csv, got %s", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != "attachment; filename=data.csv" {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+	if want := "name;address.city;age\nAlice;Paris;\nBob;;25\n"; w.Body.String() != want {
+		t.Errorf("expected %q, got %q", want, w.Body.String())
+	}
+}
+
+// TestConvertJSONToCSV_Multipart tests converting an uploaded NDJSON file to CSV
+func TestConvertJSONToCSV_Multipart(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/json2csv?quote_all=true", "events.ndjson", "{\"id\":1}\n{\"id\":2}\n")
+	w := httptest.NewRecorder()
+
+	h.ConvertJSONToCSV(w, req)
+
+	if want := "\"id\"\n\"1\"\n\"2\"\n"; w.Body.String() != want {
+		t.Errorf("expected %q, got %q", want, w.Body.String())
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != "attachment; filename=events.csv" {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+}
+
+// TestConvertJSONToCSV_InvalidJSON tests that malformed JSON is rejected
+func TestConvertJSONToCSV_InvalidJSON(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/json2csv", strings.NewReader(`[{"a":1},`))
+	w := httptest.NewRecorder()
+
+	h.ConvertJSONToCSV(w, req)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422, got %d", w.Code)
+	}
+}
+
+// TestConvertJSONToCSV_MethodNotAllowed tests that only POST is accepted
+func TestConvertJSONToCSV_MethodNotAllowed(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/json2csv", nil)
+	w := httptest.NewRecorder()
+
+	h.ConvertJSONToCSV(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", w.Code)
+	}
+}
+
+// TestGetDataByID_CSVNoDatabase tests the CSV download without a database
+func TestGetDataByID_CSVNoDatabase(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/data/id?id=1&format=csv", nil)
+	w := httptest.NewRecorder()
+
+	h.GetDataByID(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "database not initialized") {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+}
+
+// TestGetData_MemoryStore tests that uploads are listed and fetched by ID from the store
+func TestGetData_MemoryStore(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(storage.NewMemoryStore())
+	h := handler.NewCSVHandler(svc, logger)
+
+	w := httptest.NewRecorder()
+	h.UploadCSV(w, newUploadRequest(t, "/api/upload", "people.csv", "name,age\nAlice,30\n"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("upload failed with %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.GetAllData(w, httptest.NewRequest(http.MethodGet, "/api/data", nil))
+	var all service.DataPage
+	if err := json.Unmarshal(w.Body.Bytes(), &all); err != nil {
+		t.Fatalf("failed to parse JSON response: %v", err)
+	}
+	if w.Code != http.StatusOK || all.Total != 1 || len(all.Items) != 1 || all.Items[0]["filename"] != "people.csv" {
+		t.Fatalf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.GetDataByID(w, httptest.NewRequest(http.MethodGet, "/api/data/id?id=1", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"data":[{"name":"Alice","age":"30"}]`) {
+		t.Errorf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.GetDataByID(w, httptest.NewRequest(http.MethodGet, "/api/data/id?id=2", nil))
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "record not found") {
+		t.Errorf("expected 404 for a missing ID, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// TestGetAllData_Paginated tests paging through filtered uploads by cursor
+func TestGetAllData_Paginated(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(storage.NewMemoryStore())
+	h := handler.NewCSVHandler(svc, logger)
+
+	for _, name := range []string{"a.csv", "b.csv", "a.csv", "a.csv", "c.csv"} {
+		w := httptest.NewRecorder()
+		h.UploadCSV(w, newUploadRequest(t, "/api/upload", name, "name\nAlice\n"))
+		if w.Code != http.StatusOK {
+			t.Fatalf("upload failed with %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	var ids []interface{}
+	target := "/api/data?filename=a.csv&sort=id&order=asc&limit=2&metadata=true"
+	for pages := 0; target != ""; pages++ {
+		if pages == 3 {
+			t.Fatal("expected the listing to end")
+		}
+
+		w := httptest.NewRecorder()
+		h.GetAllData(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var page service.DataPage
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatalf("failed to parse JSON response: %v", err)
+		}
+		if page.Total != 3 {
+			t.Errorf("expected a total of 3, got %d", page.Total)
+		}
+		for _, item := range page.Items {
+			if _, ok := item["data"]; ok {
+				t.Errorf("expected no data in a metadata listing, got %v", item)
+			}
+			ids = append(ids, item["id"])
+		}
+
+		// The cursor carries the sort order; the filters are sent again
+		target = ""
+		if page.NextCursor != "" {
+			target = "/api/data?filename=a.csv&limit=2&metadata=true&cursor=" + page.NextCursor
+		}
+	}
+
+	if want := []interface{}{1.0, 3.0, 4.0}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("expected ids %v, got %v", want, ids)
+	}
+}
+
+// TestGetAllData_InvalidParams tests that bad listing parameters are rejected
+func TestGetAllData_InvalidParams(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(storage.NewMemoryStore())
+	h := handler.NewCSVHandler(svc, logger)
+
+	cursor := storage.Query{Sort: storage.SortID}.CursorAfter(&storage.Upload{ID: 1}).Encode()
+	tests := []string{
+		"limit=0",
+		"limit=1001",
+		"limit=ten",
+		"offset=-1",
+		"sort=size",
+		"order=up",
+		"created_after=yesterday",
+		"created_before=2024-13-01",
+		"metadata=maybe",
+		"cursor=not-a-cursor",
+		"cursor=" + cursor + "&sort=filename",
+	}
+
+	for _, params := range tests {
+		w := httptest.NewRecorder()
+		h.GetAllData(w, httptest.NewRequest(http.MethodGet, "/api/data?"+params, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d: %s", params, w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.GetAllData(w, httptest.NewRequest(http.MethodGet, "/api/data?created_after=2024-03-01&created_before=2024-03-02T12:00:00Z", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"items":[]`) {
+		t.Errorf("expected an empty page, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// TestGetRows tests reading a range of rows and a single row of an upload
+func TestGetRows(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(storage.NewMemoryStore())
+	svc.SetStoreRows(true)
+	h := handler.NewCSVHandler(svc, logger)
+
+	w := httptest.NewRecorder()
+	h.UploadCSV(w, newUploadRequest(t, "/api/upload", "people.csv", "name,age\nAlice,30\nBob,25\nCarol,41\n"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("upload failed with %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.GetRows(w, httptest.NewRequest(http.MethodGet, "/api/data/rows?id=1&start=2&limit=1", nil))
+	want := `[{"data":{"name":"Bob","age":"25"},"row_number":2,"upload_id":1}]`
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != want {
+		t.Errorf("expected %s, got %d: %s", want, w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.GetRow(w, httptest.NewRequest(http.MethodGet, "/api/data/row?id=1&row=3", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"data":{"name":"Carol","age":"41"}`) {
+		t.Errorf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name   string
+		get    func(http.ResponseWriter, *http.Request)
+		target string
+		status int
+	}{
+		{"missing row", h.GetRow, "/api/data/row?id=1&row=4", http.StatusNotFound},
+		{"missing upload", h.GetRows, "/api/data/rows?id=2", http.StatusNotFound},
+		{"no id", h.GetRows, "/api/data/rows", http.StatusBadRequest},
+		{"bad start", h.GetRows, "/api/data/rows?id=1&start=0", http.StatusBadRequest},
+		{"bad limit", h.GetRows, "/api/data/rows?id=1&limit=1001", http.StatusBadRequest},
+		{"bad row", h.GetRow, "/api/data/row?id=1&row=first", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.get(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.status, w.Code, w.Body.String())
+		}
+	}
+}
+
+// TestInferSchema tests schema inference from an uploaded CSV
+func TestInferSchema(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := newUploadRequest(t, "/api/schema", "people.csv", "id,name\n1,Alice\n2,Bob\n")
+	w := httptest.NewRecorder()
+
+	h.InferSchema(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/schema+json" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+
+	var doc struct {
+		Schema string `json:"$schema"`
+		Items  struct {
+			Properties map[string]struct {
+				Type interface{} `json:"type"`
+			} `json:"properties"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Schema != "https://json-schema.org/draft/2020-12/schema" {
+		t.Errorf("unexpected $schema %q", doc.Schema)
+	}
+	if typ := doc.Items.Properties["id"].Type; typ != "integer" {
+		t.Errorf("expected id to be an integer, got %v", typ)
+	}
+}
+
+// TestInferSchema_MethodNotAllowed tests that only POST is accepted
+func TestInferSchema_MethodNotAllowed(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/schema", nil)
+	w := httptest.NewRecorder()
+
+	h.InferSchema(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", w.Code)
+	}
+}
+
+// TestUploadCSV_ValidationReject tests that an upload with violations is rejected
+func TestUploadCSV_ValidationReject(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	spec := url.QueryEscape(`{"columns": {"email": {"required": true}}}`)
+	for _, format := range []string{"json", "ndjson"} {
+		req := newUploadRequest(t, "/api/upload?format="+format+"&validation="+spec, "users.csv", "name,email\nAlice,a@example.com\nBob,\n")
+		w := httptest.NewRecorder()
+
+		h.UploadCSV(w, req)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: expected status 422, got %d: %s", format, w.Code, w.Body.String())
+		}
+		var body struct {
+			Error       string `json:"error"`
+			InvalidRows int    `json:"invalid_rows"`
+			Violations  []struct {
+				Row   int    `json:"row"`
+				Line  int    `json:"line"`
+				Field string `json:"field"`
+				Rule  string `json:"rule"`
+			} `json:"violations"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.InvalidRows != 1 || len(body.Violations) != 1 {
+			t.Fatalf("%s: unexpected body %s", format, w.Body.String())
+		}
+		if v := body.Violations[0]; v.Row != 2 || v.Line != 3 || v.Field != "email" || v.Rule != "required" {
+			t.Errorf("%s: unexpected violation %+v", format, v)
+		}
+	}
+}
+
+// TestUploadCSV_ValidationReport tests that report mode returns the data and the violations
+func TestUploadCSV_ValidationReport(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	// The spec is sent as a file part
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "scores.csv")
+	io.WriteString(part, "name,score\nAlice,10\nBob,11\nCarol,12\n")
+	part, _ = writer.CreateFormFile("validation", "rules.json")
+	io.WriteString(part, `{"columns": {"score": {"max": 10}}}`)
+	writer.WriteField("validation_mode", "report")
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if n := w.Header().Get("X-CSV-Invalid-Rows"); n != "2" {
+		t.Errorf("expected 2 invalid rows, got %q", n)
+	}
+	if n := w.Header().Get("X-CSV-Violation-Count"); n != "2" {
+		t.Errorf("expected 2 violations, got %q", n)
+	}
+
+	var result struct {
+		Data       []map[string]interface{} `json:"data"`
+		Validation struct {
+			Total      int `json:"total"`
+			Violations []struct {
+				Row  int    `json:"row"`
+				Rule string `json:"rule"`
+			} `json:"violations"`
+		} `json:"validation"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(result.Data) != 3 || result.Data[2]["name"] != "Carol" {
+		t.Errorf("expected all records, got %v", result.Data)
+	}
+	violations := result.Validation.Violations
+	if result.Validation.Total != 2 || len(violations) != 2 || violations[1].Row != 3 || violations[1].Rule != "max" {
+		t.Errorf("unexpected report %+v", result.Validation)
+	}
+}
+
+// TestUploadCSV_ValidationReportNDJSON tests that streamed NDJSON ends with
+// the full validation report in report mode
+func TestUploadCSV_ValidationReportNDJSON(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	var csv strings.Builder
+	csv.WriteString("name,score\n")
+	for i := 1; i <= 30; i++ {
+		fmt.Fprintf(&csv, "user-%d,%d\n", i, 10+i)
+	}
+	spec := url.QueryEscape(`{"columns": {"score": {"max": 10}}}`)
+	req := newUploadRequest(t, "/api/upload?format=ndjson&validation_mode=report&validation="+spec, "scores.csv", csv.String())
+	w := httptest.NewRecorder()
+
+	h.UploadCSV(w, req)
+
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if len(lines) != 31 {
+		t.Fatalf("expected 30 records and the report, got %d lines", len(lines))
+	}
+	var last struct {
+		Validation struct {
+			Total      int               `json:"total"`
+			Violations []json.RawMessage `json:"violations"`
+		} `json:"validation"`
+	}
+	if err := json.Unmarshal([]byte(lines[30]), &last); err != nil {
+		t.Fatalf("Failed to decode report line: %v", err)
+	}
+	if last.Validation.Total != 30 || len(last.Validation.Violations) != 30 {
+		t.Errorf("expected all 30 violations, got %s", lines[30])
+	}
+	if n := w.Result().Trailer.Get("X-CSV-Violation-Count"); n != "30" {
+		t.Errorf("expected violation count trailer 30, got %q", n)
+	}
+}
+
+// TestUploadCSV_InvalidValidationSpec tests that a malformed spec is a bad request
+func TestUploadCSV_InvalidValidationSpec(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	for _, query := range []string{
+		"validation=" + url.QueryEscape(`{"oneOf": []}`),
+		"validation_mode=warn",
+	} {
+		req := newUploadRequest(t, "/api/upload?"+query, "data.csv", "a\n1\n")
+		w := httptest.NewRecorder()
+
+		h.UploadCSV(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+// TestUploadCSV_Cancelled tests that an upload whose request context is done is not converted
+func TestUploadCSV_Cancelled(t *testing.T) {
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	svc := service.NewConversionService(nil)
+	h := handler.NewCSVHandler(svc, logger)
+
+	for _, format := range []string{"json", "ndjson"} {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req := newUploadRequest(t, "/api/upload?format="+format, "data.csv", "name\nAlice\nBob\n").WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		h.UploadCSV(w, req)
+
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: expected status 503, got %d", format, w.Code)
+		}
+		if strings.Contains(w.Body.String(), "Alice") {
+			t.Errorf("%s: expected no records, got %s", format, w.Body.String())
+		}
+	}
+}
+
+// TestWithTimeout tests that the middleware gives requests a deadline
+func TestWithTimeout(t *testing.T) {
+	var deadline time.Time
+	var ok bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+	})
+
+	handler.WithTimeout(next, time.Minute).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/data", nil))
+	if !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("expected a deadline within a minute, got %v (%v)", deadline, ok)
+	}
+
+	handler.WithTimeout(next, 0).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/data", nil))
+	if ok {
+		t.Error("expected no deadline with a zero timeout")
+	}
+}
@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/infer"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		value string
+		want  infer.Type
+	}{
+		{"", infer.Null},
+		{"true", infer.Boolean},
+		{"FALSE", infer.Boolean},
+		{"30", infer.Integer},
+		{"-7", infer.Integer},
+		{"19.99", infer.Number},
+		{"1e5", infer.Number},
+		{"02134", infer.String},
+		{"+5", infer.String},
+		{".5", infer.String},
+		{"NaN", infer.String},
+		{"99999999999999999999", infer.String},
+		{"2026-01-28T10:30:00Z", infer.Timestamp},
+		{"2026-01-28", infer.String},
+		{"Alice", infer.String},
+	}
+
+	for _, tt := range tests {
+		if got := infer.Detect(tt.value); got != tt.want {
+			t.Errorf("Detect(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		a, b, want infer.Type
+	}{
+		{infer.Null, infer.Integer, infer.Integer},
+		{infer.Integer, infer.Integer, infer.Integer},
+		{infer.Integer, infer.Number, infer.Number},
+		{infer.Boolean, infer.Null, infer.Boolean},
+		{infer.Boolean, infer.Integer, infer.String},
+		{infer.Timestamp, infer.String, infer.String},
+	}
+
+	for _, tt := range tests {
+		if got := infer.Merge(tt.a, tt.b); got != tt.want {
+			t.Errorf("Merge(%s, %s) = %s, want %s", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	v, err := infer.Convert("19.99", infer.Number)
+	if err != nil || v != json.Number("19.99") {
+		t.Errorf("expected json.Number 19.99, got %v (%v)", v, err)
+	}
+
+	v, err = infer.Convert("True", infer.Boolean)
+	if err != nil || v != true {
+		t.Errorf("expected true, got %v (%v)", v, err)
+	}
+
+	v, err = infer.Convert("2026-01-28T10:30:00.50+01:00", infer.Timestamp)
+	if err != nil || v != "2026-01-28T10:30:00.50+01:00" {
+		t.Errorf("expected the timestamp unchanged, got %v (%v)", v, err)
+	}
+
+	v, err = infer.Convert("", infer.Integer)
+	if err != nil || v != nil {
+		t.Errorf("expected nil, got %v (%v)", v, err)
+	}
+
+	v, err = infer.Convert("", infer.String)
+	if err != nil || v != "" {
+		t.Errorf("expected empty string, got %v (%v)", v, err)
+	}
+
+	if _, err := infer.Convert("abc", infer.Integer); err == nil {
+		t.Error("expected error converting abc to integer")
+	}
+}
+
+func TestParseType(t *testing.T) {
+	if got, err := infer.ParseType(" Integer "); err != nil || got != infer.Integer {
+		t.Errorf("expected integer, got %s (%v)", got, err)
+	}
+	if _, err := infer.ParseType("decimal"); err == nil {
+		t.Error("expected error for unknown type")
+	}
+}
@@ -0,0 +1,160 @@
+package infer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type is the JSON type a CSV column is converted to. Names follow JSON
+// Schema, plus timestamp for RFC 3339 date-times.
+type Type string
+
+const (
+	Null      Type = "null"
+	Boolean   Type = "boolean"
+	Integer   Type = "integer"
+	Number    Type = "number"
+	Timestamp Type = "timestamp"
+	String    Type = "string"
+)
+
+// ParseType returns the Type named by s.
+func ParseType(s string) (Type, error) {
+	switch t := Type(strings.ToLower(strings.TrimSpace(s))); t {
+	case Null, Boolean, Integer, Number, Timestamp, String:
+		return t, nil
+	}
+	return "", fmt.Errorf("unknown type %q", s)
+}
+
+// Detect returns the narrowest Type that can represent value. Empty values
+// are Null.
+func Detect(value string) Type {
+	switch {
+	case value == "":
+		return Null
+	case strings.EqualFold(value, "true") || strings.EqualFold(value, "false"):
+		return Boolean
+	case isJSONNumber(value):
+		if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return Integer
+		}
+		if strings.ContainsAny(value, ".eE") {
+			return Number
+		}
+		// An integer too large for int64
+		return String
+	}
+	if _, err := time.Parse(time.RFC3339, value); err == nil {
+		return Timestamp
+	}
+	return String
+}
+
+// Merge returns the narrowest Type that can represent values of both a and
+// b. Null merges into anything and integers widen to numbers; any other mix
+// falls back to String.
+func Merge(a, b Type) Type {
+	switch {
+	case a == b:
+		return a
+	case a == Null:
+		return b
+	case b == Null:
+		return a
+	case (a == Integer && b == Number) || (a == Number && b == Integer):
+		return Number
+	}
+	return String
+}
+
+// Convert converts value to t. Empty values become nil for every type but
+// String. Numbers are returned as json.Number and timestamps as the original
+// string, so their text is preserved.
+func Convert(value string, t Type) (interface{}, error) {
+	if t == String {
+		return value, nil
+	}
+	if value == "" {
+		return nil, nil
+	}
+
+	switch t {
+	case Null:
+		return nil, fmt.Errorf("cannot convert %q to null", value)
+	case Boolean:
+		if strings.EqualFold(value, "true") {
+			return true, nil
+		}
+		if strings.EqualFold(value, "false") {
+			return false, nil
+		}
+	case Integer:
+		if isJSONNumber(value) {
+			if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+				return json.Number(value), nil
+			}
+		}
+	case Number:
+		if isJSONNumber(value) {
+			return json.Number(value), nil
+		}
+	case Timestamp:
+		if _, err := time.Parse(time.RFC3339, value); err == nil {
+			return value, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %q to %s", value, t)
+}
+
+// isJSONNumber reports whether s is a number literal as defined by JSON:
+// no leading '+', no leading zeros, digits on both sides of a decimal point.
+func isJSONNumber(s string) bool {
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+
+	switch {
+	case i < len(s) && s[i] == '0':
+		i++
+	case i < len(s) && s[i] >= '1' && s[i] <= '9':
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+	default:
+		return false
+	}
+
+	if i < len(s) && s[i] == '.' {
+		i++
+		if i >= len(s) || !isDigit(s[i]) {
+			return false
+		}
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+	}
+
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if i >= len(s) || !isDigit(s[i]) {
+			return false
+		}
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+	}
+
+	return i == len(s)
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
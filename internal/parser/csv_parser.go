@@ -1,34 +1,450 @@
 package parser
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/csv"
+	"fmt"
 	"io"
+	"strings"
 )
 
-func ParseCSV(r io.Reader) ([]map[string]string, error) {
-	reader := csv.NewReader(r)
+// Options controls how CSV input is read. The zero value detects the
+// dialect from the start of the input.
+type Options struct {
+	// Delimiter is the field delimiter. Zero means detect it.
+	Delimiter rune
+	// Quote is the quote character. Zero means detect it.
+	Quote rune
+	// Header controls whether the first row is a header row.
+	Header HeaderMode
+	// SniffSize is the most bytes inspected for detection; detection uses
+	// what has arrived once the first line is complete.
+	// Zero means DefaultSniffSize.
+	SniffSize int
+	// Ragged controls rows with more or fewer fields than the header row.
+	Ragged RaggedPolicy
+	// TrimHeaders trims surrounding whitespace from header names.
+	TrimHeaders bool
+	// HeaderCase converts header names to snake_case or camelCase.
+	HeaderCase HeaderCase
+	// Encoding is the source encoding, transcoded to UTF-8 before parsing.
+	// Empty means UTF-8, or UTF-16 when the input starts with its byte
+	// order mark. See ParseEncoding for the supported names.
+	Encoding string
+	// ColumnNames names the columns instead of the header row, which is
+	// still skipped if present. Rows are matched against these names under
+	// the Ragged policy.
+	ColumnNames []string
+	// Comment starts lines that are skipped, such as '#'. Zero means none.
+	Comment rune
+	// LazyQuotes accepts a quote inside an unquoted field, and a lone quote
+	// inside a quoted one, instead of reporting a ParseError.
+	LazyQuotes bool
+	// TrimSpace removes leading and trailing whitespace from every field,
+	// quoted or not, and from header names.
+	TrimSpace bool
+}
 
-	headers, err := reader.Read()
+// Validate reports options that cannot be used, as NewReaderWithOptions
+// would on the first read.
+func (o Options) Validate() error {
+	for _, c := range []struct {
+		name string
+		r    rune
+	}{{"delimiter", o.Delimiter}, {"quote", o.Quote}} {
+		if c.r == '\r' || c.r == '\n' || c.r >= 0x80 {
+			return fmt.Errorf("invalid %s %q", c.name, c.r)
+		}
+	}
+	if o.Delimiter != 0 && o.Delimiter == o.Quote {
+		return fmt.Errorf("delimiter and quote must differ")
+	}
+	// A detected quote could be '"' as well
+	if o.Delimiter == '"' && o.Quote == 0 {
+		return fmt.Errorf("delimiter %q needs a different quote to be set", o.Delimiter)
+	}
+	if o.Comment != 0 {
+		if o.Comment == '\r' || o.Comment == '\n' || o.Comment >= 0x80 || o.Comment == '"' {
+			return fmt.Errorf("invalid comment %q", o.Comment)
+		}
+		if o.Comment == o.Delimiter || o.Comment == o.Quote {
+			return fmt.Errorf("comment must differ from delimiter and quote")
+		}
+	}
+	if o.SniffSize < 0 {
+		return fmt.Errorf("invalid sniff size %d", o.SniffSize)
+	}
+	if _, ok := raggedPolicyNames[o.Ragged]; !ok {
+		return fmt.Errorf("invalid ragged row policy %d", int(o.Ragged))
+	}
+	if o.Encoding != "" {
+		if _, err := ParseEncoding(o.Encoding); err != nil {
+			return err
+		}
+	}
+	if o.ColumnNames != nil && len(o.ColumnNames) == 0 {
+		return fmt.Errorf("column names must not be empty")
+	}
+	if o.Ragged == RaggedExtra {
+		for _, name := range o.ColumnNames {
+			if name == ExtraField {
+				return fmt.Errorf("column %q clashes with the surplus fields of the extra ragged row policy", name)
+			}
+		}
+	}
+	if _, ok := headerCaseNames[o.HeaderCase]; !ok {
+		return fmt.Errorf("invalid header case %d", int(o.HeaderCase))
+	}
+	return nil
+}
+
+// Reader streams CSV records one at a time, named by the header row.
+//
+// Typical use:
+//
+//	r := parser.NewReader(file)
+//	for r.Next() {
+//		record := r.Record()
+//		...
+//	}
+//	if err := r.Err(); err != nil {
+//		...
+//	}
+type Reader struct {
+	ctx      context.Context
+	src      io.Reader
+	opts     Options
+	ready    bool
+	csv      *csv.Reader
+	tail     *tailReader
+	encoding string
+	dialect  Dialect
+	headers  []string
+	raw      []string
+	pending  []string
+	record   Record
+	fields   int
+	extra    []string
+	ragged   []int
+	err      error
+}
+
+// NewReader returns a Reader that reads CSV from r, detecting its dialect.
+func NewReader(r io.Reader) *Reader {
+	return NewReaderWithOptions(r, Options{})
+}
+
+// NewReaderWithOptions returns a Reader that reads CSV from r using opts.
+// The dialect is detected, and the header row consumed, on the first call
+// to Dialect, Headers or Next.
+func NewReaderWithOptions(r io.Reader, opts Options) *Reader {
+	return NewReaderContext(context.Background(), r, opts)
+}
+
+// NewReaderContext is NewReaderWithOptions with a context. Once ctx is done,
+// Next returns false and Err returns ctx.Err(), so a cancelled caller stops
+// parsing at the next record.
+func NewReaderContext(ctx context.Context, r io.Reader, opts Options) *Reader {
+	return &Reader{
+		ctx:  ctx,
+		src:  r,
+		opts: opts,
+	}
+}
+
+// init detects the dialect and sets up the underlying csv.Reader.
+func (r *Reader) init() {
+	if r.ready {
+		return
+	}
+	r.ready = true
+
+	if err := r.ctx.Err(); err != nil {
+		r.err = err
+		return
+	}
+	if err := r.opts.Validate(); err != nil {
+		r.err = err
+		return
+	}
+
+	encoding := r.opts.Encoding
+	if encoding != "" {
+		encoding, _ = ParseEncoding(encoding)
+	}
+	decoded, encoding, err := newDecoder(r.src, encoding)
 	if err != nil {
-		return nil, err
+		r.err = err
+		return
 	}
+	r.encoding = encoding
 
-	var records []map[string]string
+	size := r.opts.SniffSize
+	if size == 0 {
+		size = DefaultSniffSize
+	}
+	// The tail sits below the sniffing buffer, so it must outlast it
+	r.tail = newTailReader(decoded, size+tailSize)
+	var src io.Reader = r.tail
+
+	if r.opts.Delimiter != 0 && r.opts.Quote != 0 && r.opts.Header != HeaderAuto {
+		// Fully specified; nothing to detect
+		r.dialect = sniff(nil, true, r.opts)
+	} else {
+		buffered := bufio.NewReaderSize(src, size)
+		sample, err := peekSample(buffered)
+		if err != nil && err != io.EOF {
+			r.err = r.locate(err)
+			return
+		}
+		r.dialect = sniff(sample, err == io.EOF, r.opts)
+		src = buffered
+	}
+
+	r.csv = csv.NewReader(newQuoteReader(src, r.dialect.Quote))
+	r.csv.Comma = r.dialect.Delimiter
+	if r.csv.Comma == '"' {
+		// quoteReader exchanged it with the quote
+		r.csv.Comma = r.dialect.Quote
+	}
+	r.csv.Comment = r.opts.Comment
+	r.csv.LazyQuotes = r.opts.LazyQuotes
+	r.csv.TrimLeadingSpace = r.opts.TrimSpace
+	r.csv.FieldsPerRecord = -1
+}
+
+// peekSample returns the input b has buffered for detection, without
+// consuming it. Rather than waiting for a full buffer, it returns as soon as
+// a whole line has arrived, so input written a little at a time is parsed
+// as it comes; io.EOF means the sample is the whole input.
+func peekSample(b *bufio.Reader) ([]byte, error) {
+	n := 1
+	for {
+		// Blocks until at least n bytes are buffered
+		if _, err := b.Peek(n); err != nil {
+			sample, _ := b.Peek(b.Buffered())
+			return sample, err
+		}
+		sample, _ := b.Peek(b.Buffered())
+		if bytes.IndexByte(sample, '\n') >= 0 || len(sample) == b.Size() {
+			return sample, nil
+		}
+		n = len(sample) + 1
+	}
+}
+
+// Encoding returns the encoding the input was decoded from.
+func (r *Reader) Encoding() string {
+	r.init()
+	return r.encoding
+}
+
+// Dialect returns the dialect used to read the input.
+func (r *Reader) Dialect() Dialect {
+	r.init()
+	return r.dialect
+}
+
+// Headers returns the record names, reading the header row from the input
+// if it has not been read yet. Names are normalised so they are unique and
+// non-blank; see HeaderMapping. Without a header row, names column_1 to
+// column_N are generated from the width of the first row. An empty input
+// returns io.EOF. Options.ColumnNames replaces both.
+func (r *Reader) Headers() ([]string, error) {
+	r.init()
+	if r.headers != nil || r.err != nil {
+		return r.headers, r.err
+	}
+
+	first, err := r.read()
+	if err != nil {
+		r.err = r.locate(err)
+		return nil, r.err
+	}
+
+	if r.dialect.HasHeader {
+		r.raw = first
+	} else {
+		r.raw = make([]string, len(first))
+		r.pending = first
+	}
+	if r.opts.ColumnNames != nil {
+		r.raw = append([]string(nil), r.opts.ColumnNames...)
+	}
+	headers := normalizeHeaders(r.raw, r.opts)
+	if err := r.checkExtraField(headers); err != nil {
+		r.err = err
+		return nil, r.err
+	}
+	r.headers = headers
+	return r.headers, nil
+}
+
+// checkExtraField reports a header named ExtraField under RaggedExtra, whose
+// surplus fields would replace it. The error is located in the header row
+// when the names come from it.
+func (r *Reader) checkExtraField(headers []string) error {
+	if r.opts.Ragged != RaggedExtra {
+		return nil
+	}
+	for i, name := range headers {
+		if name != ExtraField {
+			continue
+		}
+		err := fmt.Errorf("column %q clashes with the surplus fields of the extra ragged row policy", name)
+		if !r.dialect.HasHeader || r.opts.ColumnNames != nil {
+			return err
+		}
+		pe := &ParseError{Reason: err.Error(), Err: err}
+		pe.Line, pe.Column = r.csv.FieldPos(i)
+		if r.tail != nil {
+			pe.Snippet = r.tail.snippet(pe.Line, pe.Column)
+		}
+		return pe
+	}
+	return nil
+}
+
+// HeaderMapping relates each column's header cell to its record name.
+func (r *Reader) HeaderMapping() []Header {
+	if _, err := r.Headers(); err != nil {
+		return nil
+	}
+
+	mapping := make([]Header, len(r.headers))
+	for i, name := range r.headers {
+		mapping[i] = Header{Index: i, Original: r.raw[i], Name: name}
+	}
+	return mapping
+}
+
+// Next advances to the next record. It returns false when the input is
+// exhausted or an error occurs; Err reports which.
+func (r *Reader) Next() bool {
+	r.record, r.extra = nil, nil
+	if _, err := r.Headers(); err != nil {
+		return false
+	}
 
 	for {
-		row, err := reader.Read()
-		if err == io.EOF {
-			break
+		if err := r.ctx.Err(); err != nil {
+			r.err = err
+			return false
+		}
+		row := r.pending
+		r.pending = nil
+		if row == nil {
+			var err error
+			row, err = r.read()
+			if err == io.EOF {
+				return false
+			}
+			if err != nil {
+				r.err = r.locate(err)
+				return false
+			}
 		}
+
+		record, extra, ok, err := r.fitRow(row)
 		if err != nil {
-			return nil, err
+			r.err = r.locate(err)
+			return false
 		}
+		if ok {
+			r.record, r.extra = record, extra
+			return true
+		}
+	}
+}
 
-		record := make(map[string]string)
-		for i, value := range row {
-			record[headers[i]] = value
+// read returns the next row with any quote exchange undone.
+func (r *Reader) read() ([]string, error) {
+	row, err := r.csv.Read()
+	if err != nil {
+		return nil, err
+	}
+	r.fields = len(row)
+	for i := range row {
+		row[i] = unswapQuotes(row[i], r.dialect.Quote)
+		if r.opts.TrimSpace {
+			row[i] = strings.TrimSpace(row[i])
 		}
-		records = append(records, record)
+	}
+	return row, nil
+}
+
+// Record returns the record read by the most recent call to Next, with its
+// fields in header order.
+func (r *Reader) Record() Record {
+	return r.record
+}
+
+// Extra returns the surplus fields of the current record under RaggedExtra.
+func (r *Reader) Extra() []string {
+	return r.extra
+}
+
+// RaggedLines returns the line numbers of the rows padded, extended or
+// skipped under the ragged row policy so far.
+func (r *Reader) RaggedLines() []int {
+	return r.ragged
+}
+
+// Line returns the line on which the current record starts.
+func (r *Reader) Line() int {
+	line, _ := r.Position(0)
+	return line
+}
+
+// Position returns the line and column at which field i of the current
+// record starts. Fields added by padding are reported at the end of the
+// row. It is only valid until the next call to Next.
+func (r *Reader) Position(i int) (line, column int) {
+	if r.record == nil || r.fields == 0 {
+		return 0, 0
+	}
+	if i >= r.fields {
+		last := r.fields - 1
+		line, column = r.csv.FieldPos(last)
+		return line, column + len(r.record[last].Value)
+	}
+	return r.csv.FieldPos(i)
+}
+
+// Err returns the first error encountered while reading. Problems located
+// in the input are reported as *ParseError. A missing header row is
+// reported as io.EOF; reaching the end of the data rows is not an error.
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// ParseCSV reads all records from r. Prefer Reader for large inputs.
+func ParseCSV(r io.Reader) ([]map[string]string, error) {
+	return ParseCSVWithOptions(r, Options{})
+}
+
+// ParseCSVWithOptions reads all records from r using opts.
+func ParseCSVWithOptions(r io.Reader, opts Options) ([]map[string]string, error) {
+	return ParseCSVContext(context.Background(), r, opts)
+}
+
+// ParseCSVContext is ParseCSVWithOptions with a context; it stops reading
+// and returns ctx.Err() once ctx is done.
+func ParseCSVContext(ctx context.Context, r io.Reader, opts Options) ([]map[string]string, error) {
+	reader := NewReaderContext(ctx, r, opts)
+
+	if _, err := reader.Headers(); err != nil {
+		return nil, err
+	}
+
+	var records []map[string]string
+	for reader.Next() {
+		records = append(records, reader.Record().Map())
+	}
+	if err := reader.Err(); err != nil {
+		return nil, err
 	}
 
 	return records, nil
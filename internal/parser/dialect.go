@@ -0,0 +1,308 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DefaultSniffSize is the most bytes inspected when detecting a dialect.
+const DefaultSniffSize = 64 << 10
+
+// sniffHeaderRows limits how many records are examined for header detection.
+const sniffHeaderRows = 20
+
+// delimiterCandidates lists the delimiters the sniffer chooses from, in order
+// of preference when several are equally plausible.
+var delimiterCandidates = []rune{',', ';', '\t', '|'}
+
+// Dialect describes how a CSV input is formatted.
+type Dialect struct {
+	Delimiter rune
+	Quote     rune
+	HasHeader bool
+}
+
+// DefaultDialect is the dialect of encoding/csv: comma separated, double
+// quoted, with a header row.
+var DefaultDialect = Dialect{Delimiter: ',', Quote: '"', HasHeader: true}
+
+// HeaderMode controls whether the first row is treated as a header row.
+type HeaderMode int
+
+const (
+	// HeaderPresent always treats the first row as the header row. It is the
+	// default.
+	HeaderPresent HeaderMode = iota
+	// HeaderAbsent treats every row as data.
+	HeaderAbsent
+	// HeaderAuto detects whether the first row is a header row.
+	HeaderAuto
+)
+
+// Sniff guesses the dialect of a CSV sample, typically the first few
+// kilobytes of a file.
+func Sniff(sample []byte) Dialect {
+	return sniff(sample, true, Options{Header: HeaderAuto})
+}
+
+// sniff detects the parts of the dialect not fixed by opts. complete reports
+// whether sample holds the whole input; if not, its last line is ignored
+// because it may be truncated.
+func sniff(sample []byte, complete bool, opts Options) Dialect {
+	if !complete {
+		if i := bytes.LastIndexByte(sample, '\n'); i >= 0 {
+			sample = sample[:i+1]
+		}
+	}
+
+	if opts.Comment != 0 {
+		sample = stripComments(sample, byte(opts.Comment))
+	}
+
+	d := DefaultDialect
+
+	d.Quote = opts.Quote
+	if d.Quote == 0 {
+		d.Quote = sniffQuote(sample)
+	}
+
+	d.Delimiter = opts.Delimiter
+	if d.Delimiter == 0 {
+		d.Delimiter = sniffDelimiter(sample, d.Quote)
+	}
+
+	switch opts.Header {
+	case HeaderAuto:
+		d.HasHeader = sniffHeader(sample, d)
+	case HeaderAbsent:
+		d.HasHeader = false
+	default:
+		d.HasHeader = true
+	}
+
+	return d
+}
+
+// stripComments removes the lines of sample starting with comment.
+func stripComments(sample []byte, comment byte) []byte {
+	var kept []byte
+	for len(sample) > 0 {
+		line := sample
+		if i := bytes.IndexByte(sample, '\n'); i >= 0 {
+			line = sample[:i+1]
+		}
+		sample = sample[len(line):]
+		if line[0] != comment {
+			kept = append(kept, line...)
+		}
+	}
+	return kept
+}
+
+// sniffQuote picks single quotes only when they are used to wrap fields and
+// double quotes never are.
+func sniffQuote(sample []byte) rune {
+	if countQuotedFields(sample, '\'') > 0 && countQuotedFields(sample, '"') == 0 {
+		return '\''
+	}
+	return '"'
+}
+
+// countQuotedFields counts occurrences of q at the start of a field, i.e.
+// at the start of a line or directly after a candidate delimiter.
+func countQuotedFields(sample []byte, q byte) int {
+	count := 0
+	for i, b := range sample {
+		if b != q {
+			continue
+		}
+		if i == 0 || sample[i-1] == '\n' || isDelimiterCandidate(rune(sample[i-1])) {
+			count++
+		}
+	}
+	return count
+}
+
+func isDelimiterCandidate(r rune) bool {
+	for _, c := range delimiterCandidates {
+		if r == c {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffDelimiter picks the candidate that appears the same number of times
+// on the most lines, breaking ties by the higher per-line count. A candidate
+// only counts if the first record, normally the header, has it that number
+// of times too, so that single-column input whose values happen to contain
+// one keeps the default.
+func sniffDelimiter(sample []byte, quote rune) rune {
+	lines := splitRecords(sample, byte(quote))
+	if len(lines) == 0 {
+		return DefaultDialect.Delimiter
+	}
+
+	best := DefaultDialect.Delimiter
+	bestConsistency, bestCount := 0.0, 0
+	for _, candidate := range delimiterCandidates {
+		frequency := make(map[int]int)
+		for _, line := range lines {
+			frequency[countOutsideQuotes(line, byte(candidate), byte(quote))]++
+		}
+
+		mode, modeLines := 0, 0
+		for count, n := range frequency {
+			if count > 0 && (n > modeLines || (n == modeLines && count > mode)) {
+				mode, modeLines = count, n
+			}
+		}
+		if mode == 0 || countOutsideQuotes(lines[0], byte(candidate), byte(quote)) != mode {
+			continue
+		}
+
+		consistency := float64(modeLines) / float64(len(lines))
+		if consistency > bestConsistency || (consistency == bestConsistency && mode > bestCount) {
+			best, bestConsistency, bestCount = candidate, consistency, mode
+		}
+	}
+
+	return best
+}
+
+// splitRecords splits sample into non-empty records, keeping newlines inside
+// quoted fields.
+func splitRecords(sample []byte, quote byte) [][]byte {
+	var records [][]byte
+	inQuotes := false
+	start := 0
+	for i, b := range sample {
+		switch {
+		case b == quote:
+			inQuotes = !inQuotes
+		case b == '\n' && !inQuotes:
+			if line := bytes.TrimRight(sample[start:i], "\r"); len(line) > 0 {
+				records = append(records, line)
+			}
+			start = i + 1
+		}
+	}
+	if line := bytes.TrimRight(sample[start:], "\r"); len(line) > 0 && !inQuotes {
+		records = append(records, line)
+	}
+	return records
+}
+
+func countOutsideQuotes(line []byte, delimiter, quote byte) int {
+	count := 0
+	inQuotes := false
+	for _, b := range line {
+		switch {
+		case b == quote:
+			inQuotes = !inQuotes
+		case b == delimiter && !inQuotes:
+			count++
+		}
+	}
+	return count
+}
+
+// sniffHeader reports whether the first record looks like a header row. Each
+// column whose data values are all numeric votes for a header if its first
+// cell is not numeric, and against one if it is. Without evidence either
+// way the first row is assumed to be a header.
+func sniffHeader(sample []byte, d Dialect) bool {
+	reader := csv.NewReader(newQuoteReader(bytes.NewReader(sample), d.Quote))
+	reader.Comma = d.Delimiter
+	reader.FieldsPerRecord = -1
+
+	var rows [][]string
+	for len(rows) < sniffHeaderRows {
+		row, err := reader.Read()
+		if err != nil {
+			break
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) < 2 {
+		return true
+	}
+
+	votesFor, votesAgainst := 0, 0
+	for col, header := range rows[0] {
+		numeric := 0
+		for _, row := range rows[1:] {
+			if col >= len(row) || strings.TrimSpace(row[col]) == "" {
+				continue
+			}
+			if !isNumeric(row[col]) {
+				numeric = -1
+				break
+			}
+			numeric++
+		}
+		if numeric <= 0 {
+			continue
+		}
+
+		if isNumeric(header) {
+			votesAgainst++
+		} else {
+			votesFor++
+		}
+	}
+
+	return votesAgainst <= votesFor
+}
+
+func isNumeric(s string) bool {
+	_, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return err == nil
+}
+
+// quoteReader exchanges a custom quote character with '"' so that
+// encoding/csv, which only understands double quotes, can parse the input.
+// Field values are exchanged back by unswapQuotes.
+type quoteReader struct {
+	r     io.Reader
+	quote byte
+}
+
+func newQuoteReader(r io.Reader, quote rune) io.Reader {
+	if quote == '"' {
+		return r
+	}
+	return &quoteReader{r: r, quote: byte(quote)}
+}
+
+func (q *quoteReader) Read(p []byte) (int, error) {
+	n, err := q.r.Read(p)
+	for i := 0; i < n; i++ {
+		switch p[i] {
+		case q.quote:
+			p[i] = '"'
+		case '"':
+			p[i] = q.quote
+		}
+	}
+	return n, err
+}
+
+// unswapQuotes reverses the exchange made by quoteReader in a parsed field.
+func unswapQuotes(s string, quote rune) string {
+	if quote == '"' {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case quote:
+			return '"'
+		case '"':
+			return quote
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,266 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Supported source encodings. Input is transcoded to UTF-8 before parsing.
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingUTF16       = "utf-16"
+	EncodingUTF16LE     = "utf-16le"
+	EncodingUTF16BE     = "utf-16be"
+	EncodingWindows1252 = "windows-1252"
+	EncodingLatin1      = "iso-8859-1"
+	EncodingLatin9      = "iso-8859-15"
+)
+
+var encodingAliases = map[string]string{
+	"utf8":        EncodingUTF8,
+	"utf-8":       EncodingUTF8,
+	"utf16":       EncodingUTF16,
+	"utf-16":      EncodingUTF16,
+	"utf-16le":    EncodingUTF16LE,
+	"utf-16be":    EncodingUTF16BE,
+	"windows1252": EncodingWindows1252,
+	"cp1252":      EncodingWindows1252,
+	"latin1":      EncodingLatin1,
+	"latin-1":     EncodingLatin1,
+	"iso8859-1":   EncodingLatin1,
+	"latin9":      EncodingLatin9,
+	"iso8859-15":  EncodingLatin9,
+}
+
+func init() {
+	for _, name := range []string{EncodingWindows1252, EncodingLatin1, EncodingLatin9} {
+		encodingAliases[name] = name
+	}
+}
+
+// ParseEncoding returns the canonical name of a supported encoding.
+func ParseEncoding(s string) (string, error) {
+	if name, ok := encodingAliases[strings.ToLower(strings.TrimSpace(s))]; ok {
+		return name, nil
+	}
+	return "", fmt.Errorf("unsupported encoding %q", s)
+}
+
+// EncodingError reports an invalid byte sequence in the input.
+type EncodingError struct {
+	Encoding string
+	// Offset is the position of the sequence in bytes from the start of
+	// the input, including any byte order mark.
+	Offset int64
+	// Line and Column locate the sequence in the decoded text.
+	Line   int
+	Column int
+}
+
+func (e *EncodingError) Error() string {
+	return fmt.Sprintf("invalid %s byte sequence at offset %d", e.Encoding, e.Offset)
+}
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// windows1252 maps bytes 0x80-0x9F, where Windows-1252 differs from
+// Latin-1. Zero marks the five undefined bytes.
+var windows1252 = [32]rune{
+	0x20AC, 0, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0, 0x017D, 0,
+	0, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0, 0x017E, 0x0178,
+}
+
+// latin9 lists where ISO-8859-15 differs from Latin-1.
+var latin9 = map[byte]rune{
+	0xA4: 0x20AC, 0xA6: 0x0160, 0xA8: 0x0161, 0xB4: 0x017D,
+	0xB8: 0x017E, 0xBC: 0x0152, 0xBD: 0x0153, 0xBE: 0x0178,
+}
+
+// newDecoder returns a reader producing UTF-8 from src in the named
+// encoding, and the encoding actually used. An empty name detects UTF-16
+// from its byte order mark and otherwise assumes UTF-8. Byte order marks
+// are removed.
+func newDecoder(src io.Reader, encoding string) (io.Reader, string, error) {
+	br := bufio.NewReader(src)
+	head, err := br.Peek(3)
+	if err != nil && err != io.EOF {
+		return nil, "", err
+	}
+
+	bom := 0
+	switch {
+	case bytes.HasPrefix(head, bomUTF8) && (encoding == "" || encoding == EncodingUTF8):
+		encoding, bom = EncodingUTF8, len(bomUTF8)
+	case bytes.HasPrefix(head, bomUTF16LE) && (encoding == "" || encoding == EncodingUTF16 || encoding == EncodingUTF16LE):
+		encoding, bom = EncodingUTF16LE, len(bomUTF16LE)
+	case bytes.HasPrefix(head, bomUTF16BE) && (encoding == "" || encoding == EncodingUTF16 || encoding == EncodingUTF16BE):
+		encoding, bom = EncodingUTF16BE, len(bomUTF16BE)
+	case encoding == "":
+		encoding = EncodingUTF8
+	case encoding == EncodingUTF16:
+		// No byte order mark; assume the Windows default
+		encoding = EncodingUTF16LE
+	}
+	if _, err := br.Discard(bom); err != nil {
+		return nil, "", err
+	}
+
+	d := &decoder{r: br, encoding: encoding, offset: int64(bom), line: 1, column: 1}
+	switch encoding {
+	case EncodingUTF8:
+		d.next = d.nextUTF8
+	case EncodingUTF16LE:
+		d.next = func() (rune, int, error) { return d.nextUTF16(false) }
+	case EncodingUTF16BE:
+		d.next = func() (rune, int, error) { return d.nextUTF16(true) }
+	case EncodingWindows1252, EncodingLatin1, EncodingLatin9:
+		d.next = d.nextSingleByte
+	default:
+		return nil, "", fmt.Errorf("unsupported encoding %q", encoding)
+	}
+	return d, encoding, nil
+}
+
+// decoder transcodes its input to UTF-8 one rune at a time.
+type decoder struct {
+	r        *bufio.Reader
+	encoding string
+	next     func() (rune, int, error)
+	offset   int64
+	line     int
+	column   int
+	pending  []byte
+	err      error
+}
+
+func (d *decoder) Read(p []byte) (int, error) {
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+
+	// Once something is decoded, stop rather than wait for more input, so
+	// that input arriving slowly is parsed as it comes
+	var buf [utf8.UTFMax]byte
+	for n < len(p) && d.err == nil && (n == 0 || d.r.Buffered() > 0) {
+		r, size, err := d.next()
+		if err != nil {
+			d.err = err
+			break
+		}
+		d.offset += int64(size)
+		if r == '\n' {
+			d.line, d.column = d.line+1, 1
+		} else {
+			d.column += utf8.RuneLen(r)
+		}
+
+		if n+utf8.RuneLen(r) <= len(p) {
+			n += utf8.EncodeRune(p[n:], r)
+			continue
+		}
+		w := utf8.EncodeRune(buf[:], r)
+		c := copy(p[n:], buf[:w])
+		d.pending = append(d.pending, buf[c:w]...)
+		n += c
+	}
+
+	if n > 0 || len(d.pending) > 0 {
+		return n, nil
+	}
+	return n, d.err
+}
+
+func (d *decoder) invalid() error {
+	return &EncodingError{Encoding: d.encoding, Offset: d.offset, Line: d.line, Column: d.column}
+}
+
+func (d *decoder) nextUTF8() (rune, int, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	if b < utf8.RuneSelf {
+		return rune(b), 1, nil
+	}
+
+	d.r.UnreadByte()
+	r, size, err := d.r.ReadRune()
+	if err != nil {
+		return 0, 0, err
+	}
+	if r == utf8.RuneError && size == 1 {
+		return 0, 0, d.invalid()
+	}
+	return r, size, nil
+}
+
+func (d *decoder) nextUTF16(bigEndian bool) (rune, int, error) {
+	readUnit := func() (uint16, error) {
+		var b [2]byte
+		n, err := io.ReadFull(d.r, b[:])
+		if err == io.ErrUnexpectedEOF || (err == io.EOF && n > 0) {
+			return 0, d.invalid()
+		}
+		if err != nil {
+			return 0, err
+		}
+		if bigEndian {
+			return uint16(b[0])<<8 | uint16(b[1]), nil
+		}
+		return uint16(b[1])<<8 | uint16(b[0]), nil
+	}
+
+	u1, err := readUnit()
+	if err != nil {
+		return 0, 0, err
+	}
+	if !utf16.IsSurrogate(rune(u1)) {
+		return rune(u1), 2, nil
+	}
+
+	u2, err := readUnit()
+	if err == io.EOF {
+		return 0, 0, d.invalid()
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	r := utf16.DecodeRune(rune(u1), rune(u2))
+	if r == utf8.RuneError {
+		return 0, 0, d.invalid()
+	}
+	return r, 4, nil
+}
+
+func (d *decoder) nextSingleByte() (rune, int, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	switch d.encoding {
+	case EncodingWindows1252:
+		if b >= 0x80 && b <= 0x9F {
+			r := windows1252[b-0x80]
+			if r == 0 {
+				return 0, 0, d.invalid()
+			}
+			return r, 1, nil
+		}
+	case EncodingLatin9:
+		if r, ok := latin9[b]; ok {
+			return r, 1, nil
+		}
+	}
+	return rune(b), 1, nil
+}
@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// snippetWidth is the most bytes of a line quoted in a ParseError.
+const snippetWidth = 80
+
+// tailSize is how much already parsed input is kept for snippets.
+const tailSize = 16 << 10
+
+// ParseError locates a problem in the CSV input. It wraps the underlying
+// error: a *csv.ParseError, *FieldCountError or *EncodingError, or a header
+// that clashes with ExtraField.
+type ParseError struct {
+	// Line is the 1-based line number of the problem.
+	Line int `json:"line"`
+	// Column is the 1-based byte index in the line.
+	Column int `json:"column"`
+	// Snippet is the text of the line around Column, if still available.
+	Snippet string `json:"snippet"`
+	// Reason describes the problem.
+	Reason string `json:"reason"`
+	Err    error  `json:"-"`
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Reason)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// locate turns errors that carry a position into a *ParseError with a
+// snippet of the offending line. Other errors are returned unchanged.
+func (r *Reader) locate(err error) error {
+	pe := &ParseError{Err: err}
+
+	var csvErr *csv.ParseError
+	var countErr *FieldCountError
+	var encErr *EncodingError
+	switch {
+	case errors.As(err, &csvErr):
+		pe.Line, pe.Column, pe.Reason = csvErr.Line, csvErr.Column, csvErr.Err.Error()
+	case errors.As(err, &countErr):
+		pe.Line, pe.Column = countErr.Line, countErr.Column
+		pe.Reason = fmt.Sprintf("wrong number of fields: expected %d, got %d", countErr.Expected, countErr.Got)
+	case errors.As(err, &encErr):
+		pe.Line, pe.Column, pe.Reason = encErr.Line, encErr.Column, encErr.Error()
+	default:
+		return err
+	}
+
+	if r.tail != nil {
+		pe.Snippet = r.tail.snippet(pe.Line, pe.Column)
+	}
+	return pe
+}
+
+// tailReader remembers the most recent input so error snippets can quote
+// the offending line after it has been consumed.
+type tailReader struct {
+	r    io.Reader
+	size int
+	buf  []byte
+	// line is the line number of buf[0]; partial is set when buf starts
+	// mid-line.
+	line    int
+	partial bool
+}
+
+func newTailReader(r io.Reader, size int) *tailReader {
+	return &tailReader{r: r, size: size, line: 1}
+}
+
+func (t *tailReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.buf = append(t.buf, p[:n]...)
+	if len(t.buf) > 2*t.size {
+		drop := len(t.buf) - t.size
+		t.line += bytes.Count(t.buf[:drop], []byte{'\n'})
+		t.partial = t.buf[drop-1] != '\n'
+		t.buf = append(t.buf[:0], t.buf[drop:]...)
+	}
+	return n, err
+}
+
+// snippet returns up to snippetWidth bytes of line n centred on column.
+func (t *tailReader) snippet(n, column int) string {
+	if n < t.line || (n == t.line && t.partial) {
+		return ""
+	}
+
+	rest := t.buf
+	for l := t.line; l < n; l++ {
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			return ""
+		}
+		rest = rest[i+1:]
+	}
+	if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+		rest = rest[:i]
+	}
+	rest = bytes.TrimRight(rest, "\r")
+
+	if len(rest) > snippetWidth {
+		start := column - 1 - snippetWidth/2
+		if start < 0 {
+			start = 0
+		}
+		if start > len(rest)-snippetWidth {
+			start = len(rest) - snippetWidth
+		}
+		end := start + snippetWidth
+		for start > 0 && !utf8.RuneStart(rest[start]) {
+			start--
+		}
+		for end < len(rest) && !utf8.RuneStart(rest[end]) {
+			end++
+		}
+		rest = rest[start:end]
+	}
+	return strings.ToValidUTF8(string(rest), "")
+}
@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// HeaderCase selects a case conversion for header names.
+type HeaderCase int
+
+const (
+	// CaseKeep leaves header names as they are.
+	CaseKeep HeaderCase = iota
+	// CaseSnake converts header names to snake_case.
+	CaseSnake
+	// CaseCamel converts header names to camelCase.
+	CaseCamel
+)
+
+var headerCaseNames = map[HeaderCase]string{
+	CaseKeep:  "keep",
+	CaseSnake: "snake",
+	CaseCamel: "camel",
+}
+
+func (c HeaderCase) String() string {
+	if name, ok := headerCaseNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("HeaderCase(%d)", int(c))
+}
+
+// ParseHeaderCase returns the case named by s: keep, snake or camel.
+func ParseHeaderCase(s string) (HeaderCase, error) {
+	for c, name := range headerCaseNames {
+		if name == s {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown header case %q", s)
+}
+
+// Header maps a column of the input to its name in the output.
+type Header struct {
+	// Index is the zero-based position of the column.
+	Index int `json:"index"`
+	// Original is the header cell as read, empty for generated names.
+	Original string `json:"original"`
+	// Name is the name used in records.
+	Name string `json:"name"`
+}
+
+// normalizeHeaders turns raw header cells into unique record names: cells
+// are optionally trimmed and case converted, blank names become column_N
+// for the Nth column, and repeated names get a _2, _3, ... suffix.
+func normalizeHeaders(raw []string, opts Options) []string {
+	names := make([]string, len(raw))
+	for i, name := range raw {
+		if opts.TrimHeaders {
+			name = strings.TrimSpace(name)
+		}
+		switch opts.HeaderCase {
+		case CaseSnake:
+			name = strings.ToLower(strings.Join(splitWords(name), "_"))
+		case CaseCamel:
+			name = camelCase(splitWords(name))
+		}
+		if strings.TrimSpace(name) == "" {
+			name = fmt.Sprintf("column_%d", i+1)
+		}
+		names[i] = name
+	}
+
+	return dedupeHeaders(names)
+}
+
+// dedupeHeaders suffixes repeated names, skipping suffixes already taken by
+// another column.
+func dedupeHeaders(names []string) []string {
+	taken := make(map[string]bool, len(names))
+	for _, name := range names {
+		taken[name] = false
+	}
+
+	unique := make([]string, len(names))
+	for i, name := range names {
+		if !taken[name] {
+			taken[name] = true
+			unique[i] = name
+			continue
+		}
+		for n := 2; ; n++ {
+			candidate := fmt.Sprintf("%s_%d", name, n)
+			if _, exists := taken[candidate]; !exists {
+				taken[candidate] = true
+				unique[i] = candidate
+				break
+			}
+		}
+	}
+	return unique
+}
+
+// splitWords splits s at non-alphanumeric characters and at lower-to-upper
+// case changes, keeping acronyms together: "HTTPStatus code" gives
+// [HTTP Status code].
+func splitWords(s string) []string {
+	var words []string
+	var word []rune
+	runes := []rune(s)
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(word) > 0 {
+			prev := word[len(word)-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+
+	return words
+}
+
+func camelCase(words []string) string {
+	var b strings.Builder
+	for i, word := range words {
+		lower := []rune(strings.ToLower(word))
+		if i > 0 {
+			lower[0] = unicode.ToUpper(lower[0])
+		}
+		b.WriteString(string(lower))
+	}
+	return b.String()
+}
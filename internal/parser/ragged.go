@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"fmt"
+)
+
+// ExtraField is the name under which RaggedExtra collects surplus fields.
+const ExtraField = "_extra"
+
+// RaggedPolicy controls rows whose field count differs from the header row.
+type RaggedPolicy int
+
+const (
+	// RaggedStrict fails on the first ragged row, reporting its line and column.
+	RaggedStrict RaggedPolicy = iota
+	// RaggedPad fills missing fields with null. Rows with too many fields
+	// still fail.
+	RaggedPad
+	// RaggedExtra fills missing fields with null like RaggedPad and collects
+	// surplus fields into an ExtraField array. A column named ExtraField is
+	// an error.
+	RaggedExtra
+	// RaggedSkip drops ragged rows.
+	RaggedSkip
+)
+
+var raggedPolicyNames = map[RaggedPolicy]string{
+	RaggedStrict: "strict",
+	RaggedPad:    "pad",
+	RaggedExtra:  "extra",
+	RaggedSkip:   "skip",
+}
+
+func (p RaggedPolicy) String() string {
+	if name, ok := raggedPolicyNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("RaggedPolicy(%d)", int(p))
+}
+
+// ParseRaggedPolicy returns the policy named by s: strict, pad, extra or skip.
+func ParseRaggedPolicy(s string) (RaggedPolicy, error) {
+	for p, name := range raggedPolicyNames {
+		if name == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown ragged row policy %q", s)
+}
+
+// FieldCountError reports a row whose field count differs from the header row.
+type FieldCountError struct {
+	Line     int
+	Column   int
+	Expected int
+	Got      int
+}
+
+func (e *FieldCountError) Error() string {
+	return fmt.Sprintf("line %d, column %d: wrong number of fields: expected %d, got %d",
+		e.Line, e.Column, e.Expected, e.Got)
+}
+
+// fitRow applies the ragged row policy to row. It returns the record to
+// emit, any surplus fields, and false if the row is to be skipped.
+func (r *Reader) fitRow(row []string) (Record, []string, bool, error) {
+	width := len(r.headers)
+	if len(row) == width {
+		record := make(Record, width)
+		for i, value := range row {
+			record[i] = Field{Name: r.headers[i], Value: value}
+		}
+		return record, nil, true, nil
+	}
+
+	line, _ := r.csv.FieldPos(0)
+	policy := r.opts.Ragged
+	if policy == RaggedSkip {
+		r.ragged = append(r.ragged, line)
+		return nil, nil, false, nil
+	}
+	if policy == RaggedStrict || (policy == RaggedPad && len(row) > width) {
+		return nil, nil, false, r.fieldCountError(row)
+	}
+	r.ragged = append(r.ragged, line)
+
+	record := make(Record, width)
+	for i := range record {
+		record[i] = Field{Name: r.headers[i]}
+		if i < len(row) {
+			record[i].Value = row[i]
+		} else {
+			record[i].Null = true
+		}
+	}
+
+	var extra []string
+	if len(row) > width {
+		extra = row[width:]
+	}
+	return record, extra, true, nil
+}
+
+// fieldCountError locates the first surplus field, or the end of the last
+// field of a short row.
+func (r *Reader) fieldCountError(row []string) error {
+	e := &FieldCountError{Expected: len(r.headers), Got: len(row)}
+	switch {
+	case len(row) > len(r.headers):
+		e.Line, e.Column = r.csv.FieldPos(len(r.headers))
+	default:
+		last := len(row) - 1
+		e.Line, e.Column = r.csv.FieldPos(last)
+		e.Column += len(row[last])
+	}
+	return e
+}
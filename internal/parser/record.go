@@ -0,0 +1,31 @@
+package parser
+
+// Field is one named value of a Record.
+type Field struct {
+	Name  string
+	Value string
+	// Null marks a field missing from a short row.
+	Null bool
+}
+
+// Record is a CSV row with its values in header order.
+type Record []Field
+
+// Get returns the value of the named field.
+func (r Record) Get(name string) (string, bool) {
+	for _, f := range r {
+		if f.Name == name {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+// Map returns the record as a map, losing the field order.
+func (r Record) Map() map[string]string {
+	m := make(map[string]string, len(r))
+	for _, f := range r {
+		m[f.Name] = f.Value
+	}
+	return m
+}
@@ -1,6 +1,10 @@
 package tests
 
 import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -20,3 +24,179 @@ func TestParseCSV(t *testing.T) {
 		t.Errorf("expected 2 records, got %d", len(result))
 	}
 }
+
+func TestReader_StreamsRecords(t *testing.T) {
+	r := parser.NewReader(strings.NewReader("name,age\nAlice,30\nBob,25"))
+
+	var names []string
+	for r.Next() {
+		name, _ := r.Record().Get("name")
+		names = append(names, name)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(names) != 2 || names[0] != "Alice" || names[1] != "Bob" {
+		t.Errorf("unexpected records: %v", names)
+	}
+}
+
+func TestReader_Headers(t *testing.T) {
+	r := parser.NewReader(strings.NewReader("name,age\nAlice,30"))
+
+	headers, err := r.Headers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != 2 || headers[0] != "name" || headers[1] != "age" {
+		t.Errorf("unexpected headers: %v", headers)
+	}
+
+	if !r.Next() {
+		t.Fatalf("expected a record, err: %v", r.Err())
+	}
+	if age, _ := r.Record().Get("age"); age != "30" {
+		t.Errorf("expected age=30, got %s", age)
+	}
+}
+
+func TestReader_EmptyInput(t *testing.T) {
+	r := parser.NewReader(strings.NewReader(""))
+
+	if r.Next() {
+		t.Fatal("expected no records")
+	}
+	if r.Err() != io.EOF {
+		t.Errorf("expected io.EOF, got %v", r.Err())
+	}
+}
+
+func TestReader_RecordKeepsHeaderOrder(t *testing.T) {
+	r := parser.NewReader(strings.NewReader("zeta,alpha,mid\n1,2,3"))
+
+	if !r.Next() {
+		t.Fatalf("expected a record, err: %v", r.Err())
+	}
+
+	record := r.Record()
+	want := []string{"zeta", "alpha", "mid"}
+	for i, f := range record {
+		if f.Name != want[i] {
+			t.Errorf("field %d: expected %s, got %s", i, want[i], f.Name)
+		}
+	}
+}
+
+func TestReader_Comments(t *testing.T) {
+	csvData := "# exported 2026-01-02\nname;age\n# a note; with; delimiters\nAlice;30\n"
+	records, err := parser.ParseCSVWithOptions(strings.NewReader(csvData), parser.Options{Comment: '#'})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 || records[0]["name"] != "Alice" || records[0]["age"] != "30" {
+		t.Errorf("unexpected records %v", records)
+	}
+}
+
+func TestReader_LazyQuotes(t *testing.T) {
+	csvData := "name,size\nbolt,3\" long\n"
+	if _, err := parser.ParseCSV(strings.NewReader(csvData)); err == nil {
+		t.Fatal("expected strict parsing to fail")
+	}
+
+	records, err := parser.ParseCSVWithOptions(strings.NewReader(csvData), parser.Options{LazyQuotes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[0]["size"] != `3" long` {
+		t.Errorf("unexpected size %q", records[0]["size"])
+	}
+}
+
+func TestReader_TrimSpace(t *testing.T) {
+	csvData := " name , city\n Alice ,  \"New York \"\n"
+	records, err := parser.ParseCSVWithOptions(strings.NewReader(csvData), parser.Options{TrimSpace: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if records[0]["name"] != "Alice" || records[0]["city"] != "New York" {
+		t.Errorf("unexpected records %v", records)
+	}
+}
+
+func TestOptions_ValidateComment(t *testing.T) {
+	for _, opts := range []parser.Options{
+		{Comment: '\n'},
+		{Comment: '"'},
+		{Comment: ';', Delimiter: ';'},
+		{Comment: '\'', Quote: '\''},
+	} {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("%+v: expected error", opts)
+		}
+	}
+	if err := (parser.Options{Comment: '#'}).Validate(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// endlessRows generates a header and numbered rows forever, calling cancel
+// once it has generated cancelAt rows
+type endlessRows struct {
+	rows     int
+	cancelAt int
+	cancel   func()
+	pending  []byte
+}
+
+func (e *endlessRows) Read(p []byte) (int, error) {
+	for len(e.pending) < len(p) {
+		if e.rows == 0 {
+			e.pending = append(e.pending, "n\n"...)
+		} else {
+			e.pending = append(e.pending, strconv.Itoa(e.rows)+"\n"...)
+		}
+		e.rows++
+		if e.rows == e.cancelAt {
+			e.cancel()
+		}
+	}
+	n := copy(p, e.pending)
+	e.pending = e.pending[n:]
+	return n, nil
+}
+
+func TestReader_ContextCancelledMidFile(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	input := &endlessRows{cancelAt: 50000, cancel: cancel}
+
+	r := parser.NewReaderContext(ctx, input, parser.Options{})
+	records := 0
+	for r.Next() {
+		records++
+	}
+
+	if !errors.Is(r.Err(), context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", r.Err())
+	}
+	if records == 0 || records >= input.rows {
+		t.Errorf("expected to stop partway, read %d records of %d generated", records, input.rows)
+	}
+	if input.rows > 2*input.cancelAt {
+		t.Errorf("expected reading to stop soon after cancellation, %d rows generated", input.rows)
+	}
+}
+
+func TestParseCSVContext_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := parser.ParseCSVContext(ctx, strings.NewReader("a\n1\n"), parser.Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
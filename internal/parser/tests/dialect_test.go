@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/parser"
+)
+
+func TestSniff_Delimiters(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample string
+		want   rune
+	}{
+		{"comma", "name,age\nAlice,30\nBob,25\n", ','},
+		{"semicolon", "name;price\nfoo;1,5\nbar;2,25\n", ';'},
+		{"tab", "name\tage\nAlice\t30\nBob\t25\n", '\t'},
+		{"pipe", "name|age|city\nAlice|30|NYC\n", '|'},
+		{"quoted delimiter", "name;note\n\"a\";\"x,y,z\"\n", ';'},
+		{"single column", "name\nAlice\nBob\n", ','},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := parser.Sniff([]byte(tt.sample))
+			if d.Delimiter != tt.want {
+				t.Errorf("expected delimiter %q, got %q", tt.want, d.Delimiter)
+			}
+		})
+	}
+}
+
+func TestSniff_Quote(t *testing.T) {
+	if d := parser.Sniff([]byte("name,note\n'Alice','a, b'\n")); d.Quote != '\'' {
+		t.Errorf("expected single quote, got %q", d.Quote)
+	}
+	if d := parser.Sniff([]byte("name,note\nO'Brien,\"a, b\"\n")); d.Quote != '"' {
+		t.Errorf("expected double quote, got %q", d.Quote)
+	}
+}
+
+func TestSniff_Header(t *testing.T) {
+	if d := parser.Sniff([]byte("name,age\nAlice,30\nBob,25\n")); !d.HasHeader {
+		t.Error("expected a header row")
+	}
+	if d := parser.Sniff([]byte("1,30\n2,25\n3,40\n")); d.HasHeader {
+		t.Error("expected no header row")
+	}
+	if d := parser.Sniff([]byte("name,city\nAlice,NYC\n")); !d.HasHeader {
+		t.Error("expected a header row to be assumed without evidence")
+	}
+}
+
+func TestParseCSV_DetectsSemicolons(t *testing.T) {
+	result, err := parser.ParseCSV(strings.NewReader("name;price\nfoo;1,5\nbar;2,25"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 2 || result[0]["price"] != "1,5" {
+		t.Errorf("unexpected records: %v", result)
+	}
+}
+
+func TestParseCSV_SingleColumnKeepsComma(t *testing.T) {
+	for _, value := range []string{"hello; world", "a|b", "tab\there"} {
+		result, err := parser.ParseCSV(strings.NewReader("note\n" + value + "\nfoo\n"))
+		if err != nil {
+			t.Errorf("%q: %v", value, err)
+			continue
+		}
+		if len(result) != 2 || result[0]["note"] != value || result[1]["note"] != "foo" {
+			t.Errorf("%q: unexpected records: %v", value, result)
+		}
+	}
+}
+
+func TestParseCSV_SingleQuotes(t *testing.T) {
+	result, err := parser.ParseCSV(strings.NewReader("name,note\n'Alice','say \"hi\", ok'\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 1 || result[0]["note"] != `say "hi", ok` {
+		t.Errorf("unexpected records: %v", result)
+	}
+}
+
+func TestParseCSV_NoHeaderDetected(t *testing.T) {
+	opts := parser.Options{Header: parser.HeaderAuto}
+	result, err := parser.ParseCSVWithOptions(strings.NewReader("1,30\n2,25\n3,40"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 3 || result[0]["column_1"] != "1" || result[0]["column_2"] != "30" {
+		t.Errorf("unexpected records: %v", result)
+	}
+}
+
+func TestParseCSV_NumericHeaderByDefault(t *testing.T) {
+	result, err := parser.ParseCSV(strings.NewReader("2023,2024\n10,20\n30,40"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 2 || result[0]["2023"] != "10" || result[1]["2024"] != "40" {
+		t.Errorf("unexpected records: %v", result)
+	}
+}
+
+func TestParseCSVWithOptions_Override(t *testing.T) {
+	opts := parser.Options{Delimiter: '|', Quote: '"', Header: parser.HeaderPresent}
+	result, err := parser.ParseCSVWithOptions(strings.NewReader("a,b|c\n1,2|3"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 1 || result[0]["a,b"] != "1,2" || result[0]["c"] != "3" {
+		t.Errorf("unexpected records: %v", result)
+	}
+}
+
+func TestParseCSVWithOptions_InvalidDelimiter(t *testing.T) {
+	_, err := parser.ParseCSVWithOptions(strings.NewReader("a,b\n1,2"), parser.Options{Delimiter: '\n'})
+	if err == nil {
+		t.Fatal("expected error for newline delimiter")
+	}
+}
+
+func TestOptions_ValidateQuoteDelimiter(t *testing.T) {
+	if err := (parser.Options{Delimiter: '"'}).Validate(); err == nil {
+		t.Error("expected error for quote delimiter without a quote")
+	}
+
+	opts := parser.Options{Delimiter: '"', Quote: '\''}
+	result, err := parser.ParseCSVWithOptions(strings.NewReader("a\"b\n'x\"y'\"2\n"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || result[0]["a"] != `x"y` || result[0]["b"] != "2" {
+		t.Errorf("unexpected records: %v", result)
+	}
+}
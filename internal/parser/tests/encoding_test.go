@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/parser"
+)
+
+func encodeUTF16(s string, bigEndian bool) []byte {
+	var buf bytes.Buffer
+	for _, u := range utf16.Encode([]rune(s)) {
+		if bigEndian {
+			buf.WriteByte(byte(u >> 8))
+			buf.WriteByte(byte(u))
+		} else {
+			buf.WriteByte(byte(u))
+			buf.WriteByte(byte(u >> 8))
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestEncoding_StripsUTF8BOM(t *testing.T) {
+	input := append([]byte{0xEF, 0xBB, 0xBF}, "name,age\nAlice,30"...)
+	r := parser.NewReader(bytes.NewReader(input))
+
+	headers, err := r.Headers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers[0] != "name" {
+		t.Errorf("expected BOM to be stripped, got %q", headers[0])
+	}
+	if r.Encoding() != parser.EncodingUTF8 {
+		t.Errorf("expected utf-8, got %s", r.Encoding())
+	}
+}
+
+func TestEncoding_DetectsUTF16ByBOM(t *testing.T) {
+	for _, bigEndian := range []bool{false, true} {
+		bom := []byte{0xFF, 0xFE}
+		want := parser.EncodingUTF16LE
+		if bigEndian {
+			bom, want = []byte{0xFE, 0xFF}, parser.EncodingUTF16BE
+		}
+		input := append(bom, encodeUTF16("name,city\nZoë,東京 🏙\n", bigEndian)...)
+
+		r := parser.NewReader(bytes.NewReader(input))
+		if !r.Next() {
+			t.Fatalf("%s: expected a record, err: %v", want, r.Err())
+		}
+		if city, _ := r.Record().Get("city"); city != "東京 🏙" {
+			t.Errorf("%s: unexpected city %q", want, city)
+		}
+		if r.Encoding() != want {
+			t.Errorf("expected %s, got %s", want, r.Encoding())
+		}
+	}
+}
+
+func TestEncoding_Windows1252(t *testing.T) {
+	input := []byte("name,price\nCaf\xe9,\x8010\n")
+	result, err := parser.ParseCSVWithOptions(bytes.NewReader(input), parser.Options{Encoding: "cp1252"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result[0]["name"] != "Café" || result[0]["price"] != "€10" {
+		t.Errorf("unexpected record: %v", result[0])
+	}
+}
+
+func TestEncoding_Latin1(t *testing.T) {
+	input := []byte("name\nJos\xe9\n")
+	result, err := parser.ParseCSVWithOptions(bytes.NewReader(input), parser.Options{Encoding: "latin1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result[0]["name"] != "José" {
+		t.Errorf("unexpected record: %v", result[0])
+	}
+}
+
+func TestEncoding_InvalidUTF8ReportsOffset(t *testing.T) {
+	input := []byte("name\nCaf\xe9\n")
+	_, err := parser.ParseCSV(bytes.NewReader(input))
+
+	var encErr *parser.EncodingError
+	if !errors.As(err, &encErr) {
+		t.Fatalf("expected EncodingError, got %v", err)
+	}
+	if encErr.Offset != 8 || encErr.Encoding != parser.EncodingUTF8 {
+		t.Errorf("unexpected error: %+v", encErr)
+	}
+}
+
+func TestEncoding_InvalidAfterSniffWindow(t *testing.T) {
+	input := "name\n" + strings.Repeat("Alice\n", parser.DefaultSniffSize/6+10) + "Caf\xe9\n"
+	r := parser.NewReader(strings.NewReader(input))
+	for r.Next() {
+	}
+
+	var encErr *parser.EncodingError
+	if !errors.As(r.Err(), &encErr) {
+		t.Fatalf("expected EncodingError, got %v", r.Err())
+	}
+	if want := int64(len(input) - 2); encErr.Offset != want {
+		t.Errorf("expected offset %d, got %d", want, encErr.Offset)
+	}
+}
+
+func TestEncoding_DoesNotWaitForFullBuffer(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	opts := parser.Options{Delimiter: ',', Quote: '"', Header: parser.HeaderPresent}
+	r := parser.NewReaderWithOptions(pr, opts)
+
+	go io.WriteString(pw, "name,age\nAlice,30\n")
+
+	next := make(chan bool, 1)
+	go func() { next <- r.Next() }()
+	select {
+	case ok := <-next:
+		if name, _ := r.Record().Get("name"); !ok || name != "Alice" {
+			t.Errorf("expected Alice, got %v, %v", r.Record(), r.Err())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a record before the input was closed")
+	}
+}
+
+func TestEncoding_Windows1252UndefinedByte(t *testing.T) {
+	_, err := parser.ParseCSVWithOptions(bytes.NewReader([]byte("a\n\x81\n")), parser.Options{Encoding: "windows-1252"})
+
+	var encErr *parser.EncodingError
+	if !errors.As(err, &encErr) || encErr.Offset != 2 {
+		t.Errorf("expected EncodingError at offset 2, got %v", err)
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	if name, err := parser.ParseEncoding("Latin-1"); err != nil || name != parser.EncodingLatin1 {
+		t.Errorf("expected iso-8859-1, got %q (%v)", name, err)
+	}
+	if _, err := parser.ParseEncoding("ebcdic"); err == nil {
+		t.Error("expected error for unsupported encoding")
+	}
+}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/parser"
+)
+
+func TestParseError_BareQuote(t *testing.T) {
+	r := parser.NewReader(strings.NewReader("name,note\nAlice,ok\nBob,a \"bad\" quote\n"))
+	readAll(t, r)
+
+	var parseErr *parser.ParseError
+	if !errors.As(r.Err(), &parseErr) {
+		t.Fatalf("expected ParseError, got %v", r.Err())
+	}
+	if parseErr.Line != 3 || parseErr.Column != 7 {
+		t.Errorf("expected line 3, column 7, got line %d, column %d", parseErr.Line, parseErr.Column)
+	}
+	if parseErr.Snippet != `Bob,a "bad" quote` {
+		t.Errorf("unexpected snippet %q", parseErr.Snippet)
+	}
+	if !errors.Is(r.Err(), csv.ErrBareQuote) {
+		t.Errorf("expected the csv error to be wrapped, got %v", r.Err())
+	}
+}
+
+func TestParseError_FieldCount(t *testing.T) {
+	r := parser.NewReader(strings.NewReader(raggedCSV))
+	readAll(t, r)
+
+	var parseErr *parser.ParseError
+	if !errors.As(r.Err(), &parseErr) {
+		t.Fatalf("expected ParseError, got %v", r.Err())
+	}
+	if parseErr.Line != 3 || parseErr.Column != 4 || parseErr.Snippet != "4,5" {
+		t.Errorf("unexpected error: %+v", parseErr)
+	}
+	if want := "line 3, column 4: wrong number of fields: expected 3, got 2"; parseErr.Error() != want {
+		t.Errorf("expected %q, got %q", want, parseErr.Error())
+	}
+}
+
+func TestParseError_Encoding(t *testing.T) {
+	r := parser.NewReader(strings.NewReader("name\nAlice\nBo\xffb\n"))
+	readAll(t, r)
+
+	var parseErr *parser.ParseError
+	if !errors.As(r.Err(), &parseErr) {
+		t.Fatalf("expected ParseError, got %v", r.Err())
+	}
+	if parseErr.Line != 3 || parseErr.Column != 3 {
+		t.Errorf("expected line 3, column 3, got line %d, column %d", parseErr.Line, parseErr.Column)
+	}
+}
+
+func TestParseError_SnippetAfterLongInput(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("id,value\n")
+	for i := 0; i < 20000; i++ {
+		b.WriteString("1,aaaaaaaaaa\n")
+	}
+	b.WriteString("2,\"unterminated\n")
+
+	r := parser.NewReader(strings.NewReader(b.String()))
+	readAll(t, r)
+
+	var parseErr *parser.ParseError
+	if !errors.As(r.Err(), &parseErr) {
+		t.Fatalf("expected ParseError, got %v", r.Err())
+	}
+	if parseErr.Line != 20002 {
+		t.Errorf("expected line 20002, got %d", parseErr.Line)
+	}
+	if parseErr.Snippet != `2,"unterminated` {
+		t.Errorf("unexpected snippet %q", parseErr.Snippet)
+	}
+}
+
+func TestParseError_LongLineSnippet(t *testing.T) {
+	line := strings.Repeat("x", 200) + `,a"b` + strings.Repeat("y", 200)
+	r := parser.NewReader(strings.NewReader("a,b\n" + line + "\n"))
+	readAll(t, r)
+
+	var parseErr *parser.ParseError
+	if !errors.As(r.Err(), &parseErr) {
+		t.Fatalf("expected ParseError, got %v", r.Err())
+	}
+	if len(parseErr.Snippet) != 80 || !strings.Contains(parseErr.Snippet, `a"b`) {
+		t.Errorf("expected an 80 byte snippet around the quote, got %q", parseErr.Snippet)
+	}
+}
@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/parser"
+)
+
+func headersOf(t *testing.T, csvData string, opts parser.Options) []string {
+	t.Helper()
+
+	opts.Header = parser.HeaderPresent
+	headers, err := parser.NewReaderWithOptions(strings.NewReader(csvData), opts).Headers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return headers
+}
+
+func assertHeaders(t *testing.T, got []string, want ...string) {
+	t.Helper()
+
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("expected headers %q, got %q", want, got)
+	}
+}
+
+func TestHeaders_Duplicates(t *testing.T) {
+	got := headersOf(t, "name,name,name_2,name\n", parser.Options{})
+	assertHeaders(t, got, "name", "name_3", "name_2", "name_4")
+}
+
+func TestHeaders_Blank(t *testing.T) {
+	got := headersOf(t, "id,,  ,value\n", parser.Options{})
+	assertHeaders(t, got, "id", "column_2", "column_3", "value")
+}
+
+func TestHeaders_Trim(t *testing.T) {
+	got := headersOf(t, " id , name\n", parser.Options{TrimHeaders: true})
+	assertHeaders(t, got, "id", "name")
+}
+
+func TestHeaders_SnakeCase(t *testing.T) {
+	got := headersOf(t, "First Name,lastName,HTTPStatus,user-id,Zip2Code\n", parser.Options{HeaderCase: parser.CaseSnake})
+	assertHeaders(t, got, "first_name", "last_name", "http_status", "user_id", "zip2_code")
+}
+
+func TestHeaders_CamelCase(t *testing.T) {
+	got := headersOf(t, "First Name,last_name,HTTP status\n", parser.Options{HeaderCase: parser.CaseCamel})
+	assertHeaders(t, got, "firstName", "lastName", "httpStatus")
+}
+
+func TestHeaders_CaseCollisions(t *testing.T) {
+	got := headersOf(t, "First Name,first_name\n", parser.Options{HeaderCase: parser.CaseSnake})
+	assertHeaders(t, got, "first_name", "first_name_2")
+}
+
+func TestReader_HeaderMapping(t *testing.T) {
+	r := parser.NewReaderWithOptions(strings.NewReader("name,,name\n1,2,3\n"), parser.Options{Header: parser.HeaderPresent})
+
+	mapping := r.HeaderMapping()
+	want := []parser.Header{
+		{Index: 0, Original: "name", Name: "name"},
+		{Index: 1, Original: "", Name: "column_2"},
+		{Index: 2, Original: "name", Name: "name_2"},
+	}
+	if len(mapping) != len(want) {
+		t.Fatalf("expected %d headers, got %v", len(want), mapping)
+	}
+	for i := range want {
+		if mapping[i] != want[i] {
+			t.Errorf("header %d: expected %+v, got %+v", i, want[i], mapping[i])
+		}
+	}
+}
+
+func TestParseCSV_DuplicateHeadersKeepData(t *testing.T) {
+	result, err := parser.ParseCSV(strings.NewReader("name,name\nAlice,Smith"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result[0]["name"] != "Alice" || result[0]["name_2"] != "Smith" {
+		t.Errorf("unexpected record: %v", result[0])
+	}
+}
+
+func TestReader_ColumnNamesWithoutHeader(t *testing.T) {
+	opts := parser.Options{Header: parser.HeaderAbsent, ColumnNames: []string{"id", "name"}}
+	result, err := parser.ParseCSVWithOptions(strings.NewReader("1,Alice\n2,Bob"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 2 || result[0]["id"] != "1" || result[1]["name"] != "Bob" {
+		t.Errorf("unexpected records: %v", result)
+	}
+}
+
+func TestReader_ColumnNamesReplaceHeader(t *testing.T) {
+	opts := parser.Options{Header: parser.HeaderPresent, ColumnNames: []string{"id", "name"}}
+	result, err := parser.ParseCSVWithOptions(strings.NewReader("ID,Full Name\n1,Alice"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 1 || result[0]["name"] != "Alice" {
+		t.Errorf("unexpected records: %v", result)
+	}
+}
+
+func TestReader_ColumnNamesWidthMismatch(t *testing.T) {
+	opts := parser.Options{Header: parser.HeaderAbsent, ColumnNames: []string{"id"}}
+	_, err := parser.ParseCSVWithOptions(strings.NewReader("1,Alice"), opts)
+	if err == nil {
+		t.Fatal("expected error for rows wider than the column names")
+	}
+}
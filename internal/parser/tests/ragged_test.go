@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/parser"
+)
+
+const raggedCSV = "a,b,c\n1,2,3\n4,5\n6,7,8,9\n"
+
+func readAll(t *testing.T, r *parser.Reader) []parser.Record {
+	t.Helper()
+
+	var records []parser.Record
+	for r.Next() {
+		records = append(records, r.Record())
+	}
+	return records
+}
+
+func TestRagged_StrictReportsLocation(t *testing.T) {
+	r := parser.NewReaderWithOptions(strings.NewReader(raggedCSV), parser.Options{})
+	readAll(t, r)
+
+	var fieldErr *parser.FieldCountError
+	if !errors.As(r.Err(), &fieldErr) {
+		t.Fatalf("expected FieldCountError, got %v", r.Err())
+	}
+	if fieldErr.Line != 3 || fieldErr.Column != 4 || fieldErr.Expected != 3 || fieldErr.Got != 2 {
+		t.Errorf("unexpected error: %+v", fieldErr)
+	}
+}
+
+func TestRagged_StrictLongRow(t *testing.T) {
+	r := parser.NewReaderWithOptions(strings.NewReader("a,b\n1,2,3\n"), parser.Options{})
+	readAll(t, r)
+
+	var fieldErr *parser.FieldCountError
+	if !errors.As(r.Err(), &fieldErr) {
+		t.Fatalf("expected FieldCountError, got %v", r.Err())
+	}
+	if fieldErr.Line != 2 || fieldErr.Column != 5 {
+		t.Errorf("expected line 2, column 5, got %+v", fieldErr)
+	}
+}
+
+func TestRagged_Pad(t *testing.T) {
+	r := parser.NewReaderWithOptions(strings.NewReader("a,b,c\n1,2,3\n4,5\n"), parser.Options{Ragged: parser.RaggedPad})
+	records := readAll(t, r)
+	if r.Err() != nil {
+		t.Fatal(r.Err())
+	}
+
+	if len(records) != 2 || !records[1][2].Null {
+		t.Errorf("expected padded null field, got %v", records)
+	}
+	if lines := r.RaggedLines(); len(lines) != 1 || lines[0] != 3 {
+		t.Errorf("expected ragged line 3, got %v", lines)
+	}
+}
+
+func TestRagged_PadRejectsLongRows(t *testing.T) {
+	r := parser.NewReaderWithOptions(strings.NewReader(raggedCSV), parser.Options{Ragged: parser.RaggedPad})
+	readAll(t, r)
+
+	var fieldErr *parser.FieldCountError
+	if !errors.As(r.Err(), &fieldErr) || fieldErr.Line != 4 {
+		t.Errorf("expected FieldCountError on line 4, got %v", r.Err())
+	}
+}
+
+func TestRagged_Extra(t *testing.T) {
+	r := parser.NewReaderWithOptions(strings.NewReader(raggedCSV), parser.Options{Ragged: parser.RaggedExtra})
+
+	var extras [][]string
+	for r.Next() {
+		extras = append(extras, r.Extra())
+	}
+	if r.Err() != nil {
+		t.Fatal(r.Err())
+	}
+
+	if len(extras) != 3 || extras[0] != nil || extras[1] != nil {
+		t.Fatalf("unexpected extras: %v", extras)
+	}
+	if len(extras[2]) != 1 || extras[2][0] != "9" {
+		t.Errorf("expected extra [9], got %v", extras[2])
+	}
+	if lines := r.RaggedLines(); len(lines) != 2 || lines[0] != 3 || lines[1] != 4 {
+		t.Errorf("expected ragged lines [3 4], got %v", lines)
+	}
+}
+
+func TestRagged_ExtraColumnClash(t *testing.T) {
+	opts := parser.Options{Ragged: parser.RaggedExtra}
+	r := parser.NewReaderWithOptions(strings.NewReader("name,_extra\nAlice,x,y\n"), opts)
+	var parseErr *parser.ParseError
+	if r.Next() || !errors.As(r.Err(), &parseErr) || parseErr.Line != 1 || parseErr.Column != 6 {
+		t.Errorf("expected an error at line 1, column 6, got %v", r.Err())
+	}
+
+	opts.ColumnNames = []string{"name", "_extra"}
+	if err := opts.Validate(); err == nil {
+		t.Error("expected an error for an _extra column name")
+	}
+	opts.ColumnNames = nil
+
+	opts.Ragged = parser.RaggedPad
+	r = parser.NewReaderWithOptions(strings.NewReader("name,_extra\nAlice,x\n"), opts)
+	if !r.Next() {
+		t.Errorf("expected _extra to be an ordinary column without the extra policy, got %v", r.Err())
+	}
+}
+
+func TestRagged_Skip(t *testing.T) {
+	r := parser.NewReaderWithOptions(strings.NewReader(raggedCSV), parser.Options{Ragged: parser.RaggedSkip})
+	records := readAll(t, r)
+	if r.Err() != nil {
+		t.Fatal(r.Err())
+	}
+
+	if len(records) != 1 {
+		t.Errorf("expected 1 record, got %d", len(records))
+	}
+	if lines := r.RaggedLines(); len(lines) != 2 {
+		t.Errorf("expected 2 skipped lines, got %v", lines)
+	}
+}
+
+func TestParseRaggedPolicy(t *testing.T) {
+	for _, name := range []string{"strict", "pad", "extra", "skip"} {
+		p, err := parser.ParseRaggedPolicy(name)
+		if err != nil || p.String() != name {
+			t.Errorf("ParseRaggedPolicy(%q) = %v, %v", name, p, err)
+		}
+	}
+	if _, err := parser.ParseRaggedPolicy("loose"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
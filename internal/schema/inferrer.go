@@ -0,0 +1,221 @@
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+)
+
+// DefaultEnumLimit is the most distinct values a column may have to be
+// described with an enum.
+const DefaultEnumLimit = 10
+
+// typeOrder lists JSON types in the order they appear in a type list.
+var typeOrder = []string{"string", "number", "integer", "boolean", "null"}
+
+// Inferrer builds the schema of converted records. It looks at the JSON
+// values actually written, so it describes the output whether or not types
+// were inferred.
+type Inferrer struct {
+	// EnumLimit is the most distinct values a column may have for an enum.
+	// Zero means DefaultEnumLimit; negative disables enums.
+	EnumLimit int
+
+	columns []string
+	stats   []*columnStats
+	rows    int
+}
+
+type columnStats struct {
+	types    map[string]bool
+	dateTime bool
+	values   int
+	distinct []interface{}
+	seen     map[string]bool
+	overflow bool
+	minLen   int
+	maxLen   int
+	strings  int
+}
+
+// NewInferrer returns an Inferrer for records with the given columns.
+func NewInferrer(columns []string) *Inferrer {
+	in := &Inferrer{columns: columns, stats: make([]*columnStats, len(columns))}
+	for i := range in.stats {
+		in.stats[i] = &columnStats{types: make(map[string]bool), dateTime: true, seen: make(map[string]bool)}
+	}
+	return in
+}
+
+// Add records the values of one row, in column order. Values are those
+// written to JSON: nil, bool, json.Number or string.
+func (in *Inferrer) Add(values []interface{}) {
+	in.rows++
+	limit := in.enumLimit()
+	for i, v := range values {
+		if i >= len(in.stats) {
+			break
+		}
+		st := in.stats[i]
+
+		t := jsonType(v)
+		st.types[t] = true
+		if t == "null" {
+			continue
+		}
+		st.values++
+
+		if s, ok := v.(string); ok {
+			n := utf8.RuneCountInString(s)
+			if st.strings == 0 || n < st.minLen {
+				st.minLen = n
+			}
+			if st.strings == 0 || n > st.maxLen {
+				st.maxLen = n
+			}
+			st.strings++
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				st.dateTime = false
+			}
+		}
+
+		if !st.overflow {
+			key := t + ":" + encoder.FormatScalar(v)
+			if !st.seen[key] {
+				st.seen[key] = true
+				st.distinct = append(st.distinct, v)
+				if len(st.distinct) > limit {
+					st.overflow, st.distinct, st.seen = true, nil, nil
+				}
+			}
+		}
+	}
+}
+
+// Rows returns the number of rows added.
+func (in *Inferrer) Rows() int {
+	return in.rows
+}
+
+// Properties returns the schema of each column, in column order.
+func (in *Inferrer) Properties() []*Schema {
+	properties := make([]*Schema, len(in.columns))
+	for i, st := range in.stats {
+		properties[i] = in.property(st)
+	}
+	return properties
+}
+
+// Schema returns the schema of an array of objects with one property per
+// column, every one required since converted records always have all keys.
+// With a Nester, the properties are nested the way the records were.
+func (in *Inferrer) Schema(nester *encoder.Nester) *Schema {
+	properties := in.Properties()
+
+	var item *Schema
+	if nester != nil {
+		values := make([]interface{}, len(properties))
+		for i, p := range properties {
+			values[i] = p
+		}
+		item = nestedSchema(nester.Nest(values))
+	} else {
+		item = &Schema{Type: "object"}
+		for i, p := range properties {
+			item.AddProperty(in.columns[i], p)
+		}
+	}
+
+	return &Schema{Schema: Draft, Type: "array", Items: item}
+}
+
+func (in *Inferrer) enumLimit() int {
+	if in.EnumLimit == 0 {
+		return DefaultEnumLimit
+	}
+	return in.EnumLimit
+}
+
+func (in *Inferrer) property(st *columnStats) *Schema {
+	var types []string
+	for _, t := range typeOrder {
+		// Integers are numbers too
+		if st.types[t] && !(t == "integer" && st.types["number"]) {
+			types = append(types, t)
+		}
+	}
+
+	p := &Schema{}
+	switch len(types) {
+	case 0:
+		// No rows; anything goes
+	case 1:
+		p.Type = types[0]
+	default:
+		p.Type = types
+	}
+
+	if st.strings > 0 {
+		minLen, maxLen := st.minLen, st.maxLen
+		p.MinLength, p.MaxLength = &minLen, &maxLen
+		if st.dateTime && st.strings == st.values {
+			p.Format = "date-time"
+		}
+	}
+
+	// A column is an enum candidate when its few values repeat; booleans
+	// and date-times gain nothing from one
+	if !st.overflow && len(st.distinct) > 0 && st.values >= 2*len(st.distinct) &&
+		!st.types["boolean"] && p.Format == "" && in.enumLimit() > 0 {
+		p.Enum = append([]interface{}(nil), st.distinct...)
+		if st.types["null"] {
+			p.Enum = append(p.Enum, nil)
+		}
+	}
+	return p
+}
+
+// nestedSchema describes a value built by a Nester whose leaves are column
+// schemas.
+func nestedSchema(v interface{}) *Schema {
+	switch v := v.(type) {
+	case *Schema:
+		return v
+	case encoder.Object:
+		s := &Schema{Type: "object"}
+		for _, m := range v {
+			s.AddProperty(m.Key, nestedSchema(m.Value))
+		}
+		return s
+	case []interface{}:
+		s := &Schema{Type: "array"}
+		for _, e := range v {
+			s.PrefixItems = append(s.PrefixItems, nestedSchema(e))
+		}
+		return s
+	}
+	// A gap in an array, which is written as null
+	return &Schema{Type: "null"}
+}
+
+func jsonType(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return "number"
+		}
+		return "integer"
+	case float64, float32:
+		return "number"
+	case int, int64, int32:
+		return "integer"
+	}
+	return "string"
+}
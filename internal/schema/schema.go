@@ -0,0 +1,52 @@
+package schema
+
+import (
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+)
+
+// Draft is the JSON Schema dialect of inferred schemas.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema document, or a subschema of one. Only the
+// keywords used by inference are supported.
+type Schema struct {
+	Schema string `json:"$schema,omitempty"`
+	// Type is a type name, or a list of them when values vary.
+	Type      interface{}   `json:"type,omitempty"`
+	Format    string        `json:"format,omitempty"`
+	Enum      []interface{} `json:"enum,omitempty"`
+	MinLength *int          `json:"minLength,omitempty"`
+	MaxLength *int          `json:"maxLength,omitempty"`
+	// Properties holds a *Schema per member, in column order.
+	Properties  encoder.Object `json:"properties,omitempty"`
+	Required    []string       `json:"required,omitempty"`
+	Items       *Schema        `json:"items,omitempty"`
+	PrefixItems []*Schema      `json:"prefixItems,omitempty"`
+}
+
+// Property returns the schema of the named property, or nil.
+func (s *Schema) Property(name string) *Schema {
+	for _, m := range s.Properties {
+		if m.Key == name {
+			return m.Value.(*Schema)
+		}
+	}
+	return nil
+}
+
+// AddProperty appends a required property to an object schema.
+func (s *Schema) AddProperty(name string, property *Schema) {
+	s.Properties = append(s.Properties, encoder.Member{Key: name, Value: property})
+	s.Required = append(s.Required, name)
+}
+
+// Types returns the type names of s.
+func (s *Schema) Types() []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+	"github.com/agileproject-gurpreet/csv2json/internal/schema"
+)
+
+func marshal(t *testing.T, v interface{}) string {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestInferrer_TypesAndNullability(t *testing.T) {
+	in := schema.NewInferrer([]string{"id", "price", "active", "note", "empty"})
+	in.EnumLimit = -1
+	in.Add([]interface{}{json.Number("1"), json.Number("9"), true, "hi", nil})
+	in.Add([]interface{}{json.Number("2"), json.Number("9.5"), false, nil, nil})
+
+	got := marshal(t, in.Schema(nil))
+	want := `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"array","items":{"type":"object",` +
+		`"properties":{"id":{"type":"integer"},"price":{"type":"number"},"active":{"type":"boolean"},` +
+		`"note":{"type":["string","null"],"minLength":2,"maxLength":2},"empty":{"type":"null"}},` +
+		`"required":["id","price","active","note","empty"]}}`
+	if got != want {
+		t.Errorf("expected %s\ngot %s", want, got)
+	}
+}
+
+func TestInferrer_Enum(t *testing.T) {
+	in := schema.NewInferrer([]string{"status", "name"})
+	for _, row := range [][]interface{}{
+		{"open", "Alice"}, {"closed", "Bob"}, {"open", "Carol"}, {nil, "Dave"}, {"closed", "Eve"},
+	} {
+		in.Add(row)
+	}
+
+	properties := in.Properties()
+	if got := marshal(t, properties[0].Enum); got != `["open","closed",null]` {
+		t.Errorf("unexpected status enum %s", got)
+	}
+	if properties[1].Enum != nil {
+		t.Errorf("expected no enum for distinct names, got %v", properties[1].Enum)
+	}
+	if *properties[1].MinLength != 3 || *properties[1].MaxLength != 5 {
+		t.Errorf("unexpected name lengths %d-%d", *properties[1].MinLength, *properties[1].MaxLength)
+	}
+}
+
+func TestInferrer_EnumLimit(t *testing.T) {
+	in := schema.NewInferrer([]string{"code"})
+	in.EnumLimit = 2
+	for _, code := range []string{"a", "b", "c", "a", "b", "c", "a"} {
+		in.Add([]interface{}{code})
+	}
+
+	if p := in.Properties()[0]; p.Enum != nil {
+		t.Errorf("expected no enum above the limit, got %v", p.Enum)
+	}
+}
+
+func TestInferrer_DateTimeAndMixedTypes(t *testing.T) {
+	in := schema.NewInferrer([]string{"at", "mixed"})
+	in.Add([]interface{}{"2026-01-02T03:04:05Z", json.Number("1")})
+	in.Add([]interface{}{"2026-01-03T03:04:05.5+01:00", "n/a"})
+
+	properties := in.Properties()
+	if properties[0].Format != "date-time" || properties[0].Type != "string" {
+		t.Errorf("unexpected timestamp schema %s", marshal(t, properties[0]))
+	}
+	if got := marshal(t, properties[1].Type); got != `["string","integer"]` {
+		t.Errorf("unexpected mixed type %s", got)
+	}
+}
+
+func TestInferrer_Nested(t *testing.T) {
+	columns := []string{"address.city", "tags[0]", "tags[2]"}
+	nester, err := encoder.NewNester(columns, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := schema.NewInferrer(columns)
+	in.EnumLimit = -1
+	in.Add([]interface{}{"Paris", "a", "c"})
+
+	got := marshal(t, in.Schema(nester).Items)
+	want := `{"type":"object","properties":{"address":{"type":"object","properties":{"city":{"type":"string","minLength":5,"maxLength":5}},"required":["city"]},` +
+		`"tags":{"type":"array","prefixItems":[{"type":"string","minLength":1,"maxLength":1},{"type":"null"},{"type":"string","minLength":1,"maxLength":1}]}},` +
+		`"required":["address","tags"]}`
+	if got != want {
+		t.Errorf("expected %s\ngot %s", want, got)
+	}
+}
@@ -1,25 +1,41 @@
 package service
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 
-	"github.com/agileproject-gurpreet/csv2json/internal/database"
-	"github.com/agileproject-gurpreet/csv2json/internal/parser"
+	"github.com/agileproject-gurpreet/csv2json/internal/converter"
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+	"github.com/agileproject-gurpreet/csv2json/internal/storage"
+	"github.com/agileproject-gurpreet/csv2json/internal/validate"
 )
 
 type ConversionService struct {
-	db *database.PostgresDB
+	store storage.Store
+	// rows also saves each record of an upload on its own
+	rows bool
 }
 
-func NewConversionService(db *database.PostgresDB) *ConversionService {
+// NewConversionService returns a service saving uploads to store. With a nil
+// store nothing is saved and the stored data methods fail
+func NewConversionService(store storage.Store) *ConversionService {
 	return &ConversionService{
-		db: db,
+		store: store,
 	}
 }
 
+// SetStoreRows sets whether uploads are also saved one row per record, so
+// that GetRows and GetRow can read them without loading the whole upload.
+// Their data is then stored twice
+func (s *ConversionService) SetStoreRows(enabled bool) {
+	s.rows = enabled
+}
+
 // ProcessCSVFile reads a CSV file and converts it to JSON
 func (s *ConversionService) ProcessCSVFile(filePath string) ([]byte, error) {
 	// Open the CSV file
@@ -29,19 +45,12 @@ func (s *ConversionService) ProcessCSVFile(filePath string) ([]byte, error) {
 	}
 	defer file.Close()
 
-	// Parse CSV
-	records, err := parser.ParseCSV(file)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse CSV: %w", err)
+	var buf bytes.Buffer
+	if err := s.StreamCSV(file, &buf); err != nil {
+		return nil, err
 	}
 
-	// Convert to JSON
-	jsonData, err := json.Marshal(records)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal to JSON: %w", err)
-	}
-
-	return jsonData, nil
+	return buf.Bytes(), nil
 }
 
 // ProcessCSVReader reads a CSV from an io.Reader and converts it to JSON
@@ -51,42 +60,373 @@ func (s *ConversionService) ProcessCSVReader(r io.Reader) ([]byte, error) {
 
 // ProcessCSVReaderWithFilename reads a CSV from an io.Reader, converts it to JSON, and saves to database
 func (s *ConversionService) ProcessCSVReaderWithFilename(r io.Reader, filename string) ([]byte, error) {
-	// Parse CSV
-	records, err := parser.ParseCSV(r)
+	jsonData, _, err := s.ProcessCSVReaderWithOptions(r, filename, converter.Options{})
+	return jsonData, err
+}
+
+// ProcessCSVReaderWithOptions converts a CSV from an io.Reader using opts, saves it to
+// the database, and reports how the input was read. With opts.Validation in reject
+// mode, an upload with violations fails with *validate.Error and is not saved
+func (s *ConversionService) ProcessCSVReaderWithOptions(r io.Reader, filename string, opts converter.Options) ([]byte, *converter.Result, error) {
+	return s.ProcessCSVReaderWithOptionsContext(context.Background(), r, filename, opts)
+}
+
+// ProcessCSVReaderWithOptionsContext is ProcessCSVReaderWithOptions with a context. Once
+// ctx is done, conversion stops at the next record and nothing is saved
+func (s *ConversionService) ProcessCSVReaderWithOptionsContext(ctx context.Context, r io.Reader, filename string, opts converter.Options) ([]byte, *converter.Result, error) {
+	// Stored uploads keep the schema of their data
+	opts.Schema = opts.Schema || s.store != nil
+
+	var buf bytes.Buffer
+	result, err := s.ConvertCSVContext(ctx, r, &buf, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse CSV: %w", err)
+		return nil, nil, err
+	}
+	jsonData := buf.Bytes()
+
+	// Save to the store, if one is configured
+	if err := s.save(ctx, filename, jsonData, result, opts.Format); err != nil {
+		return nil, nil, err
 	}
 
-	// Convert to JSON
-	jsonData, err := json.Marshal(records)
+	return jsonData, result, nil
+}
+
+// ProcessCSVStream converts a CSV from r, writing each record to w as soon as
+// it is read, and saves the result to the database once the input is
+// complete. Nothing is saved if conversion fails, including on violations in
+// validation reject mode; w may then hold partial or complete output.
+func (s *ConversionService) ProcessCSVStream(r io.Reader, w io.Writer, filename string, opts converter.Options) (*converter.Result, error) {
+	return s.ProcessCSVStreamContext(context.Background(), r, w, filename, opts)
+}
+
+// ProcessCSVStreamContext is ProcessCSVStream with a context. Once ctx is done,
+// conversion stops at the next record and nothing is saved
+func (s *ConversionService) ProcessCSVStreamContext(ctx context.Context, r io.Reader, w io.Writer, filename string, opts converter.Options) (*converter.Result, error) {
+	if s.store == nil {
+		return s.ConvertCSVContext(ctx, r, w, opts)
+	}
+
+	// Keep a copy for the database, which needs the complete document. It is
+	// spooled to a temporary file, so memory does not grow with the output
+	// while it streams; only the insert reads it back
+	opts.Schema = true
+	spool, err := os.CreateTemp("", "csv2json-*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	spooled := bufio.NewWriter(spool)
+	result, err := s.ConvertCSVContext(ctx, r, io.MultiWriter(w, spooled), opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := spooled.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write spool file: %w", err)
+	}
+
+	data, err := os.ReadFile(spool.Name())
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal to JSON: %w", err)
+		return nil, fmt.Errorf("failed to read spool file: %w", err)
+	}
+	if err := s.save(ctx, filename, data, result, opts.Format); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// save stores converted output as a JSON array, if a store is available.
+func (s *ConversionService) save(ctx context.Context, filename string, data []byte, result *converter.Result, format encoder.Format) error {
+	if s.store == nil {
+		return nil
 	}
 
-	// Save to database if db is available
-	if s.db != nil {
-		if err := s.db.InsertCSVData(filename, records); err != nil {
-			return nil, fmt.Errorf("failed to save to database: %w", err)
+	if format == encoder.FormatNDJSON {
+		data = encoder.LinesToArray(data)
+	}
+	var inferred []byte
+	if result.Schema != nil {
+		var err error
+		if inferred, err = json.Marshal(result.Schema); err != nil {
+			return fmt.Errorf("failed to encode schema: %w", err)
 		}
 	}
+	// Nested key order is kept with the top-level one, as JSON Pointers
+	columns := result.Columns
+	if len(result.Pointers) > 0 {
+		columns = append(append([]string(nil), columns...), result.Pointers...)
+	}
+	upload := &storage.Upload{Filename: filename, Data: data, Columns: columns, Schema: inferred}
+	insert := s.store.Insert
+	if s.rows {
+		insert = s.store.InsertWithRows
+	}
+	if _, err := insert(ctx, upload); err != nil {
+		return fmt.Errorf("failed to save to database: %w", err)
+	}
+	return nil
+}
+
+// StreamCSV converts a CSV from r into a JSON array written to w one record
+// at a time. Nothing is persisted. On error, w may hold a partial array.
+// Use ConvertCSV with converter.Options.Format for NDJSON.
+func (s *ConversionService) StreamCSV(r io.Reader, w io.Writer) error {
+	_, err := s.ConvertCSV(r, w, converter.Options{})
+	return err
+}
+
+// ConvertCSV is StreamCSV with options; it reports how the input was read.
+func (s *ConversionService) ConvertCSV(r io.Reader, w io.Writer, opts converter.Options) (*converter.Result, error) {
+	return s.ConvertCSVContext(context.Background(), r, w, opts)
+}
+
+// ConvertCSVContext is ConvertCSV with a context; conversion stops at the next
+// record once ctx is done, with an error wrapping ctx.Err().
+func (s *ConversionService) ConvertCSVContext(ctx context.Context, r io.Reader, w io.Writer, opts converter.Options) (*converter.Result, error) {
+	return converter.ConvertContext(ctx, r, w, opts)
+}
+
+// ConvertJSONToCSV converts a JSON array of objects, or NDJSON, from r to CSV
+// written to w. Nothing is persisted.
+func (s *ConversionService) ConvertJSONToCSV(r io.Reader, w io.Writer, opts converter.CSVOptions) (*converter.CSVResult, error) {
+	return s.ConvertJSONToCSVContext(context.Background(), r, w, opts)
+}
+
+// ConvertJSONToCSVContext is ConvertJSONToCSV with a context.
+func (s *ConversionService) ConvertJSONToCSVContext(ctx context.Context, r io.Reader, w io.Writer, opts converter.CSVOptions) (*converter.CSVResult, error) {
+	return converter.ConvertJSONContext(ctx, r, w, opts)
+}
+
+// InferSchema converts a CSV from r without keeping the output and returns
+// the JSON Schema of the records it would produce. Nothing is persisted.
+func (s *ConversionService) InferSchema(r io.Reader, opts converter.Options) (*converter.Result, error) {
+	return s.InferSchemaContext(context.Background(), r, opts)
+}
+
+// InferSchemaContext is InferSchema with a context.
+func (s *ConversionService) InferSchemaContext(ctx context.Context, r io.Reader, opts converter.Options) (*converter.Result, error) {
+	opts.Schema = true
+	return converter.ConvertContext(ctx, r, io.Discard, opts)
+}
 
-	return jsonData, nil
+// ValidateCSV converts a CSV from r without keeping the output and returns
+// the violations of opts.Validation. Nothing is persisted.
+func (s *ConversionService) ValidateCSV(r io.Reader, opts converter.Options) (*validate.Report, error) {
+	return s.ValidateCSVContext(context.Background(), r, opts)
+}
+
+// ValidateCSVContext is ValidateCSV with a context.
+func (s *ConversionService) ValidateCSVContext(ctx context.Context, r io.Reader, opts converter.Options) (*validate.Report, error) {
+	if opts.Validation == nil {
+		return nil, fmt.Errorf("no validation spec")
+	}
+	opts.ValidationMode = validate.ModeReport
+	result, err := converter.ConvertContext(ctx, r, io.Discard, opts)
+	if err != nil {
+		return nil, err
+	}
+	return result.Validation, nil
 }
 
 // GetAllData retrieves all CSV data from the database
 func (s *ConversionService) GetAllData() ([]map[string]interface{}, error) {
-	if s.db == nil {
+	return s.GetAllDataContext(context.Background())
+}
+
+// GetAllDataContext is GetAllData with a context
+func (s *ConversionService) GetAllDataContext(ctx context.Context) ([]map[string]interface{}, error) {
+	if s.store == nil {
 		return nil, fmt.Errorf("database not initialized")
 	}
 
-	return s.db.GetAllCSVData()
+	uploads, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return records(uploads)
 }
 
 // GetDataByID retrieves CSV data by ID from the database
 func (s *ConversionService) GetDataByID(id int) (map[string]interface{}, error) {
-	if s.db == nil {
+	return s.GetDataByIDContext(context.Background(), id)
+}
+
+// GetDataByIDContext is GetDataByID with a context
+func (s *ConversionService) GetDataByIDContext(ctx context.Context, id int) (map[string]interface{}, error) {
+	if s.store == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	upload, err := s.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return upload.Record()
+}
+
+// QueryData retrieves the stored CSV data selected by q, newest first
+func (s *ConversionService) QueryData(q storage.Query) ([]map[string]interface{}, error) {
+	return s.QueryDataContext(context.Background(), q)
+}
+
+// QueryDataContext is QueryData with a context
+func (s *ConversionService) QueryDataContext(ctx context.Context, q storage.Query) ([]map[string]interface{}, error) {
+	if s.store == nil {
 		return nil, fmt.Errorf("database not initialized")
 	}
 
-	return s.db.GetCSVDataByID(id)
+	uploads, err := s.store.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return records(uploads)
+}
+
+// DataPage is one page of a listing of stored CSV data.
+type DataPage struct {
+	Items []map[string]interface{} `json:"items"`
+	// Total is the number of records matching the filters, on every page.
+	Total int `json:"total"`
+	// NextCursor continues the listing after Items; empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListData returns a page of the stored CSV data selected by q, with the
+// total number of matches and a cursor for the next page when q has a Limit
+func (s *ConversionService) ListData(q storage.Query) (*DataPage, error) {
+	return s.ListDataContext(context.Background(), q)
+}
+
+// ListDataContext is ListData with a context
+func (s *ConversionService) ListDataContext(ctx context.Context, q storage.Query) (*DataPage, error) {
+	if s.store == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	// One upload more than the page tells whether there is a next one
+	limit := q.Limit
+	if limit > 0 {
+		q.Limit++
+	}
+	uploads, err := s.store.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.store.Count(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &DataPage{Total: total}
+	if limit > 0 && len(uploads) > limit {
+		uploads = uploads[:limit]
+		page.NextCursor = q.CursorAfter(&uploads[limit-1]).Encode()
+	}
+	if page.Items, err = records(uploads); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// DeleteData removes the CSV data stored under id; a missing id is
+// storage.ErrNotFound
+func (s *ConversionService) DeleteData(id int) error {
+	return s.DeleteDataContext(context.Background(), id)
+}
+
+// DeleteDataContext is DeleteData with a context
+func (s *ConversionService) DeleteDataContext(ctx context.Context, id int) error {
+	if s.store == nil {
+		return fmt.Errorf("database not initialized")
+	}
+
+	return s.store.Delete(ctx, id)
+}
+
+// GetRows returns the rows of the upload stored under id numbered from start,
+// at most limit of them unless it is 0. Only uploads saved with SetStoreRows
+// have rows; a missing id is storage.ErrNotFound
+func (s *ConversionService) GetRows(id, start, limit int) ([]map[string]interface{}, error) {
+	return s.GetRowsContext(context.Background(), id, start, limit)
+}
+
+// GetRowsContext is GetRows with a context
+func (s *ConversionService) GetRowsContext(ctx context.Context, id, start, limit int) ([]map[string]interface{}, error) {
+	if s.store == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	rows, err := s.store.GetRows(ctx, id, start, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]map[string]interface{}, 0, len(rows))
+	for _, r := range rows {
+		record, err := r.Record()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, record)
+	}
+	return result, nil
+}
+
+// GetRow returns row n of the upload stored under id; a missing upload or
+// row is storage.ErrNotFound
+func (s *ConversionService) GetRow(id, n int) (map[string]interface{}, error) {
+	return s.GetRowContext(context.Background(), id, n)
+}
+
+// GetRowContext is GetRow with a context
+func (s *ConversionService) GetRowContext(ctx context.Context, id, n int) (map[string]interface{}, error) {
+	if s.store == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+
+	row, err := s.store.GetRow(ctx, id, n)
+	if err != nil {
+		return nil, err
+	}
+	return row.Record()
+}
+
+// records presents stored uploads as the API returns them.
+func records(uploads []storage.Upload) ([]map[string]interface{}, error) {
+	results := make([]map[string]interface{}, 0, len(uploads))
+	for i := range uploads {
+		record, err := uploads[i].Record()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, record)
+	}
+	return results, nil
+}
+
+// GetDataByIDAsCSV writes the data stored under id to w as CSV and returns
+// the filename it was uploaded as
+func (s *ConversionService) GetDataByIDAsCSV(id int, w io.Writer, opts converter.CSVOptions) (string, error) {
+	return s.GetDataByIDAsCSVContext(context.Background(), id, w, opts)
+}
+
+// GetDataByIDAsCSVContext is GetDataByIDAsCSV with a context
+func (s *ConversionService) GetDataByIDAsCSVContext(ctx context.Context, id int, w io.Writer, opts converter.CSVOptions) (string, error) {
+	record, err := s.GetDataByIDContext(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(record["data"])
+	if err != nil {
+		return "", fmt.Errorf("failed to encode stored data: %w", err)
+	}
+	if _, err := converter.ConvertJSONContext(ctx, bytes.NewReader(data), w, opts); err != nil {
+		return "", err
+	}
+
+	filename, _ := record["filename"].(string)
+	return filename, nil
 }
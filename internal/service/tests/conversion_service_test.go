@@ -1,12 +1,25 @@
 package tests
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/agileproject-gurpreet/csv2json/internal/converter"
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+	"github.com/agileproject-gurpreet/csv2json/internal/infer"
+	"github.com/agileproject-gurpreet/csv2json/internal/parser"
 	"github.com/agileproject-gurpreet/csv2json/internal/service"
+	"github.com/agileproject-gurpreet/csv2json/internal/storage"
+	"github.com/agileproject-gurpreet/csv2json/internal/validate"
 )
 
 // TestNewConversionService tests service creation
@@ -527,3 +540,775 @@ func TestProcessCSVReader_QuotedFields(t *testing.T) {
 		t.Errorf("expected 'John Doe', got %s", result[0]["name"])
 	}
 }
+
+// TestStreamCSV_Success tests streaming conversion to a writer
+func TestStreamCSV_Success(t *testing.T) {
+	svc := service.NewConversionService(nil)
+
+	var buf bytes.Buffer
+	if err := svc.StreamCSV(strings.NewReader("name,age\nAlice,30\nBob,25"), &buf); err != nil {
+		t.Fatalf("StreamCSV failed: %v", err)
+	}
+
+	var result []map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatalf("failed to unmarshal JSON: %v", err)
+	}
+
+	if len(result) != 2 || result[1]["name"] != "Bob" {
+		t.Errorf("unexpected result: %v", result)
+	}
+}
+
+// signalWriter notifies on its first write
+type signalWriter struct {
+	once    sync.Once
+	written chan struct{}
+}
+
+func (w *signalWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.written) })
+	return len(p), nil
+}
+
+// TestStreamCSV_WritesBeforeInputEnds tests that records are emitted as they are read
+func TestStreamCSV_WritesBeforeInputEnds(t *testing.T) {
+	svc := service.NewConversionService(nil)
+
+	pr, pw := io.Pipe()
+	out := &signalWriter{written: make(chan struct{})}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- svc.StreamCSV(pr, out)
+	}()
+
+	io.WriteString(pw, "name,age\nAlice,30\n")
+
+	select {
+	case <-out.written:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected output before input was closed")
+	}
+
+	pw.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("StreamCSV failed: %v", err)
+	}
+}
+
+// TestProcessCSVReaderWithOptions_ReportsDialect tests that the detected dialect is returned
+func TestProcessCSVReaderWithOptions_ReportsDialect(t *testing.T) {
+	svc := service.NewConversionService(nil)
+
+	_, result, err := svc.ProcessCSVReaderWithOptions(strings.NewReader("name|age\nAlice|30"), "test.csv", converter.Options{})
+	if err != nil {
+		t.Fatalf("ProcessCSVReaderWithOptions failed: %v", err)
+	}
+
+	if result.Dialect.Delimiter != '|' || !result.Dialect.HasHeader {
+		t.Errorf("unexpected dialect: %+v", result.Dialect)
+	}
+	if result.Rows != 1 {
+		t.Errorf("expected 1 row, got %d", result.Rows)
+	}
+}
+
+// TestConvertCSV_InferTypes tests native JSON types in the output
+func TestConvertCSV_InferTypes(t *testing.T) {
+	svc := service.NewConversionService(nil)
+
+	csvData := "name,age,price,active,joined,note\nAlice,30,19.99,true,2026-01-28T10:30:00Z,\nBob,,5,false,2026-01-29T08:00:00Z,"
+	var buf bytes.Buffer
+	result, err := svc.ConvertCSV(strings.NewReader(csvData), &buf, converter.Options{InferTypes: true})
+	if err != nil {
+		t.Fatalf("ConvertCSV failed: %v", err)
+	}
+
+	var records []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &records); err != nil {
+		t.Fatalf("failed to unmarshal JSON: %v", err)
+	}
+
+	if records[0]["age"] != float64(30) {
+		t.Errorf("expected age=30 as number, got %#v", records[0]["age"])
+	}
+	if records[1]["age"] != nil {
+		t.Errorf("expected null age, got %#v", records[1]["age"])
+	}
+	if records[0]["price"] != 19.99 {
+		t.Errorf("expected price=19.99 as number, got %#v", records[0]["price"])
+	}
+	if records[1]["active"] != false {
+		t.Errorf("expected active=false as boolean, got %#v", records[1]["active"])
+	}
+	if records[0]["joined"] != "2026-01-28T10:30:00Z" {
+		t.Errorf("expected timestamp string, got %#v", records[0]["joined"])
+	}
+	if records[0]["note"] != nil {
+		t.Errorf("expected null note, got %#v", records[0]["note"])
+	}
+
+	if result.Types["price"] != infer.Number || result.Types["age"] != infer.Integer {
+		t.Errorf("unexpected column types: %v", result.Types)
+	}
+}
+
+// TestConvertCSV_ColumnTypeOverride tests per-column type overrides
+func TestConvertCSV_ColumnTypeOverride(t *testing.T) {
+	svc := service.NewConversionService(nil)
+
+	opts := converter.Options{
+		InferTypes:  true,
+		ColumnTypes: map[string]infer.Type{"zip": infer.String, "qty": infer.Number},
+	}
+	var buf bytes.Buffer
+	if _, err := svc.ConvertCSV(strings.NewReader("zip,qty\n12345,2"), &buf, opts); err != nil {
+		t.Fatalf("ConvertCSV failed: %v", err)
+	}
+
+	var records []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &records); err != nil {
+		t.Fatalf("failed to unmarshal JSON: %v", err)
+	}
+	if records[0]["zip"] != "12345" || records[0]["qty"] != float64(2) {
+		t.Errorf("unexpected record: %v", records[0])
+	}
+}
+
+// TestConvertCSV_ColumnTypeOverrideMismatch tests that overridden columns must convert
+func TestConvertCSV_ColumnTypeOverrideMismatch(t *testing.T) {
+	svc := service.NewConversionService(nil)
+
+	opts := converter.Options{ColumnTypes: map[string]infer.Type{"qty": infer.Integer}}
+	_, err := svc.ConvertCSV(strings.NewReader("qty\n2\nmany"), io.Discard, opts)
+	if err == nil {
+		t.Fatal("expected error for non-integer value")
+	}
+	if !strings.Contains(err.Error(), "row 2") {
+		t.Errorf("expected row number in error, got: %v", err)
+	}
+}
+
+// TestProcessCSVReader_PreservesColumnOrder tests that keys follow the header row
+func TestProcessCSVReader_PreservesColumnOrder(t *testing.T) {
+	svc := service.NewConversionService(nil)
+
+	jsonData, err := svc.ProcessCSVReader(strings.NewReader("zeta,alpha,mid\n1,2,3"))
+	if err != nil {
+		t.Fatalf("ProcessCSVReader failed: %v", err)
+	}
+
+	want := `[{"zeta":"1","alpha":"2","mid":"3"}]`
+	if string(jsonData) != want {
+		t.Errorf("expected %s, got %s", want, jsonData)
+	}
+}
+
+// TestConvertCSV_RaggedExtra tests padding and _extra collection for ragged rows
+func TestConvertCSV_RaggedExtra(t *testing.T) {
+	svc := service.NewConversionService(nil)
+
+	opts := converter.Options{Parser: parser.Options{Ragged: parser.RaggedExtra}}
+	var buf bytes.Buffer
+	result, err := svc.ConvertCSV(strings.NewReader("a,b\n1\n2,3,4,5\n"), &buf, opts)
+	if err != nil {
+		t.Fatalf("ConvertCSV failed: %v", err)
+	}
+
+	want := `[{"a":"1","b":null},{"a":"2","b":"3","_extra":["4","5"]}]`
+	if buf.String() != want {
+		t.Errorf("expected %s, got %s", want, buf.String())
+	}
+	if result.Ragged != parser.RaggedExtra || len(result.RaggedLines) != 2 {
+		t.Errorf("unexpected ragged report: %v %v", result.Ragged, result.RaggedLines)
+	}
+}
+
+// TestConvertCSV_Arrays tests headerless input written as JSON arrays
+func TestConvertCSV_Arrays(t *testing.T) {
+	svc := service.NewConversionService(nil)
+
+	opts := converter.Options{
+		Parser:     parser.Options{Header: parser.HeaderAbsent},
+		Arrays:     true,
+		InferTypes: true,
+	}
+	var buf bytes.Buffer
+	result, err := svc.ConvertCSV(strings.NewReader("1,Alice\n2,Bob"), &buf, opts)
+	if err != nil {
+		t.Fatalf("ConvertCSV failed: %v", err)
+	}
+
+	want := `[[1,"Alice"],[2,"Bob"]]`
+	if buf.String() != want {
+		t.Errorf("expected %s, got %s", want, buf.String())
+	}
+	if len(result.Columns) != 2 || result.Columns[0] != "column_1" {
+		t.Errorf("unexpected columns: %v", result.Columns)
+	}
+}
+
+// rowGenerator produces a CSV of n rows on demand, so large inputs never
+// exist in memory as a whole
+type rowGenerator struct {
+	n, produced int
+	pending     []byte
+}
+
+func (g *rowGenerator) Read(p []byte) (int, error) {
+	for len(g.pending) < len(p) && g.produced <= g.n {
+		if g.produced == 0 {
+			g.pending = append(g.pending, "id,name,score\n"...)
+		} else {
+			g.pending = append(g.pending, fmt.Sprintf("%d,user-%d,%d.5\n", g.produced, g.produced, g.produced%100)...)
+		}
+		g.produced++
+	}
+	if len(g.pending) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, g.pending)
+	g.pending = g.pending[n:]
+	return n, nil
+}
+
+// lineCounter counts output lines without keeping them, noting how much
+// input had been generated when the first line arrived
+type lineCounter struct {
+	input      *rowGenerator
+	lines      int
+	bytes      int
+	firstWrite int
+	last       []byte
+}
+
+func (c *lineCounter) Write(p []byte) (int, error) {
+	if c.bytes == 0 {
+		c.firstWrite = c.input.produced
+	}
+	c.lines += bytes.Count(p, []byte{'\n'})
+	c.bytes += len(p)
+	c.last = append(c.last[:0], p...)
+	return len(p), nil
+}
+
+// TestConvertCSV_NDJSONLargeInput tests that NDJSON is written line by line as a large input is read
+func TestConvertCSV_NDJSONLargeInput(t *testing.T) {
+	svc := service.NewConversionService(nil)
+
+	const rows = 200000
+	input := &rowGenerator{n: rows}
+	out := &lineCounter{input: input}
+
+	opts := converter.Options{InferTypes: true, Format: encoder.FormatNDJSON}
+	result, err := svc.ConvertCSV(input, out, opts)
+	if err != nil {
+		t.Fatalf("ConvertCSV failed: %v", err)
+	}
+
+	if out.lines != rows || result.Rows != rows {
+		t.Errorf("expected %d lines, got %d (result rows %d)", rows, out.lines, result.Rows)
+	}
+	// Inference holds back the first DefaultInferRows rows; allow for the
+	// input read ahead of them
+	if limit := converter.DefaultInferRows + 500; out.firstWrite > limit {
+		t.Errorf("expected output within %d rows of input, first write after %d rows", limit, out.firstWrite)
+	}
+	if want := fmt.Sprintf("{\"id\":%d,\"name\":\"user-%d\",\"score\":%d.5}\n", rows, rows, rows%100); !strings.HasSuffix(string(out.last), want) {
+		t.Errorf("expected last line %q, got %q", want, out.last)
+	}
+}
+
+// cancelAfter cancels its context once it has been written n lines
+type cancelAfter struct {
+	lineCounter
+	n      int
+	cancel context.CancelFunc
+}
+
+func (c *cancelAfter) Write(p []byte) (int, error) {
+	n, err := c.lineCounter.Write(p)
+	if c.lines >= c.n {
+		c.cancel()
+	}
+	return n, err
+}
+
+// TestConvertCSVContext_CancelMidFile tests that cancelling stops parsing partway through the input
+func TestConvertCSVContext_CancelMidFile(t *testing.T) {
+	svc := service.NewConversionService(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const rows = 10000000
+	input := &rowGenerator{n: rows}
+	out := &cancelAfter{lineCounter: lineCounter{input: input}, n: 1000, cancel: cancel}
+
+	opts := converter.Options{InferTypes: true, Format: encoder.FormatNDJSON}
+	_, err := svc.ConvertCSVContext(ctx, input, out, opts)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	if input.produced >= rows/100 {
+		t.Errorf("expected parsing to stop soon after cancellation, %d of %d rows read", input.produced, rows)
+	}
+	if out.lines < 1000 {
+		t.Errorf("expected at least 1000 lines before cancellation, got %d", out.lines)
+	}
+}
+
+// TestProcessCSVReaderWithOptionsContext_Deadline tests that an expired deadline fails the conversion
+func TestProcessCSVReaderWithOptionsContext_Deadline(t *testing.T) {
+	svc := service.NewConversionService(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	_, _, err := svc.ProcessCSVReaderWithOptionsContext(ctx, strings.NewReader("name\nAlice"), "test.csv", converter.Options{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+// TestConvertJSONToCSVContext_Cancelled tests that a cancelled context stops JSON to CSV conversion
+func TestConvertJSONToCSVContext_Cancelled(t *testing.T) {
+	svc := service.NewConversionService(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var buf bytes.Buffer
+	_, err := svc.ConvertJSONToCSVContext(ctx, strings.NewReader(`[{"a":1}]`), &buf, converter.CSVOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+// TestProcessCSVStream_NoDatabase tests that ProcessCSVStream writes NDJSON straight to the writer
+func TestProcessCSVStream_NoDatabase(t *testing.T) {
+	svc := service.NewConversionService(nil)
+
+	var buf bytes.Buffer
+	opts := converter.Options{Format: encoder.FormatNDJSON}
+	result, err := svc.ProcessCSVStream(strings.NewReader("name,age\nAlice,30\nBob,25"), &buf, "test.csv", opts)
+	if err != nil {
+		t.Fatalf("ProcessCSVStream failed: %v", err)
+	}
+
+	if want := "{\"name\":\"Alice\",\"age\":\"30\"}\n{\"name\":\"Bob\",\"age\":\"25\"}\n"; buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+	if result.Rows != 2 {
+		t.Errorf("expected 2 rows, got %d", result.Rows)
+	}
+}
+
+// TestProcessCSVReaderWithOptions_ValidationReject tests that violations fail the upload
+func TestProcessCSVReaderWithOptions_ValidationReject(t *testing.T) {
+	svc := service.NewConversionService(nil)
+	spec, err := validate.ParseSpec([]byte(`{"columns": {"age": {"min": 0}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csv := "name,age\nAlice,30\nBob,-1\n"
+	_, _, err = svc.ProcessCSVReaderWithOptions(strings.NewReader(csv), "people.csv", converter.Options{Validation: spec})
+
+	var validationErr *validate.Error
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected *validate.Error, got %v", err)
+	}
+	if v := validationErr.Report.Violations; len(v) != 1 || v[0].Row != 2 || v[0].Line != 3 || v[0].Rule != "min" {
+		t.Errorf("unexpected violations %+v", v)
+	}
+}
+
+// TestProcessCSVReaderWithOptions_ValidationReport tests that report mode keeps the output
+func TestProcessCSVReaderWithOptions_ValidationReport(t *testing.T) {
+	svc := service.NewConversionService(nil)
+	spec, err := validate.ParseSpec([]byte(`{"properties": {"age": {"type": "integer"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csv := "name,age\nAlice,30\nBob,n/a\n"
+	opts := converter.Options{InferTypes: true, InferRows: 1, Validation: spec, ValidationMode: validate.ModeReport}
+	jsonData, result, err := svc.ProcessCSVReaderWithOptions(strings.NewReader(csv), "people.csv", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `[{"name":"Alice","age":30},{"name":"Bob","age":"n/a"}]`; string(jsonData) != want {
+		t.Errorf("expected %s, got %s", want, jsonData)
+	}
+	if r := result.Validation; r.InvalidRows != 1 || r.Violations[0].Field != "age" || r.Violations[0].Value != "n/a" {
+		t.Errorf("unexpected report %+v", r)
+	}
+}
+
+// TestValidateCSV tests validation without keeping the output
+func TestValidateCSV(t *testing.T) {
+	svc := service.NewConversionService(nil)
+	spec, err := validate.ParseSpec([]byte(`{"columns": {"id": {"unique": true}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := svc.ValidateCSV(strings.NewReader("id\n1\n2\n1\n"), converter.Options{Validation: spec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Rows != 3 || report.Total != 1 || report.Violations[0].Row != 3 {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	if _, err := svc.ValidateCSV(strings.NewReader("id\n1\n"), converter.Options{}); err == nil {
+		t.Error("expected error without a spec")
+	}
+}
+
+// TestProcessCSVReaderWithFilename_SavesToStore tests that uploads are stored with their columns and schema
+func TestProcessCSVReaderWithFilename_SavesToStore(t *testing.T) {
+	store := storage.NewMemoryStore()
+	svc := service.NewConversionService(store)
+
+	if _, err := svc.ProcessCSVReaderWithFilename(strings.NewReader("name,age\nAlice,30\nBob,25"), "people.csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	uploads, err := store.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 1 {
+		t.Fatalf("expected 1 stored upload, got %d", len(uploads))
+	}
+	u := uploads[0]
+	if u.Filename != "people.csv" || strings.Join(u.Columns, ",") != "name,age" || u.Schema == nil {
+		t.Errorf("unexpected upload %+v", u)
+	}
+	if want := `[{"name":"Alice","age":"30"},{"name":"Bob","age":"25"}]`; string(u.Data) != want {
+		t.Errorf("expected %s, got %s", want, u.Data)
+	}
+}
+
+// TestProcessCSVReader_SavesNestedOrder tests that unflattened uploads are stored with the order of their nested keys
+func TestProcessCSVReader_SavesNestedOrder(t *testing.T) {
+	store := storage.NewMemoryStore()
+	svc := service.NewConversionService(store)
+
+	opts := converter.Options{Unflatten: true}
+	if _, _, err := svc.ProcessCSVReaderWithOptions(strings.NewReader("id,a.b,a.a\n1,2,3"), "nested.csv", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	uploads, err := store.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(uploads[0].Columns, ","); got != "id,a,/a/b,/a/a" {
+		t.Errorf("unexpected columns %s", got)
+	}
+}
+
+// TestGetAllData_Store tests reading stored uploads back, newest first, with their column order
+func TestGetAllData_Store(t *testing.T) {
+	svc := service.NewConversionService(storage.NewMemoryStore())
+
+	data, err := svc.GetAllData()
+	if err != nil || len(data) != 0 {
+		t.Fatalf("expected no data, got %v, %v", data, err)
+	}
+
+	for _, name := range []string{"first.csv", "second.csv"} {
+		if _, err := svc.ProcessCSVReaderWithFilename(strings.NewReader("zeta,alpha\n1,2"), name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err = svc.GetAllData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2 || data[0]["filename"] != "second.csv" || data[1]["filename"] != "first.csv" {
+		t.Fatalf("unexpected data %v", data)
+	}
+
+	encoded, err := json.Marshal(data[0]["data"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"zeta":"1","alpha":"2"}]`; string(encoded) != want {
+		t.Errorf("expected %s, got %s", want, encoded)
+	}
+}
+
+// TestGetDataByID_Store tests fetching a stored upload and a missing ID
+func TestGetDataByID_Store(t *testing.T) {
+	store := storage.NewMemoryStore()
+	svc := service.NewConversionService(store)
+
+	if _, err := svc.ProcessCSVReaderWithFilename(strings.NewReader("name\nAlice"), "people.csv"); err != nil {
+		t.Fatal(err)
+	}
+	uploads, err := store.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := uploads[0].ID
+
+	record, err := svc.GetDataByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record["id"] != id || record["filename"] != "people.csv" || record["schema"] == nil {
+		t.Errorf("unexpected record %v", record)
+	}
+
+	if _, err := svc.GetDataByID(id + 1); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected storage.ErrNotFound, got %v", err)
+	}
+}
+
+// TestGetDataByIDAsCSV_Store tests exporting a stored upload as CSV
+func TestGetDataByIDAsCSV_Store(t *testing.T) {
+	store := storage.NewMemoryStore()
+	svc := service.NewConversionService(store)
+
+	if _, err := svc.ProcessCSVReaderWithFilename(strings.NewReader("name,city\nAlice,\"Paris, FR\""), "people.csv"); err != nil {
+		t.Fatal(err)
+	}
+	uploads, err := store.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	filename, err := svc.GetDataByIDAsCSV(uploads[0].ID, &buf, converter.CSVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filename != "people.csv" {
+		t.Errorf("expected people.csv, got %q", filename)
+	}
+	if want := "name,city\nAlice,\"Paris, FR\"\n"; buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+// TestProcessCSVStream_SavesNDJSONAsArray tests that streamed NDJSON is stored as a JSON array
+func TestProcessCSVStream_SavesNDJSONAsArray(t *testing.T) {
+	store := storage.NewMemoryStore()
+	svc := service.NewConversionService(store)
+
+	var buf bytes.Buffer
+	opts := converter.Options{Format: encoder.FormatNDJSON}
+	if _, err := svc.ProcessCSVStream(strings.NewReader("name\nAlice\nBob"), &buf, "people.csv", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	uploads, err := store.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 1 {
+		t.Fatalf("expected 1 stored upload, got %d", len(uploads))
+	}
+	if want := `[{"name":"Alice"},{"name":"Bob"}]`; string(uploads[0].Data) != want {
+		t.Errorf("expected %s, got %s", want, uploads[0].Data)
+	}
+}
+
+// TestProcessCSVStream_SpoolsStoredCopy tests that the stored copy of a
+// streamed upload goes through a temporary file that is removed afterwards,
+// whether or not the upload succeeds
+func TestProcessCSVStream_SpoolsStoredCopy(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	store := storage.NewMemoryStore()
+	svc := service.NewConversionService(store)
+
+	var buf bytes.Buffer
+	if _, err := svc.ProcessCSVStream(strings.NewReader("name\nAlice\nBob"), &buf, "people.csv", converter.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ProcessCSVStream(strings.NewReader("name,age\nAlice\n"), io.Discard, "broken.csv", converter.Options{}); err == nil {
+		t.Fatal("expected error for a ragged row")
+	}
+
+	uploads, err := store.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 1 || string(uploads[0].Data) != buf.String() {
+		t.Errorf("expected the streamed output %s to be stored once, got %v", buf.String(), uploads)
+	}
+	if entries, err := os.ReadDir(tmp); err != nil || len(entries) != 0 {
+		t.Errorf("expected spool files to be removed, got %v, %v", entries, err)
+	}
+}
+
+// TestProcessCSVReaderWithOptions_NotSavedOnFailure tests that rejected and cancelled uploads are not stored
+func TestProcessCSVReaderWithOptions_NotSavedOnFailure(t *testing.T) {
+	store := storage.NewMemoryStore()
+	svc := service.NewConversionService(store)
+
+	spec, err := validate.ParseSpec([]byte(`{"columns": {"age": {"min": 0}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := svc.ProcessCSVReaderWithOptions(strings.NewReader("age\n-1\n"), "rejected.csv", converter.Options{Validation: spec}); err == nil {
+		t.Fatal("expected validation error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := svc.ProcessCSVReaderWithOptionsContext(ctx, strings.NewReader("age\n1\n"), "cancelled.csv", converter.Options{}); err == nil {
+		t.Fatal("expected context error")
+	}
+
+	if uploads, _ := store.List(context.Background()); len(uploads) != 0 {
+		t.Errorf("expected nothing stored, got %d uploads", len(uploads))
+	}
+}
+
+// TestQueryData_Store tests selecting stored uploads by filename
+func TestQueryData_Store(t *testing.T) {
+	svc := service.NewConversionService(storage.NewMemoryStore())
+
+	for _, name := range []string{"sales.csv", "stock.csv", "sales.csv"} {
+		if _, err := svc.ProcessCSVReaderWithFilename(strings.NewReader("n\n1"), name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := svc.QueryData(storage.Query{Filename: "sales.csv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2 || data[0]["filename"] != "sales.csv" || data[1]["filename"] != "sales.csv" {
+		t.Errorf("unexpected data %v", data)
+	}
+
+	data, err = svc.QueryData(storage.Query{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || data[0]["id"] != 3 {
+		t.Errorf("expected the newest upload, got %v", data)
+	}
+}
+
+// TestListData_Store tests listing stored uploads a page at a time
+func TestListData_Store(t *testing.T) {
+	svc := service.NewConversionService(storage.NewMemoryStore())
+
+	for _, name := range []string{"sales.csv", "stock.csv", "sales.csv", "sales.csv"} {
+		if _, err := svc.ProcessCSVReaderWithFilename(strings.NewReader("n\n1"), name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := storage.Query{Filename: "sales.csv", Sort: storage.SortID, Limit: 2}
+	page, err := svc.ListData(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || len(page.Items) != 2 || page.Items[0]["id"] != 4 || page.Items[1]["id"] != 3 || page.NextCursor == "" {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+
+	if q.After, err = storage.DecodeCursor(page.NextCursor); err != nil {
+		t.Fatal(err)
+	}
+	page, err = svc.ListData(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || len(page.Items) != 1 || page.Items[0]["id"] != 1 || page.NextCursor != "" {
+		t.Errorf("unexpected last page %+v", page)
+	}
+
+	// A page that ends exactly at the last upload has no next cursor
+	page, err = svc.ListData(storage.Query{Limit: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 4 || page.NextCursor != "" {
+		t.Errorf("unexpected page %+v", page)
+	}
+
+	if _, err := service.NewConversionService(nil).ListData(q); err == nil || !strings.Contains(err.Error(), "database not initialized") {
+		t.Errorf("expected database not initialized, got %v", err)
+	}
+}
+
+// TestGetRows_Store tests reading the rows of an upload saved with rows
+func TestGetRows_Store(t *testing.T) {
+	svc := service.NewConversionService(storage.NewMemoryStore())
+	svc.SetStoreRows(true)
+
+	if _, err := svc.ProcessCSVReaderWithFilename(strings.NewReader("name,age\nAlice,30\nBob,25\nCarol,41"), "people.csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := svc.GetRows(1, 2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := json.Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"data":{"name":"Bob","age":"25"},"row_number":2,"upload_id":1},{"data":{"name":"Carol","age":"41"},"row_number":3,"upload_id":1}]`
+	if string(out) != want {
+		t.Errorf("expected %s, got %s", want, out)
+	}
+
+	row, err := svc.GetRow(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, _ := json.Marshal(row["data"]); string(out) != `{"name":"Alice","age":"30"}` {
+		t.Errorf("unexpected row %s", out)
+	}
+	if _, err := svc.GetRow(1, 4); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected storage.ErrNotFound, got %v", err)
+	}
+
+	// Without the option, uploads are saved whole only
+	svc.SetStoreRows(false)
+	if _, err := svc.ProcessCSVReaderWithFilename(strings.NewReader("name\nDave"), "more.csv"); err != nil {
+		t.Fatal(err)
+	}
+	if rows, err := svc.GetRows(2, 1, 0); err != nil || len(rows) != 0 {
+		t.Errorf("expected no rows, got %v, %v", rows, err)
+	}
+
+	if _, err := service.NewConversionService(nil).GetRow(1, 1); err == nil || !strings.Contains(err.Error(), "database not initialized") {
+		t.Errorf("expected database not initialized, got %v", err)
+	}
+}
+
+// TestDeleteData_Store tests deleting a stored upload
+func TestDeleteData_Store(t *testing.T) {
+	svc := service.NewConversionService(storage.NewMemoryStore())
+
+	if _, err := svc.ProcessCSVReaderWithFilename(strings.NewReader("n\n1"), "a.csv"); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.DeleteData(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.GetDataByID(1); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected storage.ErrNotFound after delete, got %v", err)
+	}
+	if err := svc.DeleteData(1); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected storage.ErrNotFound deleting twice, got %v", err)
+	}
+
+	if err := service.NewConversionService(nil).DeleteData(1); err == nil || !strings.Contains(err.Error(), "database not initialized") {
+		t.Errorf("expected database not initialized, got %v", err)
+	}
+}
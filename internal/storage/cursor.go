@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Cursor is the position of an upload in a sort order: the listing it
+// continues selects the uploads after it. Unlike an offset, it stays valid
+// when uploads are added or deleted in between.
+type Cursor struct {
+	Sort      Sort      `json:"sort"`
+	Ascending bool      `json:"asc,omitempty"`
+	ID        int       `json:"id"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	Filename  string    `json:"filename,omitempty"`
+}
+
+// CursorAfter returns the cursor continuing the listing of q after u.
+func (q Query) CursorAfter(u *Upload) *Cursor {
+	c := &Cursor{Sort: q.sort(), Ascending: q.Ascending, ID: u.ID}
+	switch c.Sort {
+	case SortCreatedAt:
+		c.CreatedAt = u.CreatedAt
+	case SortFilename:
+		c.Filename = u.Filename
+	}
+	return c
+}
+
+// upload returns an Upload at the position of c, for comparisons.
+func (c *Cursor) upload() *Upload {
+	return &Upload{ID: c.ID, CreatedAt: c.CreatedAt, Filename: c.Filename}
+}
+
+// Encode returns c as an opaque string for clients to send back.
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a string returned by Cursor.Encode.
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if _, err := ParseSort(string(c.Sort)); err != nil || c.Sort == "" {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &c, nil
+}
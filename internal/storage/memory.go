@@ -0,0 +1,247 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store held in memory, for tests and for running without
+// a database. Its contents are lost when the process exits.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	uploads map[int]*Upload
+	// rows holds the records of uploads inserted with rows, by upload ID
+	rows   map[int][][]byte
+	nextID int
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{uploads: make(map[int]*Upload), rows: make(map[int][][]byte), nextID: 1}
+}
+
+// Insert stores a copy of u.
+func (m *MemoryStore) Insert(ctx context.Context, u *Upload) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.insert(u), nil
+}
+
+// insert stores a copy of u under a new ID. m.mu must be held.
+func (m *MemoryStore) insert(u *Upload) int {
+	u.ID = m.nextID
+	m.nextID++
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now().UTC()
+	}
+	m.uploads[u.ID] = clone(u)
+	return u.ID
+}
+
+// InsertWithRows stores a copy of u and of each of its records.
+func (m *MemoryStore) InsertWithRows(ctx context.Context, u *Upload) (int, error) {
+	// Split before storing anything, so invalid data stores nothing
+	var rows [][]byte
+	err := u.EachRow(func(r Row) error {
+		rows = append(rows, r.Data)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := m.insert(u)
+	m.rows[id] = rows
+	return id, nil
+}
+
+// List returns copies of every upload.
+func (m *MemoryStore) List(ctx context.Context) ([]Upload, error) {
+	return m.Query(ctx, Query{})
+}
+
+// Get returns a copy of the upload stored under id.
+func (m *MemoryStore) Get(ctx context.Context, id int) (*Upload, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.uploads[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return clone(u), nil
+}
+
+// Delete removes the upload stored under id.
+func (m *MemoryStore) Delete(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.uploads[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.uploads, id)
+	delete(m.rows, id)
+	return nil
+}
+
+// GetRows returns copies of the rows of the upload stored under id from
+// start.
+func (m *MemoryStore) GetRows(ctx context.Context, id, start, limit int) ([]Row, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.uploads[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	all := m.rows[id]
+	first := min(max(start, 1), len(all)+1) - 1
+	last := len(all)
+	if limit > 0 {
+		last = min(first+limit, last)
+	}
+
+	rows := []Row{}
+	for i := first; i < last; i++ {
+		rows = append(rows, m.row(u, i+1))
+	}
+	return rows, nil
+}
+
+// GetRow returns a copy of row n of the upload stored under id.
+func (m *MemoryStore) GetRow(ctx context.Context, id, n int) (*Row, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.uploads[id]
+	if !ok || n < 1 || n > len(m.rows[id]) {
+		return nil, ErrNotFound
+	}
+	row := m.row(u, n)
+	return &row, nil
+}
+
+// row returns a copy of row n of u. m.mu must be held.
+func (m *MemoryStore) row(u *Upload, n int) Row {
+	return Row{
+		UploadID: u.ID,
+		Number:   n,
+		Data:     cloneBytes(m.rows[u.ID][n-1]),
+		Columns:  cloneStrings(u.Columns),
+	}
+}
+
+// Query returns copies of the uploads selected by q.
+func (m *MemoryStore) Query(ctx context.Context, q Query) ([]Upload, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	var matched []*Upload
+	for _, u := range m.uploads {
+		if q.Match(u) {
+			matched = append(matched, u)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool { return q.Less(matched[i], matched[j]) })
+
+	if q.Offset > 0 {
+		matched = matched[min(q.Offset, len(matched)):]
+	}
+	if q.Limit > 0 && q.Limit < len(matched) {
+		matched = matched[:q.Limit]
+	}
+
+	uploads := make([]Upload, len(matched))
+	for i, u := range matched {
+		uploads[i] = *clone(u)
+		if q.OmitData {
+			uploads[i].Data = nil
+		}
+	}
+	return uploads, nil
+}
+
+// Count returns the number of uploads matching the filters of q.
+func (m *MemoryStore) Count(ctx context.Context, q Query) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	q = q.Filter()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	count := 0
+	for _, u := range m.uploads {
+		if q.Match(u) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Close does nothing; a MemoryStore stays usable.
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// clone copies u so callers cannot change what is stored.
+func clone(u *Upload) *Upload {
+	c := *u
+	c.Data = cloneBytes(u.Data)
+	c.Schema = cloneBytes(u.Schema)
+	c.Columns = cloneStrings(u.Columns)
+	return &c
+}
+
+// cloneStrings copies s, keeping nil as nil.
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string(nil), s...)
+}
+
+// cloneBytes copies b, keeping nil as nil.
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
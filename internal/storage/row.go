@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+)
+
+// Row is one record of an upload inserted with InsertWithRows, stored on its
+// own so that it can be read without the rest of the upload.
+type Row struct {
+	UploadID int
+	// Number is the position of the record in the upload's data, from 1.
+	Number int
+	// Data is the record as JSON.
+	Data []byte
+	// Columns is the key order of the upload's records, or nil.
+	Columns []string
+}
+
+// Record returns r as the API presents it: a map with upload_id, row_number
+// and data, with the keys of data in Columns order.
+func (r *Row) Record() (map[string]interface{}, error) {
+	data, err := encoder.OrderObject(r.Data, r.Columns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal row: %w", err)
+	}
+	return map[string]interface{}{
+		"upload_id":  r.UploadID,
+		"row_number": r.Number,
+		"data":       data,
+	}, nil
+}
+
+// EachRow calls fn with each record of u.Data in order, as a Row numbered
+// from 1, decoding one record at a time. It stops at the first error.
+func (u *Upload) EachRow(fn func(r Row) error) error {
+	decoder := json.NewDecoder(bytes.NewReader(u.Data))
+	if t, err := decoder.Token(); err != nil || t != json.Delim('[') {
+		return fmt.Errorf("failed to split rows: data is not a JSON array")
+	}
+
+	for n := 1; decoder.More(); n++ {
+		var data json.RawMessage
+		if err := decoder.Decode(&data); err != nil {
+			return fmt.Errorf("failed to split rows: %w", err)
+		}
+		if err := fn(Row{UploadID: u.ID, Number: n, Data: data, Columns: u.Columns}); err != nil {
+			return err
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("failed to split rows: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,546 @@
+// Package storagetest checks that a storage.Store implementation behaves as
+// the interface documents, so every backend passes the same tests.
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/storage"
+)
+
+// Run runs the conformance tests against stores returned by open, which is
+// called once per test and must return an empty store.
+func Run(t *testing.T, open func(t *testing.T) storage.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Store)
+	}{
+		{"InsertGet", testInsertGet},
+		{"GetMissing", testGetMissing},
+		{"List", testList},
+		{"Delete", testDelete},
+		{"Query", testQuery},
+		{"Sort", testSort},
+		{"Cursor", testCursor},
+		{"OmitData", testOmitData},
+		{"Count", testCount},
+		{"InvalidQuery", testInvalidQuery},
+		{"Rows", testRows},
+		{"ManyRows", testManyRows},
+		{"InvalidRows", testInvalidRows},
+		{"Cancelled", testCancelled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := open(t)
+			defer s.Close()
+			tt.fn(t, s)
+		})
+	}
+}
+
+// base is the creation time of the first upload the tests insert.
+var base = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// insert stores an upload created at base plus minutes.
+func insert(t *testing.T, s storage.Store, filename string, minutes int) *storage.Upload {
+	t.Helper()
+
+	u := &storage.Upload{
+		Filename:  filename,
+		Data:      []byte(`[{"name":"Alice","age":30}]`),
+		Columns:   []string{"name", "age"},
+		CreatedAt: base.Add(time.Duration(minutes) * time.Minute),
+	}
+	id, err := s.Insert(context.Background(), u)
+	if err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if id == 0 || u.ID != id {
+		t.Fatalf("Insert returned id %d and set %d", id, u.ID)
+	}
+	return u
+}
+
+// ids returns the IDs of uploads in order.
+func ids(uploads []storage.Upload) []int {
+	result := []int{}
+	for _, u := range uploads {
+		result = append(result, u.ID)
+	}
+	return result
+}
+
+// sameJSON reports whether a and b hold equal JSON, as stores may
+// reformat it.
+func sameJSON(a, b []byte) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	var x, y interface{}
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+func testInsertGet(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	u := &storage.Upload{
+		Filename: "people.csv",
+		Data:     []byte(`[{"name":"Alice","age":30},{"name":"Bob","age":null}]`),
+		Columns:  []string{"name", "age"},
+		Schema:   []byte(`{"type":"array"}`),
+	}
+	before := time.Now().Add(-time.Minute)
+	id, err := s.Insert(ctx, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.CreatedAt.Before(before) {
+		t.Errorf("expected CreatedAt to be set to now, got %v", u.CreatedAt)
+	}
+
+	got, err := s.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != id || got.Filename != "people.csv" {
+		t.Errorf("unexpected upload %+v", got)
+	}
+	if !sameJSON(got.Data, u.Data) || !sameJSON(got.Schema, u.Schema) {
+		t.Errorf("unexpected data %s or schema %s", got.Data, got.Schema)
+	}
+	if !reflect.DeepEqual(got.Columns, u.Columns) {
+		t.Errorf("expected columns %v, got %v", u.Columns, got.Columns)
+	}
+	if !got.CreatedAt.Equal(u.CreatedAt) {
+		t.Errorf("expected CreatedAt %v, got %v", u.CreatedAt, got.CreatedAt)
+	}
+
+	// Optional fields stay unset
+	bare := &storage.Upload{Data: []byte(`[]`)}
+	if _, err := s.Insert(ctx, bare); err != nil {
+		t.Fatal(err)
+	}
+	if bare.ID == id {
+		t.Errorf("expected a new id, got %d again", id)
+	}
+	got, err = s.Get(ctx, bare.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Columns != nil || got.Schema != nil || got.Filename != "" {
+		t.Errorf("expected no columns, schema or filename, got %+v", got)
+	}
+}
+
+func testGetMissing(t *testing.T, s storage.Store) {
+	if _, err := s.Get(context.Background(), 42); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func testList(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	uploads, err := s.List(ctx)
+	if err != nil || len(uploads) != 0 {
+		t.Fatalf("expected an empty store, got %v, %v", uploads, err)
+	}
+
+	a := insert(t, s, "a.csv", 0)
+	b := insert(t, s, "b.csv", 10)
+	c := insert(t, s, "c.csv", 10)
+	d := insert(t, s, "d.csv", 5)
+
+	uploads, err = s.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Newest first, ties by ID
+	want := []int{c.ID, b.ID, d.ID, a.ID}
+	if got := ids(uploads); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected order %v, got %v", want, got)
+	}
+}
+
+func testDelete(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	a := insert(t, s, "a.csv", 0)
+	b := insert(t, s, "b.csv", 1)
+
+	if err := s.Delete(ctx, a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, a.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+	if err := s.Delete(ctx, a.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected ErrNotFound deleting twice, got %v", err)
+	}
+
+	uploads, err := s.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(uploads); !reflect.DeepEqual(got, []int{b.ID}) {
+		t.Errorf("expected only %d left, got %v", b.ID, got)
+	}
+}
+
+func testQuery(t *testing.T, s storage.Store) {
+	a := insert(t, s, "sales.csv", 0)
+	b := insert(t, s, "stock.csv", 1)
+	c := insert(t, s, "sales.csv", 2)
+	d := insert(t, s, "sales.csv", 3)
+
+	tests := []struct {
+		name  string
+		query storage.Query
+		want  []int
+	}{
+		{"all", storage.Query{}, []int{d.ID, c.ID, b.ID, a.ID}},
+		{"filename", storage.Query{Filename: "sales.csv"}, []int{d.ID, c.ID, a.ID}},
+		{"no match", storage.Query{Filename: "missing.csv"}, []int{}},
+		{"after", storage.Query{CreatedAfter: b.CreatedAt}, []int{d.ID, c.ID, b.ID}},
+		{"before", storage.Query{CreatedBefore: c.CreatedAt}, []int{b.ID, a.ID}},
+		{"range", storage.Query{CreatedAfter: b.CreatedAt, CreatedBefore: d.CreatedAt}, []int{c.ID, b.ID}},
+		{"limit", storage.Query{Limit: 2}, []int{d.ID, c.ID}},
+		{"offset", storage.Query{Offset: 1, Limit: 2}, []int{c.ID, b.ID}},
+		{"offset past end", storage.Query{Offset: 10}, []int{}},
+		{"filtered page", storage.Query{Filename: "sales.csv", Offset: 1}, []int{c.ID, a.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploads, err := s.Query(context.Background(), tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(uploads); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func testSort(t *testing.T, s storage.Store) {
+	a := insert(t, s, "b.csv", 0)
+	b := insert(t, s, "a.csv", 2)
+	c := insert(t, s, "B.csv", 1)
+	d := insert(t, s, "a.csv", 1)
+
+	tests := []struct {
+		name  string
+		query storage.Query
+		want  []int
+	}{
+		{"created_at", storage.Query{Sort: storage.SortCreatedAt}, []int{b.ID, d.ID, c.ID, a.ID}},
+		{"created_at ascending", storage.Query{Ascending: true}, []int{a.ID, c.ID, d.ID, b.ID}},
+		{"id", storage.Query{Sort: storage.SortID}, []int{d.ID, c.ID, b.ID, a.ID}},
+		{"id ascending", storage.Query{Sort: storage.SortID, Ascending: true}, []int{a.ID, b.ID, c.ID, d.ID}},
+		// Byte order, so upper case first; ties by ID
+		{"filename ascending", storage.Query{Sort: storage.SortFilename, Ascending: true}, []int{c.ID, b.ID, d.ID, a.ID}},
+		{"filename", storage.Query{Sort: storage.SortFilename}, []int{a.ID, d.ID, b.ID, c.ID}},
+		{"filtered", storage.Query{Filename: "a.csv", Sort: storage.SortID, Ascending: true}, []int{b.ID, d.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploads, err := s.Query(context.Background(), tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(uploads); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func testCursor(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	var all []int
+	for i, name := range []string{"c.csv", "a.csv", "b.csv", "a.csv", "c.csv"} {
+		// Two uploads share each creation time
+		all = append(all, insert(t, s, name, i/2).ID)
+	}
+
+	for _, sort := range []storage.Sort{storage.SortCreatedAt, storage.SortID, storage.SortFilename} {
+		for _, ascending := range []bool{false, true} {
+			q := storage.Query{Sort: sort, Ascending: ascending}
+			want, err := s.Query(ctx, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Walk the listing two at a time, through an encoded cursor
+			var got []int
+			for page := 0; page < len(all); page++ {
+				q.Limit = 2
+				uploads, err := s.Query(ctx, q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, ids(uploads)...)
+				if len(uploads) < q.Limit {
+					break
+				}
+				if q.After, err = storage.DecodeCursor(q.CursorAfter(&uploads[len(uploads)-1]).Encode()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if !reflect.DeepEqual(got, ids(want)) {
+				t.Errorf("%s ascending=%v: expected %v by cursor, got %v", sort, ascending, ids(want), got)
+			}
+		}
+	}
+
+	// A cursor keeps its place when earlier uploads are added or deleted
+	q := storage.Query{Sort: storage.SortID, Ascending: true, Limit: 2}
+	first, err := s.Query(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.After = q.CursorAfter(&first[1])
+	if err := s.Delete(ctx, first[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	next, err := s.Query(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(next); !reflect.DeepEqual(got, all[2:4]) {
+		t.Errorf("expected %v after the cursor, got %v", all[2:4], got)
+	}
+}
+
+func testOmitData(t *testing.T, s storage.Store) {
+	u := insert(t, s, "people.csv", 0)
+
+	uploads, err := s.Query(context.Background(), storage.Query{OmitData: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 1 {
+		t.Fatalf("expected 1 upload, got %d", len(uploads))
+	}
+	got := uploads[0]
+	if got.Data != nil {
+		t.Errorf("expected no data, got %s", got.Data)
+	}
+	if got.ID != u.ID || got.Filename != "people.csv" || !reflect.DeepEqual(got.Columns, u.Columns) || !got.CreatedAt.Equal(u.CreatedAt) {
+		t.Errorf("expected the metadata of %+v, got %+v", u, got)
+	}
+}
+
+func testCount(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	a := insert(t, s, "sales.csv", 0)
+	insert(t, s, "stock.csv", 1)
+	insert(t, s, "sales.csv", 2)
+
+	tests := []struct {
+		name  string
+		query storage.Query
+		want  int
+	}{
+		{"all", storage.Query{}, 3},
+		{"filename", storage.Query{Filename: "sales.csv"}, 2},
+		{"range", storage.Query{CreatedAfter: base.Add(time.Minute)}, 2},
+		// Paging and cursors do not change the total
+		{"paged", storage.Query{Filename: "sales.csv", Limit: 1, Offset: 1, After: storage.Query{}.CursorAfter(a)}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := s.Count(ctx, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, count)
+			}
+		})
+	}
+}
+
+func testInvalidQuery(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	u := insert(t, s, "a.csv", 0)
+
+	after := storage.Query{Sort: storage.SortID}.CursorAfter(u)
+	if _, err := s.Query(ctx, storage.Query{After: after}); !errors.Is(err, storage.ErrCursorMismatch) {
+		t.Errorf("expected ErrCursorMismatch for another sort, got %v", err)
+	}
+	if _, err := s.Query(ctx, storage.Query{Sort: storage.SortID, Ascending: true, After: after}); !errors.Is(err, storage.ErrCursorMismatch) {
+		t.Errorf("expected ErrCursorMismatch for another direction, got %v", err)
+	}
+	if _, err := s.Query(ctx, storage.Query{Sort: "size"}); err == nil {
+		t.Error("expected error for an unknown sort")
+	}
+	if _, err := s.Query(ctx, storage.Query{Limit: -1}); err == nil {
+		t.Error("expected error for a negative limit")
+	}
+}
+
+func testRows(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	u := &storage.Upload{
+		Filename: "people.csv",
+		Data:     []byte(`[{"name":"Alice","age":30},{"name":"Bob","age":null},{"name":"Carol","age":41}]`),
+		Columns:  []string{"name", "age"},
+	}
+	id, err := s.InsertWithRows(ctx, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != id || u.CreatedAt.IsZero() {
+		t.Errorf("expected ID and CreatedAt to be set, got %+v", u)
+	}
+	if got, err := s.Get(ctx, id); err != nil || !sameJSON(got.Data, u.Data) {
+		t.Errorf("expected the upload to be stored too, got %v, %v", got, err)
+	}
+
+	tests := []struct {
+		name         string
+		start, limit int
+		want         []int
+	}{
+		{"all", 1, 0, []int{1, 2, 3}},
+		{"from before the first", 0, 0, []int{1, 2, 3}},
+		{"range", 2, 1, []int{2}},
+		{"limit past end", 2, 10, []int{2, 3}},
+		{"start past end", 4, 0, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := s.GetRows(ctx, id, tt.start, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []int{}
+			for _, r := range rows {
+				if r.UploadID != id || !reflect.DeepEqual(r.Columns, u.Columns) {
+					t.Errorf("unexpected row %+v", r)
+				}
+				got = append(got, r.Number)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected rows %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	row, err := s.GetRow(ctx, id, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.Number != 2 || !sameJSON(row.Data, []byte(`{"name":"Bob","age":null}`)) {
+		t.Errorf("unexpected row %d: %s", row.Number, row.Data)
+	}
+	for _, n := range []int{0, 4} {
+		if _, err := s.GetRow(ctx, id, n); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("expected ErrNotFound for row %d, got %v", n, err)
+		}
+	}
+	if _, err := s.GetRows(ctx, 42, 1, 0); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing upload, got %v", err)
+	}
+
+	// Uploads inserted without rows have none
+	plain := insert(t, s, "plain.csv", 0)
+	if rows, err := s.GetRows(ctx, plain.ID, 1, 0); err != nil || len(rows) != 0 {
+		t.Errorf("expected no rows, got %v, %v", rows, err)
+	}
+	if _, err := s.GetRow(ctx, plain.ID, 1); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// Rows go with their upload
+	if err := s.Delete(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetRow(ctx, id, 1); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func testManyRows(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+
+	// Enough rows to span several insert batches, ending in a partial one
+	const n = 2345
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i := 1; i <= n; i++ {
+		if i > 1 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(&buf, `{"n":%d}`, i)
+	}
+	buf.WriteByte(']')
+
+	id, err := s.InsertWithRows(ctx, &storage.Upload{Data: buf.Bytes()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := s.GetRows(ctx, id, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != n {
+		t.Fatalf("expected %d rows, got %d", n, len(rows))
+	}
+	for i, r := range rows {
+		if want := fmt.Sprintf(`{"n":%d}`, i+1); r.Number != i+1 || !sameJSON(r.Data, []byte(want)) {
+			t.Fatalf("expected row %d to be %s, got %d: %s", i+1, want, r.Number, r.Data)
+		}
+	}
+}
+
+func testInvalidRows(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+
+	for _, data := range []string{`{"name":"Alice"}`, `[{"name":"Alice"},`} {
+		if _, err := s.InsertWithRows(ctx, &storage.Upload{Data: []byte(data)}); err == nil {
+			t.Errorf("expected error for data %s", data)
+		}
+	}
+
+	// Nothing is stored when the rows cannot be
+	uploads, err := s.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 0 {
+		t.Errorf("expected no uploads, got %v", ids(uploads))
+	}
+}
+
+func testCancelled(t *testing.T, s storage.Store) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := s.Insert(ctx, &storage.Upload{Data: []byte(`[]`)}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected Insert to fail with context.Canceled, got %v", err)
+	}
+	if _, err := s.List(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected List to fail with context.Canceled, got %v", err)
+	}
+
+	uploads, err := s.List(context.Background())
+	if err != nil || len(uploads) != 0 {
+		t.Errorf("expected nothing stored, got %v, %v", uploads, err)
+	}
+}
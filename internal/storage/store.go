@@ -0,0 +1,211 @@
+// Package storage defines where converted uploads are kept. ConversionService
+// works against the Store interface; database.PostgresDB and MemoryStore
+// implement it.
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+)
+
+// ErrNotFound is returned for an ID that is not stored.
+var ErrNotFound = errors.New("record not found")
+
+// ErrCursorMismatch is returned for a cursor used with another sort order
+// than it was made for.
+var ErrCursorMismatch = errors.New("cursor does not match the sort order")
+
+// Upload is one stored conversion.
+type Upload struct {
+	ID       int
+	Filename string
+	// Data is the converted records as a JSON array.
+	Data []byte
+	// Columns is the key order of the records in Data, which stores that
+	// reformat JSON may not keep; nil if unknown. The top-level keys may be
+	// followed by the JSON Pointers of nested members, such as
+	// "/address/city", to keep their order too.
+	Columns []string
+	// Schema is the JSON Schema inferred from Data, or nil.
+	Schema    []byte
+	CreatedAt time.Time
+}
+
+// Sort names the field uploads are ordered by. Uploads with equal values
+// are ordered by ID in the same direction.
+type Sort string
+
+const (
+	SortCreatedAt Sort = "created_at"
+	SortID        Sort = "id"
+	SortFilename  Sort = "filename"
+)
+
+// ParseSort returns the Sort named by s; "" is SortCreatedAt.
+func ParseSort(s string) (Sort, error) {
+	switch Sort(s) {
+	case "", SortCreatedAt:
+		return SortCreatedAt, nil
+	case SortID, SortFilename:
+		return Sort(s), nil
+	}
+	return "", fmt.Errorf("unknown sort %q (want created_at, id or filename)", s)
+}
+
+// Query selects stored uploads. Zero fields do not filter.
+type Query struct {
+	// Filename matches the upload filename exactly.
+	Filename string
+	// CreatedAfter and CreatedBefore bound the creation time; the first is
+	// inclusive, the second exclusive.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	// Sort orders the uploads, newest or highest first unless Ascending.
+	// The zero value sorts by creation time.
+	Sort      Sort
+	Ascending bool
+
+	// After continues a listing from a cursor, selecting only the uploads
+	// that follow it. It must have been made for the same Sort and
+	// Ascending.
+	After *Cursor
+	// Limit caps the number of uploads returned, after skipping Offset.
+	Limit  int
+	Offset int
+
+	// OmitData leaves Data nil, for listings that only need metadata.
+	OmitData bool
+}
+
+// Validate reports a query that cannot be run: an unknown sort, a negative
+// limit or offset, or a cursor made for another order.
+func (q Query) Validate() error {
+	if _, err := ParseSort(string(q.Sort)); err != nil {
+		return err
+	}
+	if q.Limit < 0 || q.Offset < 0 {
+		return fmt.Errorf("limit and offset must not be negative")
+	}
+	if q.After != nil && (q.After.Sort != q.sort() || q.After.Ascending != q.Ascending) {
+		return ErrCursorMismatch
+	}
+	return nil
+}
+
+// sort returns q.Sort, defaulting to SortCreatedAt.
+func (q Query) sort() Sort {
+	if q.Sort == "" {
+		return SortCreatedAt
+	}
+	return q.Sort
+}
+
+// Filter returns q without its order, cursor and paging: the query whose
+// matches Store.Count counts.
+func (q Query) Filter() Query {
+	return Query{Filename: q.Filename, CreatedAfter: q.CreatedAfter, CreatedBefore: q.CreatedBefore}
+}
+
+// Store keeps uploads. Lists are ordered newest first, by creation time and
+// then ID, unless a Query says otherwise. Implementations are safe for
+// concurrent use.
+type Store interface {
+	// Insert stores u and returns its new ID, also set on u. A zero
+	// CreatedAt is set to the current time.
+	Insert(ctx context.Context, u *Upload) (int, error)
+	// InsertWithRows is Insert that also stores each record of u.Data, a
+	// JSON array, as a Row. The upload and its rows are stored together or
+	// not at all.
+	InsertWithRows(ctx context.Context, u *Upload) (int, error)
+	// List returns every upload.
+	List(ctx context.Context) ([]Upload, error)
+	// Get returns the upload stored under id, or ErrNotFound.
+	Get(ctx context.Context, id int) (*Upload, error)
+	// Delete removes the upload stored under id and its rows, or returns
+	// ErrNotFound.
+	Delete(ctx context.Context, id int) error
+	// GetRows returns the rows of the upload stored under id numbered from
+	// start, at most limit of them unless it is 0, or ErrNotFound if there
+	// is no such upload. An upload inserted without rows has none.
+	GetRows(ctx context.Context, id, start, limit int) ([]Row, error)
+	// GetRow returns row number n of the upload stored under id, or
+	// ErrNotFound.
+	GetRow(ctx context.Context, id, n int) (*Row, error)
+	// Query returns the uploads selected by q, in its order. An invalid
+	// query is an error.
+	Query(ctx context.Context, q Query) ([]Upload, error)
+	// Count returns the number of uploads matching the filters of q,
+	// ignoring its order, cursor and paging.
+	Count(ctx context.Context, q Query) (int, error)
+	// Close releases the resources of the store.
+	Close() error
+}
+
+// Record returns u as the API presents it: a map with id, filename, data,
+// schema and created_at, with the keys of each record in data restored to
+// Columns order. Records stored without columns have sorted keys. Without
+// Data, as listed with Query.OmitData, there is no data key.
+func (u *Upload) Record() (map[string]interface{}, error) {
+	var schema interface{}
+	if u.Schema != nil {
+		schema = json.RawMessage(u.Schema)
+	}
+
+	record := map[string]interface{}{
+		"id":         u.ID,
+		"filename":   u.Filename,
+		"schema":     schema,
+		"created_at": u.CreatedAt,
+	}
+	if u.Data != nil {
+		data, err := encoder.OrderObjects(u.Data, u.Columns)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal data: %w", err)
+		}
+		record["data"] = data
+	}
+	return record, nil
+}
+
+// Match reports whether u is selected by the filters and cursor of q,
+// ignoring Limit and Offset.
+func (q Query) Match(u *Upload) bool {
+	if q.After != nil && !q.Less(q.After.upload(), u) {
+		return false
+	}
+	if q.Filename != "" && u.Filename != q.Filename {
+		return false
+	}
+	if !q.CreatedAfter.IsZero() && u.CreatedAt.Before(q.CreatedAfter) {
+		return false
+	}
+	if !q.CreatedBefore.IsZero() && !u.CreatedAt.Before(q.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// Less reports whether a comes before b in the order of q.
+func (q Query) Less(a, b *Upload) bool {
+	var cmp int
+	switch q.sort() {
+	case SortCreatedAt:
+		cmp = a.CreatedAt.Compare(b.CreatedAt)
+	case SortFilename:
+		cmp = strings.Compare(a.Filename, b.Filename)
+	}
+	if cmp == 0 {
+		cmp = a.ID - b.ID
+	}
+	if q.Ascending {
+		return cmp < 0
+	}
+	return cmp > 0
+}
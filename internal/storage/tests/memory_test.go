@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/storage"
+	"github.com/agileproject-gurpreet/csv2json/internal/storage/storagetest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		return storage.NewMemoryStore()
+	})
+}
+
+func TestMemoryStore_ReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemoryStore()
+
+	u := &storage.Upload{Data: []byte(`[{"a":1}]`), Columns: []string{"a"}}
+	if _, err := s.Insert(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	u.Data[2] = 'b'
+	u.Columns[0] = "b"
+
+	got, err := s.Get(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Columns[0] = "c"
+
+	again, err := s.Get(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again.Data) != `[{"a":1}]` || again.Columns[0] != "a" {
+		t.Errorf("stored upload was changed through a caller's copy: %s %v", again.Data, again.Columns)
+	}
+}
+
+func TestUpload_Record(t *testing.T) {
+	u := &storage.Upload{
+		ID:       7,
+		Filename: "people.csv",
+		Data:     []byte(`[{"name":"Alice","age":30}]`),
+		Columns:  []string{"name", "age"},
+	}
+
+	record, err := u.Record()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"created_at":"0001-01-01T00:00:00Z","data":[{"name":"Alice","age":30}],"filename":"people.csv","id":7,"schema":null}`
+	if string(data) != want {
+		t.Errorf("expected %s, got %s", want, data)
+	}
+}
@@ -0,0 +1,222 @@
+package structs
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/parser"
+)
+
+// timeLayouts are the layouts time.Time fields are parsed with, in order.
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// DecodeError reports a CSV value that cannot be stored in its field.
+type DecodeError struct {
+	// Row is the 1-based data row.
+	Row int
+	// Line and Column locate the value in the input. Column is a 1-based
+	// byte index in the line.
+	Line   int
+	Column int
+	// Header is the CSV column name and Field the Go field path.
+	Header string
+	Field  string
+	Value  string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("row %d (line %d, column %d): column %q into field %s: %v",
+		e.Row, e.Line, e.Column, e.Header, e.Field, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Decoder stores CSV records in structs of one type.
+type Decoder struct {
+	typ reflect.Type
+	// columns holds the field of each record column, or nil for columns
+	// without one.
+	columns []*Field
+}
+
+// NewDecoder returns a Decoder for records with the given headers into
+// structs of type t. A header matches a field name exactly or, failing
+// that, ignoring case. Columns without a field are ignored, and fields
+// without a column keep their zero value.
+func NewDecoder(t reflect.Type, headers []string, sep string) (*Decoder, error) {
+	fields, err := Fields(t, sep)
+	if err != nil {
+		return nil, err
+	}
+
+	exact := make(map[string]int, len(fields))
+	folded := make(map[string]int, len(fields))
+	for i, f := range fields {
+		exact[f.Name] = i
+		if _, taken := folded[strings.ToLower(f.Name)]; !taken {
+			folded[strings.ToLower(f.Name)] = i
+		}
+	}
+
+	d := &Decoder{typ: t, columns: make([]*Field, len(headers))}
+	used := make(map[int]bool, len(fields))
+	// Exact matches first, so they win over another header's folded match
+	for c, h := range headers {
+		if i, ok := exact[h]; ok && !used[i] {
+			d.columns[c], used[i] = &fields[i], true
+		}
+	}
+	for c, h := range headers {
+		if d.columns[c] != nil {
+			continue
+		}
+		if i, ok := folded[strings.ToLower(h)]; ok && !used[i] {
+			d.columns[c], used[i] = &fields[i], true
+		}
+	}
+	return d, nil
+}
+
+// Decode stores record, data row row, in the struct v points to. position
+// locates field i of the record in the input.
+func (d *Decoder) Decode(record parser.Record, row int, position func(int) (int, int), v reflect.Value) error {
+	for i, f := range record {
+		if i >= len(d.columns) || d.columns[i] == nil || f.Null {
+			continue
+		}
+		field := d.columns[i]
+		fv := fieldByIndex(v, field.Index)
+		if !fv.IsValid() {
+			continue
+		}
+		if err := Set(fv, f.Value); err != nil {
+			line, column := position(i)
+			return &DecodeError{
+				Row: row, Line: line, Column: column,
+				Header: f.Name, Field: field.Path, Value: f.Value, Err: err,
+			}
+		}
+	}
+	return nil
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex, allocating nil pointers to
+// nested structs on the way. It returns the zero Value if a nil pointer
+// cannot be set, as with an unexported embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// Set stores the CSV value s in v. An empty value leaves numbers, bools,
+// times and pointers at their zero value; other types, including
+// encoding.TextUnmarshaler implementations, are given the empty string.
+func Set(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		if s == "" {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	if v.Type() == timeType {
+		if s == "" {
+			v.Set(reflect.Zero(timeType))
+			return nil
+		}
+		t, err := parseTime(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+		return nil
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(s))
+		return nil
+	}
+
+	if s == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	trimmed := strings.TrimSpace(s)
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(trimmed)
+		if err != nil {
+			return invalid(s, v.Type(), err)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(trimmed, 10, v.Type().Bits())
+		if err != nil {
+			return invalid(s, v.Type(), err)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(trimmed, 10, v.Type().Bits())
+		if err != nil {
+			return invalid(s, v.Type(), err)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(trimmed, v.Type().Bits())
+		if err != nil {
+			return invalid(s, v.Type(), err)
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	trimmed := strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, trimmed); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not an RFC 3339 timestamp or date", s)
+}
+
+// invalid describes a strconv failure without repeating its function name.
+func invalid(s string, t reflect.Type, err error) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf("%q is out of range for %s", s, t)
+	}
+	return fmt.Errorf("%q is not a valid %s", s, t)
+}
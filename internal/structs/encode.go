@@ -0,0 +1,345 @@
+package structs
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+)
+
+// Encoder writes structs and maps as CSV rows under one header row.
+type Encoder struct {
+	out *encoder.CSVWriter
+	sep string
+	// fixed is set when the header was given rather than taken from the
+	// first record; keys outside a fixed header are dropped.
+	fixed   bool
+	columns []string
+	index   map[string]int
+	fields  map[reflect.Type][]Field
+	header  bool
+	rows    int
+}
+
+// NewEncoder returns an Encoder writing to out. columns fixes the header
+// row; nil takes it from the first record, or all records for EncodeAll
+// of maps. Nested names are joined with sep.
+func NewEncoder(out *encoder.CSVWriter, columns []string, sep string) *Encoder {
+	e := &Encoder{out: out, sep: sep, fields: make(map[reflect.Type][]Field)}
+	if columns != nil {
+		e.fixed = true
+		e.setColumns(columns)
+	}
+	return e
+}
+
+func (e *Encoder) setColumns(columns []string) {
+	e.columns = columns
+	e.index = make(map[string]int, len(columns))
+	for i, c := range columns {
+		e.index[c] = i
+	}
+}
+
+// Rows returns the number of records written, excluding the header row.
+func (e *Encoder) Rows() int {
+	return e.rows
+}
+
+// Columns returns the header row, once known.
+func (e *Encoder) Columns() []string {
+	return e.columns
+}
+
+// Encode writes one record: a struct, a pointer to one, or a map with
+// string keys. The header row is written first.
+func (e *Encoder) Encode(v reflect.Value) error {
+	members, err := e.members(v)
+	if err != nil {
+		return fmt.Errorf("record %d: %w", e.rows+1, err)
+	}
+	if e.columns == nil {
+		columns := make([]string, len(members))
+		for i, m := range members {
+			columns[i] = m.Key
+		}
+		e.setColumns(columns)
+	}
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	row := make([]string, len(e.columns))
+	for _, m := range members {
+		i, ok := e.index[m.Key]
+		if !ok {
+			if e.fixed {
+				continue
+			}
+			return fmt.Errorf("record %d: %q is not in the header row; fix the columns to drop it", e.rows+1, m.Key)
+		}
+		row[i] = m.Value.(string)
+	}
+	if err := e.out.Write(row); err != nil {
+		return err
+	}
+	e.rows++
+	return nil
+}
+
+// EncodeAll writes every record of a slice, an array, or a channel,
+// received from until it is closed. Without a header row, one of structs
+// takes the columns of the element type, so it is written even without
+// records, and a slice of maps the keys of all records in order of first
+// appearance.
+func (e *Encoder) EncodeAll(v reflect.Value) error {
+	v = indirect(v)
+	if !v.IsValid() {
+		return fmt.Errorf("cannot encode nil")
+	}
+	kind := v.Kind()
+	if kind != reflect.Slice && kind != reflect.Array && kind != reflect.Chan {
+		return fmt.Errorf("cannot encode %s: not a slice, array or channel", v.Type())
+	}
+
+	if e.columns == nil {
+		elem := v.Type().Elem()
+		for elem.Kind() == reflect.Pointer {
+			elem = elem.Elem()
+		}
+		switch {
+		case elem.Kind() == reflect.Struct && !single(elem):
+			fields, err := e.structFields(elem)
+			if err != nil {
+				return err
+			}
+			columns := make([]string, len(fields))
+			for i, f := range fields {
+				columns[i] = f.Name
+			}
+			e.setColumns(columns)
+		case kind != reflect.Chan && (isMap(elem) || elem.Kind() == reflect.Interface):
+			if err := e.unionColumns(v); err != nil {
+				return err
+			}
+		}
+	}
+
+	if kind == reflect.Chan {
+		for {
+			record, ok := v.Recv()
+			if !ok {
+				break
+			}
+			if err := e.Encode(record); err != nil {
+				return err
+			}
+		}
+	} else {
+		for i := 0; i < v.Len(); i++ {
+			if err := e.Encode(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	}
+	if e.columns != nil {
+		return e.writeHeader()
+	}
+	return nil
+}
+
+// writeHeader writes the header row unless it has been written.
+func (e *Encoder) writeHeader() error {
+	if e.header {
+		return nil
+	}
+	e.header = true
+	return e.out.Write(e.columns)
+}
+
+// unionColumns sets the header to the keys of all records of the slice v.
+func (e *Encoder) unionColumns(v reflect.Value) error {
+	var columns []string
+	seen := make(map[string]bool)
+	for i := 0; i < v.Len(); i++ {
+		members, err := e.members(v.Index(i))
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i+1, err)
+		}
+		for _, m := range members {
+			if !seen[m.Key] {
+				seen[m.Key] = true
+				columns = append(columns, m.Key)
+			}
+		}
+	}
+	e.setColumns(columns)
+	return nil
+}
+
+// members returns the formatted values of a record, keyed by column. Map
+// keys are sorted.
+func (e *Encoder) members(v reflect.Value) (encoder.Object, error) {
+	v = indirect(v)
+	if !v.IsValid() {
+		return nil, fmt.Errorf("cannot encode nil")
+	}
+	if !isMap(v.Type()) && (v.Kind() != reflect.Struct || single(v.Type())) {
+		return nil, fmt.Errorf("cannot encode %s: not a struct or map", v.Type())
+	}
+	var members encoder.Object
+	if err := e.flatten(v, "", &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// flatten appends the leaves of v to members, named from prefix: struct
+// fields by Fields, map keys joined with the separator and slice elements
+// as "[n]".
+func (e *Encoder) flatten(v reflect.Value, prefix string, members *encoder.Object) error {
+	v = indirect(v)
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + e.sep + key
+	}
+
+	switch {
+	case !v.IsValid():
+		*members = append(*members, encoder.Member{Key: prefix, Value: ""})
+		return nil
+
+	case v.Kind() == reflect.Struct && !single(v.Type()):
+		fields, err := e.structFields(v.Type())
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			text := ""
+			if fv, ok := fieldValue(v, f.Index); ok && !(f.OmitEmpty && fv.IsZero()) {
+				if text, err = Format(fv); err != nil {
+					return fmt.Errorf("field %s: %w", f.Path, err)
+				}
+			}
+			*members = append(*members, encoder.Member{Key: join(f.Name), Value: text})
+		}
+		return nil
+
+	case isMap(v.Type()):
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			if err := e.flatten(v.MapIndex(k), join(k.String()), members); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8:
+		for i := 0; i < v.Len(); i++ {
+			if err := e.flatten(v.Index(i), prefix+"["+strconv.Itoa(i)+"]", members); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	text, err := Format(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", prefix, err)
+	}
+	*members = append(*members, encoder.Member{Key: prefix, Value: text})
+	return nil
+}
+
+func (e *Encoder) structFields(t reflect.Type) ([]Field, error) {
+	if fields, ok := e.fields[t]; ok {
+		return fields, nil
+	}
+	fields, err := Fields(t, e.sep)
+	if err != nil {
+		return nil, err
+	}
+	e.fields[t] = fields
+	return fields, nil
+}
+
+// fieldValue is reflect.Value.FieldByIndex, reporting false when a nil
+// pointer to a nested struct is on the way.
+func fieldValue(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// Format renders a field value as CSV text: nil pointers are empty,
+// encoding.TextMarshaler implementations, including time.Time, give their
+// text, and floats are written without an exponent.
+func Format(v reflect.Value) (string, error) {
+	v = indirect(v)
+	if !v.IsValid() {
+		return "", nil
+	}
+
+	if m, ok := textMarshaler(v); ok {
+		text, err := m.MarshalText()
+		return string(text), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// textMarshaler returns v as an encoding.TextMarshaler, taking the address
+// of a copy for methods on the pointer.
+func textMarshaler(v reflect.Value) (encoding.TextMarshaler, bool) {
+	if v.Type().Implements(textMarshalerType) {
+		return v.Interface().(encoding.TextMarshaler), true
+	}
+	if reflect.PointerTo(v.Type()).Implements(textMarshalerType) {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		return p.Interface().(encoding.TextMarshaler), true
+	}
+	return nil, false
+}
+
+// indirect follows pointers and interfaces, returning the zero Value for nil.
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func isMap(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String
+}
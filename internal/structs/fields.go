@@ -0,0 +1,208 @@
+// Package structs maps Go structs to CSV columns using `csv` struct tags.
+package structs
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+// Field is a struct field bound to a CSV column.
+//
+// The column is named by the field's `csv` tag, or the field name without
+// one. A tag of "-" skips the field. Fields of embedded structs are
+// promoted, and other nested structs are flattened under their own name
+// and the separator, so Address.City is the column "address.city" for
+//
+//	Address Address `csv:"address"`
+//
+// Structs that implement encoding.TextUnmarshaler or TextMarshaler, and
+// time.Time, are single columns.
+type Field struct {
+	// Name is the column name.
+	Name string
+	// Path is the Go field path, such as "Address.City".
+	Path string
+	// Index is the reflect index sequence of the field.
+	Index []int
+	// Type is the field's type.
+	Type reflect.Type
+	// OmitEmpty is set by the tag option "omitempty".
+	OmitEmpty bool
+
+	depth  int
+	tagged bool
+}
+
+// Fields returns the fields of the struct type t in declaration order.
+// Nested names are joined with sep. Two fields may only share a column
+// name when one is nested less deeply, as with embedding.
+func Fields(t reflect.Type, sep string) ([]Field, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s is not a struct", t)
+	}
+	var all []Field
+	if err := collect(t, sep, "", "", nil, map[reflect.Type]bool{t: true}, &all); err != nil {
+		return nil, err
+	}
+	return dominant(all)
+}
+
+// collect appends the fields of t to all, prefixing names and paths.
+// visiting holds the struct types being expanded, to reject recursion.
+func collect(t reflect.Type, sep, prefix, path string, index []int, visiting map[reflect.Type]bool, all *[]Field) error {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("csv")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		ft := sf.Type
+		nested := ft
+		if nested.Kind() == reflect.Pointer {
+			nested = nested.Elem()
+		}
+		expand := nested.Kind() == reflect.Struct && !single(nested)
+		// Unexported embedded structs still promote their fields, unless
+		// behind a pointer, which reflect cannot allocate
+		if !sf.IsExported() && !(sf.Anonymous && expand && ft.Kind() != reflect.Pointer) {
+			continue
+		}
+
+		f := Field{
+			Path:      joinPath(path, sf.Name),
+			Index:     append(append([]int{}, index...), i),
+			Type:      ft,
+			OmitEmpty: hasOption(options, "omitempty"),
+			depth:     len(index),
+			tagged:    name != "",
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		if expand {
+			if visiting[nested] {
+				return fmt.Errorf("field %s: recursive type %s", f.Path, nested)
+			}
+			visiting[nested] = true
+			// Embedded structs are promoted unless a tag names them
+			inner := prefix
+			if !sf.Anonymous || f.tagged {
+				inner = prefix + name + sep
+			}
+			if err := collect(nested, sep, inner, f.Path, f.Index, visiting, all); err != nil {
+				return err
+			}
+			delete(visiting, nested)
+			continue
+		}
+
+		if !supported(ft) {
+			return fmt.Errorf("field %s: unsupported type %s", f.Path, ft)
+		}
+		f.Name = prefix + name
+		*all = append(*all, f)
+	}
+	return nil
+}
+
+// dominant drops fields hidden by a less deeply nested field of the same
+// name, keeping declaration order, and rejects names that stay ambiguous.
+func dominant(all []Field) ([]Field, error) {
+	byName := make(map[string][]int)
+	for i, f := range all {
+		byName[f.Name] = append(byName[f.Name], i)
+	}
+
+	keep := make([]bool, len(all))
+	for _, f := range all {
+		name := f.Name
+		indexes, pending := byName[name]
+		if !pending {
+			continue
+		}
+		delete(byName, name)
+		sort.SliceStable(indexes, func(a, b int) bool {
+			fa, fb := all[indexes[a]], all[indexes[b]]
+			if fa.depth != fb.depth {
+				return fa.depth < fb.depth
+			}
+			return fa.tagged && !fb.tagged
+		})
+		first := all[indexes[0]]
+		if len(indexes) > 1 {
+			second := all[indexes[1]]
+			if second.depth == first.depth && second.tagged == first.tagged {
+				return nil, fmt.Errorf("fields %s and %s both map to column %q", first.Path, second.Path, name)
+			}
+		}
+		keep[indexes[0]] = true
+	}
+
+	fields := make([]Field, 0, len(all))
+	for i, f := range all {
+		if keep[i] {
+			fields = append(fields, f)
+		}
+	}
+	return fields, nil
+}
+
+// single reports whether the struct type t is one column rather than a
+// group of nested fields.
+func single(t reflect.Type) bool {
+	return t == timeType ||
+		reflect.PointerTo(t).Implements(textUnmarshalerType) ||
+		reflect.PointerTo(t).Implements(textMarshalerType)
+}
+
+// supported reports whether values of t can be read from and written to
+// a CSV field.
+func supported(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if single(t) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Interface:
+		return t.NumMethod() == 0
+	}
+	return false
+}
+
+func hasOption(options, option string) bool {
+	for options != "" {
+		var o string
+		o, options, _ = strings.Cut(options, ",")
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/structs"
+)
+
+type base struct {
+	ID   int    `csv:"id"`
+	Name string `csv:"name"`
+}
+
+type geo struct {
+	Lat, Lng float64
+}
+
+type place struct {
+	*base
+	Name     string `csv:"title"`
+	Location geo    `csv:"loc"`
+	Tagged   geo    `csv:"-"`
+	hidden   string
+}
+
+func names(fields []structs.Field) string {
+	list := make([]string, len(fields))
+	for i, f := range fields {
+		list[i] = f.Name + "=" + f.Path
+	}
+	return strings.Join(list, " ")
+}
+
+func TestFields(t *testing.T) {
+	fields, err := structs.Fields(reflect.TypeOf(place{}), "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unexported embedded pointers are skipped, as they cannot be allocated
+	want := "title=Name loc/Lat=Location.Lat loc/Lng=Location.Lng"
+	if got := names(fields); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestFields_Dominance(t *testing.T) {
+	type outer struct {
+		base
+		ID string `csv:"id"`
+	}
+
+	fields, err := structs.Fields(reflect.TypeOf(outer{}), ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "name=base.Name id=ID"; names(fields) != want {
+		t.Errorf("expected %s, got %s", want, names(fields))
+	}
+}
+
+func TestFields_Errors(t *testing.T) {
+	type duplicate struct {
+		A string `csv:"x"`
+		B string `csv:"x"`
+	}
+	type node struct {
+		Value string
+		Next  *node
+	}
+
+	tests := []struct {
+		t    reflect.Type
+		want string
+	}{
+		{reflect.TypeOf(duplicate{}), `fields A and B both map to column "x"`},
+		{reflect.TypeOf(node{}), "field Next: recursive type"},
+		{reflect.TypeOf(struct{ C chan int }{}), "field C: unsupported type chan int"},
+		{reflect.TypeOf(0), "is not a struct"},
+	}
+
+	for _, tt := range tests {
+		_, err := structs.Fields(tt.t, ".")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.t, tt.want, err)
+		}
+	}
+}
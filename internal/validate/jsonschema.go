@@ -0,0 +1,454 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+)
+
+// annotations are keywords that carry no assertion and are ignored.
+var annotations = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "$anchor": true, "$defs": true,
+	"title": true, "description": true, "default": true, "examples": true,
+	"deprecated": true, "readOnly": true, "writeOnly": true,
+}
+
+// jsonTypes are the type names of JSON Schema.
+var jsonTypes = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true,
+	"number": true, "integer": true, "string": true,
+}
+
+// jsonSchema is a compiled JSON Schema. A boolean schema has only never
+// set, for false; true compiles to an empty schema.
+type jsonSchema struct {
+	never bool
+
+	types    []string
+	enum     []interface{}
+	constant []interface{}
+
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+	format               string
+
+	minimum, maximum                   *float64
+	exclusiveMinimum, exclusiveMaximum *float64
+	multipleOf                         *float64
+
+	properties map[string]*jsonSchema
+	required   []string
+	additional *jsonSchema
+
+	items       *jsonSchema
+	prefixItems []*jsonSchema
+	minItems    *int
+	maxItems    *int
+}
+
+// compileSchema compiles the decoded schema raw found at path.
+func compileSchema(raw interface{}, path string) (*jsonSchema, error) {
+	switch v := raw.(type) {
+	case bool:
+		return &jsonSchema{never: !v}, nil
+	case map[string]interface{}:
+		return compileObject(v, path)
+	}
+	return nil, fmt.Errorf("%sschema must be an object or a boolean", at(path))
+}
+
+func compileObject(raw map[string]interface{}, path string) (*jsonSchema, error) {
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	s := &jsonSchema{}
+	for _, k := range keys {
+		v := raw[k]
+		var err error
+		switch k {
+		case "type":
+			s.types, err = compileTypes(v)
+		case "enum":
+			list, ok := v.([]interface{})
+			if !ok {
+				err = fmt.Errorf("must be an array")
+			}
+			s.enum = list
+		case "const":
+			s.constant = []interface{}{v}
+		case "minLength":
+			s.minLength, err = count(v)
+		case "maxLength":
+			s.maxLength, err = count(v)
+		case "pattern":
+			var p string
+			if p, err = str(v); err == nil {
+				s.pattern, err = regexp.Compile(p)
+			}
+		case "format":
+			s.format, err = str(v)
+		case "minimum":
+			s.minimum, err = number(v)
+		case "maximum":
+			s.maximum, err = number(v)
+		case "exclusiveMinimum":
+			s.exclusiveMinimum, err = number(v)
+		case "exclusiveMaximum":
+			s.exclusiveMaximum, err = number(v)
+		case "multipleOf":
+			if s.multipleOf, err = number(v); err == nil && *s.multipleOf <= 0 {
+				err = fmt.Errorf("must be greater than 0")
+			}
+		case "properties":
+			err = s.compileProperties(v, path)
+		case "required":
+			s.required, err = strs(v)
+		case "additionalProperties":
+			s.additional, err = compileSchema(v, join(path, "*"))
+		case "items":
+			s.items, err = compileSchema(v, path+"[*]")
+		case "prefixItems":
+			err = s.compilePrefixItems(v, path)
+		case "minItems":
+			s.minItems, err = count(v)
+		case "maxItems":
+			s.maxItems, err = count(v)
+		default:
+			if !annotations[k] {
+				err = fmt.Errorf("unsupported keyword")
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s%s: %w", at(path), k, err)
+		}
+	}
+	return s, nil
+}
+
+func (s *jsonSchema) compileProperties(v interface{}, path string) error {
+	properties, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("must be an object")
+	}
+	s.properties = make(map[string]*jsonSchema, len(properties))
+	for name, raw := range properties {
+		property, err := compileSchema(raw, join(path, name))
+		if err != nil {
+			return err
+		}
+		s.properties[name] = property
+	}
+	return nil
+}
+
+func (s *jsonSchema) compilePrefixItems(v interface{}, path string) error {
+	list, ok := v.([]interface{})
+	if !ok {
+		return fmt.Errorf("must be an array")
+	}
+	for i, raw := range list {
+		item, err := compileSchema(raw, fmt.Sprintf("%s[%d]", path, i))
+		if err != nil {
+			return err
+		}
+		s.prefixItems = append(s.prefixItems, item)
+	}
+	return nil
+}
+
+// describesDocument reports whether s is the schema of the whole output,
+// an array of records, rather than of each record.
+func (s *jsonSchema) describesDocument() bool {
+	return len(s.types) == 1 && s.types[0] == "array" && s.items != nil
+}
+
+func compileTypes(v interface{}) ([]string, error) {
+	var types []string
+	switch v := v.(type) {
+	case string:
+		types = []string{v}
+	case []interface{}:
+		var err error
+		if types, err = strs(v); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("must be a string or an array")
+	}
+	for _, t := range types {
+		if !jsonTypes[t] {
+			return nil, fmt.Errorf("unknown type %q", t)
+		}
+	}
+	return types, nil
+}
+
+func count(v interface{}) (*int, error) {
+	n, ok := v.(json.Number)
+	if ok {
+		if i, err := strconv.Atoi(n.String()); err == nil && i >= 0 {
+			return &i, nil
+		}
+	}
+	return nil, fmt.Errorf("must be a non-negative integer")
+}
+
+func number(v interface{}) (*float64, error) {
+	if n, ok := v.(json.Number); ok {
+		if f, err := n.Float64(); err == nil {
+			return &f, nil
+		}
+	}
+	return nil, fmt.Errorf("must be a number")
+}
+
+func str(v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("must be a string")
+}
+
+func strs(v interface{}) ([]string, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be an array of strings")
+	}
+	out := make([]string, len(list))
+	for i, e := range list {
+		s, ok := e.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be an array of strings")
+		}
+		out[i] = s
+	}
+	return out, nil
+}
+
+// at prefixes compile errors with the location of the subschema.
+func at(path string) string {
+	if path == "" {
+		return ""
+	}
+	return path + ": "
+}
+
+// join appends an object member to a path in header syntax.
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// schemaViolation is a failed assertion at a path within a record.
+type schemaViolation struct {
+	path    string
+	value   interface{}
+	keyword string
+	message string
+}
+
+// check appends to violations the assertions of s that v fails.
+func (s *jsonSchema) check(v interface{}, path string, violations []schemaViolation) []schemaViolation {
+	v = normalize(v)
+	fail := func(keyword, format string, args ...interface{}) {
+		violations = append(violations, schemaViolation{path, v, keyword, fmt.Sprintf(format, args...)})
+	}
+
+	if s.never {
+		fail("false", "no value is allowed")
+		return violations
+	}
+
+	if len(s.types) > 0 && !hasType(v, s.types) {
+		fail("type", "expected %s, got %s", typeList(s.types), typeOf(v))
+		// The remaining assertions would only restate the mismatch
+		return violations
+	}
+	if s.enum != nil && !contains(s.enum, v) {
+		fail("enum", "value is not one of the allowed values")
+	}
+	if s.constant != nil && !equal(s.constant[0], v) {
+		fail("const", "value is not the constant")
+	}
+
+	switch v := v.(type) {
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.minLength != nil && n < *s.minLength {
+			fail("minLength", "length %d is less than %d", n, *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			fail("maxLength", "length %d is greater than %d", n, *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("pattern", "does not match pattern %q", s.pattern.String())
+		}
+		if !validFormat(s.format, v) {
+			fail("format", "is not a valid %s", s.format)
+		}
+
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			break
+		}
+		if s.minimum != nil && f < *s.minimum {
+			fail("minimum", "%s is less than %v", v, *s.minimum)
+		}
+		if s.maximum != nil && f > *s.maximum {
+			fail("maximum", "%s is greater than %v", v, *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && f <= *s.exclusiveMinimum {
+			fail("exclusiveMinimum", "%s is not greater than %v", v, *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && f >= *s.exclusiveMaximum {
+			fail("exclusiveMaximum", "%s is not less than %v", v, *s.exclusiveMaximum)
+		}
+		if s.multipleOf != nil {
+			if q := f / *s.multipleOf; q != math.Trunc(q) {
+				fail("multipleOf", "%s is not a multiple of %v", v, *s.multipleOf)
+			}
+		}
+
+	case encoder.Object:
+		present := make(map[string]bool, len(v))
+		for _, m := range v {
+			present[m.Key] = true
+			property := s.properties[m.Key]
+			if property == nil {
+				property = s.additional
+			}
+			if property != nil {
+				violations = property.check(m.Value, join(path, m.Key), violations)
+			}
+		}
+		for _, name := range s.required {
+			if !present[name] {
+				violations = append(violations, schemaViolation{join(path, name), nil, "required", "member is required"})
+			}
+		}
+
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			fail("minItems", "has %d items, fewer than %d", len(v), *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			fail("maxItems", "has %d items, more than %d", len(v), *s.maxItems)
+		}
+		for i, e := range v {
+			item := s.items
+			if i < len(s.prefixItems) {
+				item = s.prefixItems[i]
+			}
+			if item != nil {
+				violations = item.check(e, fmt.Sprintf("%s[%d]", path, i), violations)
+			}
+		}
+	}
+	return violations
+}
+
+// validFormat asserts the formats inferred schemas use; others pass.
+func validFormat(format, v string) bool {
+	var err error
+	switch format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, v)
+	case "date":
+		_, err = time.Parse("2006-01-02", v)
+	}
+	return err == nil
+}
+
+// normalize turns the surplus fields of a row into a JSON array value.
+func normalize(v interface{}) interface{} {
+	if list, ok := v.([]string); ok {
+		values := make([]interface{}, len(list))
+		for i, s := range list {
+			values[i] = s
+		}
+		return values
+	}
+	return v
+}
+
+func typeOf(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if isInteger(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case encoder.Object:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func hasType(v interface{}, types []string) bool {
+	actual := typeOf(v)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func typeList(types []string) string {
+	if len(types) == 1 {
+		return types[0]
+	}
+	return fmt.Sprintf("one of %v", types)
+}
+
+func isInteger(n json.Number) bool {
+	f, err := n.Float64()
+	return err == nil && f == math.Trunc(f) && !math.IsInf(f, 0)
+}
+
+func contains(list []interface{}, v interface{}) bool {
+	for _, e := range list {
+		if equal(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares scalar JSON values, numbers by value. Objects and arrays
+// are never equal.
+func equal(a, b interface{}) bool {
+	an, aok := a.(json.Number)
+	bn, bok := b.(json.Number)
+	if aok && bok {
+		af, aerr := an.Float64()
+		bf, berr := bn.Float64()
+		return aerr == nil && berr == nil && af == bf
+	}
+	switch a.(type) {
+	case nil, bool, string:
+		return a == b
+	}
+	return false
+}
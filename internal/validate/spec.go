@@ -0,0 +1,126 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Mode decides what happens to an upload with violations.
+type Mode string
+
+const (
+	// ModeReject fails the conversion, so nothing is stored.
+	ModeReject Mode = "reject"
+	// ModeReport converts and stores the upload and reports the violations.
+	ModeReport Mode = "report"
+)
+
+// ParseMode returns the Mode named by s. Empty means ModeReject; "accept"
+// is accepted for ModeReport.
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "reject":
+		return ModeReject, nil
+	case "report", "accept":
+		return ModeReport, nil
+	}
+	return "", fmt.Errorf("unknown validation mode %q", s)
+}
+
+// Rule constrains the raw values of one column. Empty values only fail
+// Required; the other checks apply to non-empty values.
+type Rule struct {
+	// Required rejects empty values, and a header without the column.
+	Required bool `json:"required"`
+	// Pattern is a regular expression values must match. It is not
+	// anchored; use ^ and $ to match whole values.
+	Pattern string `json:"pattern"`
+	// Min and Max bound values, which must then be numbers.
+	Min *float64 `json:"min"`
+	Max *float64 `json:"max"`
+	// Allowed lists the only values permitted.
+	Allowed []string `json:"allowed"`
+	// Unique rejects values already seen in an earlier row.
+	Unique bool `json:"unique"`
+}
+
+// Spec is what converted records are validated against: either a JSON
+// Schema or a set of column rules.
+type Spec struct {
+	schema  *jsonSchema
+	rules   map[string]Rule
+	pattern map[string]*regexp.Regexp
+}
+
+// ParseSpec reads a spec from JSON. An object with only a "columns" member
+// maps column names to rules:
+//
+//	{"columns": {"id": {"required": true, "unique": true}, "age": {"min": 0}}}
+//
+// Anything else is a JSON Schema, applied to each record. A schema whose
+// type is "array", such as an inferred one, describes the whole output and
+// its "items" are applied to each record. See NewSchemaSpec for the
+// keywords supported.
+func ParseSpec(data []byte) (*Spec, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err == nil && len(probe) == 1 && probe["columns"] != nil {
+		var set struct {
+			Columns map[string]Rule `json:"columns"`
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&set); err != nil {
+			return nil, fmt.Errorf("invalid column rules: %w", err)
+		}
+		return NewRuleSpec(set.Columns)
+	}
+	return NewSchemaSpec(data)
+}
+
+// NewRuleSpec returns a spec checking each named column against its rule.
+func NewRuleSpec(rules map[string]Rule) (*Spec, error) {
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("column rules must not be empty")
+	}
+
+	spec := &Spec{rules: rules, pattern: make(map[string]*regexp.Regexp)}
+	for name, rule := range rules {
+		if rule.Pattern != "" {
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("column %q: invalid pattern: %w", name, err)
+			}
+			spec.pattern[name] = re
+		}
+		if rule.Min != nil && rule.Max != nil && *rule.Min > *rule.Max {
+			return nil, fmt.Errorf("column %q: min %v exceeds max %v", name, *rule.Min, *rule.Max)
+		}
+	}
+	return spec, nil
+}
+
+// NewSchemaSpec returns a spec checking each record against the JSON
+// Schema in data. The assertions of draft 2020-12 for types, values,
+// strings, numbers, objects and arrays are supported, along with the
+// date-time and date formats. Applicators such as anyOf and $ref are not,
+// and are an error.
+func NewSchemaSpec(data []byte) (*Spec, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var raw interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON Schema: %w", err)
+	}
+
+	root, err := compileSchema(raw, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON Schema: %w", err)
+	}
+	if root.describesDocument() {
+		root = root.items
+	}
+	return &Spec{schema: root}, nil
+}
@@ -0,0 +1,224 @@
+package tests
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+	"github.com/agileproject-gurpreet/csv2json/internal/parser"
+	"github.com/agileproject-gurpreet/csv2json/internal/validate"
+)
+
+// record builds a parser.Record of the given columns and values.
+func record(columns []string, values ...string) parser.Record {
+	r := make(parser.Record, len(columns))
+	for i, c := range columns {
+		r[i] = parser.Field{Name: c, Value: values[i]}
+	}
+	return r
+}
+
+// object builds the JSON object written for a record.
+func object(r parser.Record, values ...interface{}) encoder.Object {
+	o := make(encoder.Object, len(r))
+	for i, f := range r {
+		o[i] = encoder.Member{Key: f.Name, Value: values[i]}
+	}
+	return o
+}
+
+// position places field i of row at line row+1, column 10*i+1.
+func position(row int) func(int) (int, int) {
+	return func(i int) (int, int) { return row + 1, 10*i + 1 }
+}
+
+func mustParse(t *testing.T, spec string) *validate.Spec {
+	t.Helper()
+
+	s, err := validate.ParseSpec([]byte(spec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func rules(violations []validate.Violation) string {
+	var parts []string
+	for _, v := range violations {
+		parts = append(parts, v.Field+":"+v.Rule)
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestValidator_ColumnRules(t *testing.T) {
+	spec := mustParse(t, `{"columns": {
+		"id": {"required": true, "unique": true},
+		"email": {"pattern": "^[^@]+@[^@]+$"},
+		"age": {"min": 0, "max": 150},
+		"status": {"allowed": ["open", "closed"]},
+		"sku": {"required": true}
+	}}`)
+
+	columns := []string{"id", "email", "age", "status"}
+	v := validate.NewValidator(spec, columns)
+	rows := [][]string{
+		{"1", "a@example.com", "30", "open"},
+		{"1", "nope", "200", "pending"},
+		{"", "", "x", ""},
+	}
+	for i, values := range rows {
+		v.Check(i+1, record(columns, values...), nil, position(i+1))
+	}
+
+	report := v.Report()
+	if report.Rows != 3 || report.InvalidRows != 2 || report.Valid() {
+		t.Errorf("unexpected report %+v", report)
+	}
+	want := "sku:required,id:unique,email:pattern,age:max,status:allowed,id:required,age:number"
+	if got := rules(report.Violations); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	dup := report.Violations[1]
+	if dup.Row != 2 || dup.Line != 3 || dup.Column != 1 || dup.Value != "1" || dup.Message != "duplicates row 1" {
+		t.Errorf("unexpected violation %+v", dup)
+	}
+	if missing := report.Violations[0]; missing.Row != 0 || missing.Message != "column is missing" {
+		t.Errorf("unexpected missing column violation %+v", missing)
+	}
+}
+
+func TestValidator_UniqueAfterOtherRule(t *testing.T) {
+	spec := mustParse(t, `{"columns": {"code": {"unique": true, "pattern": "^[A-Z]+$"}}}`)
+
+	columns := []string{"code"}
+	v := validate.NewValidator(spec, columns)
+	for i, code := range []string{"abc", "ABC", "abc"} {
+		v.Check(i+1, record(columns, code), nil, position(i+1))
+	}
+
+	report := v.Report()
+	if want := "code:pattern,code:pattern,code:unique"; rules(report.Violations) != want {
+		t.Fatalf("expected %s, got %s", want, rules(report.Violations))
+	}
+	if last := report.Violations[2]; last.Row != 3 || last.Message != "duplicates row 1" {
+		t.Errorf("unexpected duplicate violation %+v", last)
+	}
+}
+
+func TestValidator_JSONSchema(t *testing.T) {
+	spec := mustParse(t, `{
+		"type": "object",
+		"properties": {
+			"id": {"type": "integer", "minimum": 1},
+			"name": {"type": "string", "maxLength": 5},
+			"status": {"enum": ["open", "closed", null]}
+		},
+		"required": ["id", "name", "status"],
+		"additionalProperties": false
+	}`)
+
+	columns := []string{"id", "name", "status"}
+	v := validate.NewValidator(spec, columns)
+	good := record(columns, "1", "Alice", "open")
+	v.Check(1, good, object(good, json.Number("1"), "Alice", "open"), position(1))
+	bad := record(columns, "0", "Bartholomew", "")
+	v.Check(2, bad, object(bad, json.Number("0"), "Bartholomew", nil), position(2))
+	wrong := record(columns, "x", "Eve", "open")
+	v.Check(3, wrong, append(object(wrong, "x", "Eve", "open"), encoder.Member{Key: "_extra", Value: []string{"y"}}), position(3))
+
+	report := v.Report()
+	want := "id:minimum,name:maxLength,id:type,_extra:false"
+	if got := rules(report.Violations); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if report.InvalidRows != 2 {
+		t.Errorf("expected 2 invalid rows, got %d", report.InvalidRows)
+	}
+	if name := report.Violations[1]; name.Line != 3 || name.Column != 11 || name.Value != "Bartholomew" {
+		t.Errorf("unexpected violation %+v", name)
+	}
+}
+
+func TestValidator_InferredSchema(t *testing.T) {
+	// A schema describing the whole output applies its items to each record
+	spec := mustParse(t, `{"$schema": "https://json-schema.org/draft/2020-12/schema", "type": "array",
+		"items": {"type": "object", "properties": {
+			"address": {"type": "object", "properties": {"city": {"type": "string"}}, "required": ["city"]}
+		}, "required": ["address"]}}`)
+
+	v := validate.NewValidator(spec, []string{"address.city"})
+	r := record([]string{"address.city"}, "7")
+	nested := encoder.Object{{Key: "address", Value: encoder.Object{{Key: "city", Value: json.Number("7")}}}}
+	v.Check(1, r, nested, position(1))
+
+	violations := v.Report().Violations
+	if len(violations) != 1 || violations[0].Field != "address.city" || violations[0].Column != 1 {
+		t.Errorf("unexpected violations %+v", violations)
+	}
+}
+
+func TestValidator_ArrayRecords(t *testing.T) {
+	spec := mustParse(t, `{"type": "array", "prefixItems": [{"type": "string"}, {"type": "integer"}]}`)
+
+	columns := []string{"name", "age"}
+	v := validate.NewValidator(spec, columns)
+	v.Check(1, record(columns, "Alice", "x"), []interface{}{"Alice", "x"}, position(1))
+
+	violations := v.Report().Violations
+	if len(violations) != 1 || violations[0].Field != "age" || violations[0].Column != 11 {
+		t.Errorf("unexpected violations %+v", violations)
+	}
+}
+
+func TestValidator_MaxViolations(t *testing.T) {
+	spec := mustParse(t, `{"columns": {"id": {"required": true}}}`)
+
+	v := validate.NewValidator(spec, []string{"id"})
+	v.MaxViolations = 2
+	for i := 1; i <= 5; i++ {
+		v.Check(i, record([]string{"id"}, ""), nil, position(i))
+	}
+
+	report := v.Report()
+	if report.Total != 5 || len(report.Violations) != 2 || report.InvalidRows != 5 {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
+
+func TestParseSpec_Errors(t *testing.T) {
+	tests := map[string]string{
+		"not JSON":            `{`,
+		"unknown rule":        `{"columns": {"id": {"requird": true}}}`,
+		"empty rules":         `{"columns": {}}`,
+		"bad pattern":         `{"columns": {"id": {"pattern": "("}}}`,
+		"min above max":       `{"columns": {"id": {"min": 2, "max": 1}}}`,
+		"unsupported keyword": `{"anyOf": [{"type": "string"}]}`,
+		"unknown type":        `{"type": "text"}`,
+		"nested error":        `{"properties": {"a": {"minLength": -1}}}`,
+		"not a schema":        `[]`,
+	}
+	for name, spec := range tests {
+		if _, err := validate.ParseSpec([]byte(spec)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	tests := map[string]validate.Mode{
+		"":       validate.ModeReject,
+		"reject": validate.ModeReject,
+		"Report": validate.ModeReport,
+		"accept": validate.ModeReport,
+	}
+	for input, want := range tests {
+		if got, err := validate.ParseMode(input); err != nil || got != want {
+			t.Errorf("%q: expected %q, got %q (%v)", input, want, got, err)
+		}
+	}
+	if _, err := validate.ParseMode("warn"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
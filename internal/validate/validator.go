@@ -0,0 +1,256 @@
+package validate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+	"github.com/agileproject-gurpreet/csv2json/internal/parser"
+)
+
+// DefaultMaxViolations is the most violations a Report lists.
+const DefaultMaxViolations = 1000
+
+// Violation is a value, or a missing column, that fails the spec.
+type Violation struct {
+	// Row is the 1-based data row, or 0 for a problem with the header.
+	Row int `json:"row"`
+	// Line and Column locate the value in the input, when known. Column is
+	// a 1-based byte index in the line.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+	// Field is the column name, or the path within the record for a JSON
+	// Schema violation that is not about a single column.
+	Field string `json:"field"`
+	Value string `json:"value"`
+	// Rule is the failed rule or JSON Schema keyword.
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Report is the outcome of validating a conversion.
+type Report struct {
+	// Rows is the number of records checked.
+	Rows int `json:"rows"`
+	// InvalidRows is the number of records with at least one violation.
+	InvalidRows int `json:"invalid_rows"`
+	// Total is the number of violations found, of which Violations lists
+	// the first Validator.MaxViolations.
+	Total      int         `json:"total"`
+	Violations []Violation `json:"violations"`
+}
+
+// Valid reports whether no violations were found.
+func (r *Report) Valid() bool {
+	return r.Total == 0
+}
+
+// Error fails a conversion validated in ModeReject.
+type Error struct {
+	Report *Report
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("validation failed: %d of %d rows invalid, %d violations",
+		e.Report.InvalidRows, e.Report.Rows, e.Report.Total)
+}
+
+// Validator checks the records of one conversion against a Spec.
+type Validator struct {
+	// MaxViolations is the most violations listed in the report. Zero
+	// means DefaultMaxViolations.
+	MaxViolations int
+
+	spec    *Spec
+	columns []string
+	index   map[string]int
+	rules   []columnRule
+	report  Report
+}
+
+// columnRule is a Rule bound to its column.
+type columnRule struct {
+	Rule
+	name    string
+	column  int
+	pattern *regexp.Regexp
+	// seen maps each value to the row it first appeared in, for Unique.
+	seen map[string]int
+}
+
+// NewValidator returns a Validator for records with the given columns.
+// Required columns missing from them are reported straight away.
+func NewValidator(spec *Spec, columns []string) *Validator {
+	v := &Validator{spec: spec, columns: columns, index: make(map[string]int, len(columns))}
+	for i, name := range columns {
+		v.index[name] = i
+	}
+
+	var missing []string
+	for name, rule := range spec.rules {
+		i, ok := v.index[name]
+		if !ok {
+			if rule.Required {
+				missing = append(missing, name)
+			}
+			continue
+		}
+		c := columnRule{Rule: rule, name: name, column: i, pattern: spec.pattern[name]}
+		if rule.Unique {
+			c.seen = make(map[string]int)
+		}
+		v.rules = append(v.rules, c)
+	}
+	sort.Slice(v.rules, func(i, j int) bool { return v.rules[i].column < v.rules[j].column })
+
+	sort.Strings(missing)
+	for _, name := range missing {
+		v.add(Violation{Field: name, Rule: "required", Message: "column is missing"})
+	}
+	return v
+}
+
+// Check validates one record: the raw fields of data row row, and value,
+// the JSON value written for it. position locates field i in the input.
+func (v *Validator) Check(row int, record parser.Record, value interface{}, position func(int) (int, int)) {
+	v.report.Rows++
+	line, _ := position(0)
+	var found []Violation
+
+	for _, c := range v.rules {
+		if c.column >= len(record) {
+			continue
+		}
+		f := record[c.column]
+		var failed []Violation
+		if message, rule := c.check(f.Value); rule != "" {
+			failed = append(failed, Violation{Rule: rule, Message: message})
+		}
+		// Uniqueness is checked whatever the other rules found, so that
+		// every value is remembered
+		if message, dup := c.duplicate(f.Value, row); dup {
+			failed = append(failed, Violation{Rule: "unique", Message: message})
+		}
+		for _, violation := range failed {
+			violation.Row, violation.Field, violation.Value = row, c.name, f.Value
+			violation.Line, violation.Column = position(c.column)
+			found = append(found, violation)
+		}
+	}
+
+	if v.spec.schema != nil {
+		for _, sv := range v.spec.schema.check(value, "", nil) {
+			violation := Violation{Row: row, Line: line, Field: sv.path, Rule: sv.keyword, Message: sv.message}
+			if i, ok := v.column(sv.path, value); ok {
+				violation.Field = v.columns[i]
+				violation.Line, violation.Column = position(i)
+			}
+			if scalar(sv.value) {
+				violation.Value = encoder.FormatScalar(sv.value)
+			}
+			found = append(found, violation)
+		}
+	}
+
+	if len(found) > 0 {
+		v.report.InvalidRows++
+	}
+	for _, violation := range found {
+		v.add(violation)
+	}
+}
+
+// Report returns the violations found so far.
+func (v *Validator) Report() *Report {
+	report := v.report
+	return &report
+}
+
+func (v *Validator) add(violation Violation) {
+	v.report.Total++
+	limit := v.MaxViolations
+	if limit == 0 {
+		limit = DefaultMaxViolations
+	}
+	if len(v.report.Violations) < limit {
+		v.report.Violations = append(v.report.Violations, violation)
+	}
+}
+
+// column returns the input column a JSON Schema path refers to: a header,
+// or "[n]" when records are written as arrays.
+func (v *Validator) column(path string, record interface{}) (int, bool) {
+	if _, ok := record.([]interface{}); ok {
+		if !strings.HasPrefix(path, "[") || !strings.HasSuffix(path, "]") {
+			return 0, false
+		}
+		i, err := strconv.Atoi(path[1 : len(path)-1])
+		return i, err == nil && i >= 0 && i < len(v.columns)
+	}
+	i, ok := v.index[path]
+	return i, ok
+}
+
+// check returns the first rule other than uniqueness that value fails and
+// why, or an empty rule.
+func (c *columnRule) check(value string) (string, string) {
+	if value == "" {
+		if c.Required {
+			return "value is required", "required"
+		}
+		return "", ""
+	}
+
+	if c.pattern != nil && !c.pattern.MatchString(value) {
+		return fmt.Sprintf("does not match pattern %q", c.Pattern), "pattern"
+	}
+	if c.Min != nil || c.Max != nil {
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return "is not a number", "number"
+		}
+		if c.Min != nil && n < *c.Min {
+			return fmt.Sprintf("%s is less than %v", value, *c.Min), "min"
+		}
+		if c.Max != nil && n > *c.Max {
+			return fmt.Sprintf("%s is greater than %v", value, *c.Max), "max"
+		}
+	}
+	if c.Allowed != nil && !allowed(c.Allowed, value) {
+		return "is not one of the allowed values", "allowed"
+	}
+	return "", ""
+}
+
+// duplicate reports whether a unique column already had value, in which row,
+// and otherwise remembers it for row. Empty values are never duplicates.
+func (c *columnRule) duplicate(value string, row int) (string, bool) {
+	if c.seen == nil || value == "" {
+		return "", false
+	}
+	if first, dup := c.seen[value]; dup {
+		return fmt.Sprintf("duplicates row %d", first), true
+	}
+	c.seen[value] = row
+	return "", false
+}
+
+func allowed(values []string, value string) bool {
+	for _, a := range values {
+		if a == value {
+			return true
+		}
+	}
+	return false
+}
+
+func scalar(v interface{}) bool {
+	switch v.(type) {
+	case encoder.Object, []interface{}:
+		return false
+	}
+	return true
+}
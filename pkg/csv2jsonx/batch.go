@@ -0,0 +1,47 @@
+package csv2jsonx
+
+import (
+	"github.com/agileproject-gurpreet/csv2json/internal/batch"
+)
+
+// BatchOptions controls ConvertBatch.
+type BatchOptions struct {
+	// Options is applied to every file.
+	Options
+	// Workers is the most files converted at once. Zero means the number
+	// of CPUs.
+	Workers int
+	// OutputDir receives the outputs, created if needed. Empty writes each
+	// output next to its input. Files found under a directory keep their
+	// path relative to it.
+	OutputDir string
+	// Recursive also converts CSV files in subdirectories of directories.
+	Recursive bool
+	// OnFile, if set, is called as each file completes, one call at a time.
+	OnFile func(FileResult)
+}
+
+// FileResult is the outcome of converting one file of a batch: its Output
+// path and Rows written, or the Err that made it fail. Err wraps a
+// *ParseError for malformed input.
+type FileResult = batch.FileResult
+
+// BatchResult lists the outcome of each file of a batch, in input order,
+// with the total rows written and the number of failed files.
+type BatchResult = batch.Summary
+
+// ConvertBatch converts the CSV files matched by patterns concurrently.
+// A pattern is a file, a directory whose *.csv files are converted, or a
+// glob such as "data/*.csv". Each file is written as name.json, or
+// name.ndjson for FormatNDJSON, and only once it has converted. A failing
+// file does not stop the others; check BatchResult.Failed. An error is
+// only returned for invalid options or patterns, or when nothing matches.
+func ConvertBatch(patterns []string, opts BatchOptions) (*BatchResult, error) {
+	return batch.Run(patterns, batch.Options{
+		Convert:   opts.Options.converterOptions(),
+		Workers:   opts.Workers,
+		OutputDir: opts.OutputDir,
+		Recursive: opts.Recursive,
+		OnFile:    opts.OnFile,
+	})
+}
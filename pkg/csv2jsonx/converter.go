@@ -1,27 +1,278 @@
 package csv2jsonx
 
 import (
-	"encoding/json"
+	"bytes"
+	"fmt"
 	"io"
 	"os"
 
+	"github.com/agileproject-gurpreet/csv2json/internal/converter"
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+	"github.com/agileproject-gurpreet/csv2json/internal/infer"
 	"github.com/agileproject-gurpreet/csv2json/internal/parser"
 )
 
-func ConvertReader(r io.Reader) ([]byte, error) {
-	records, err := parser.ParseCSV(r)
-	if err != nil {
+// Type is the JSON type a column is converted to.
+type Type string
+
+const (
+	Null      Type = Type(infer.Null)
+	Boolean   Type = Type(infer.Boolean)
+	Integer   Type = Type(infer.Integer)
+	Number    Type = Type(infer.Number)
+	Timestamp Type = Type(infer.Timestamp)
+	String    Type = Type(infer.String)
+)
+
+// Format is the output format of a conversion.
+type Format string
+
+const (
+	// FormatJSON writes a single JSON array.
+	FormatJSON = Format(encoder.FormatJSON)
+	// FormatNDJSON writes one JSON object per line, for log pipelines and
+	// bulk loaders that read JSON Lines.
+	FormatNDJSON = Format(encoder.FormatNDJSON)
+)
+
+// ParseError locates a problem in the CSV input by line and column. Errors
+// returned by the Convert functions wrap it when the input is malformed, so
+// it can be retrieved with errors.As.
+type ParseError = parser.ParseError
+
+// PathError reports a header that cannot be nested under
+// Options.Unflatten, such as "a" alongside "a.b".
+type PathError = encoder.PathError
+
+// HeaderMode controls whether the first row is a header row.
+type HeaderMode int
+
+const (
+	// HeaderPresent always treats the first row as the header row. It is the
+	// default.
+	HeaderPresent = HeaderMode(parser.HeaderPresent)
+	// HeaderAbsent treats every row as data.
+	HeaderAbsent = HeaderMode(parser.HeaderAbsent)
+	// HeaderAuto detects whether the first row is a header row.
+	HeaderAuto = HeaderMode(parser.HeaderAuto)
+)
+
+// RaggedPolicy controls rows with more or fewer fields than the header row.
+type RaggedPolicy int
+
+const (
+	// RaggedStrict fails on the first ragged row with a *ParseError.
+	RaggedStrict = RaggedPolicy(parser.RaggedStrict)
+	// RaggedPad fills missing fields with null; surplus fields still fail.
+	RaggedPad = RaggedPolicy(parser.RaggedPad)
+	// RaggedExtra pads like RaggedPad and collects surplus fields under
+	// the "_extra" key, or at the end of the array with Arrays.
+	RaggedExtra = RaggedPolicy(parser.RaggedExtra)
+	// RaggedSkip drops ragged rows.
+	RaggedSkip = RaggedPolicy(parser.RaggedSkip)
+)
+
+// HeaderCase converts header names before they become keys.
+type HeaderCase int
+
+const (
+	// CaseKeep leaves header names as they are.
+	CaseKeep = HeaderCase(parser.CaseKeep)
+	// CaseSnake converts header names to snake_case.
+	CaseSnake = HeaderCase(parser.CaseSnake)
+	// CaseCamel converts header names to camelCase.
+	CaseCamel = HeaderCase(parser.CaseCamel)
+)
+
+// Options controls a conversion. The zero value detects the dialect and
+// converts every value to a JSON string, as ConvertReader does.
+type Options struct {
+	// Delimiter is the field delimiter, such as ';' or '\t'. Zero means
+	// detect it.
+	Delimiter rune
+	// Quote is the quote character. Zero means detect it.
+	Quote rune
+	// Comment starts lines that are skipped, such as '#'. Zero means none.
+	Comment rune
+	// LazyQuotes accepts a quote inside an unquoted field, and a lone quote
+	// inside a quoted one, instead of failing with a *ParseError.
+	LazyQuotes bool
+	// TrimSpace removes surrounding whitespace from every field and header.
+	TrimSpace bool
+	// TrimHeaders removes surrounding whitespace from header names only.
+	TrimHeaders bool
+	// HeaderCase converts header names to snake_case or camelCase.
+	HeaderCase HeaderCase
+	// Ragged controls rows whose field count differs from the header row.
+	Ragged RaggedPolicy
+	// InferTypes emits integers, floats, booleans, nulls and RFC 3339
+	// timestamps as native JSON types, inferred from each column.
+	InferTypes bool
+	// ColumnTypes fixes the type of the named columns.
+	ColumnTypes map[string]Type
+	// Header controls whether the first row is a header row. Files without
+	// one get generated names column_1, column_2, ...
+	Header HeaderMode
+	// ColumnNames names the columns instead of the header row.
+	ColumnNames []string
+	// Arrays writes each record as a JSON array of values instead of an object.
+	Arrays bool
+	// Unflatten builds nested objects and arrays from header paths such as
+	// "address.city" and "tags[0]". It cannot be combined with Arrays.
+	Unflatten bool
+	// Separator splits header paths for Unflatten; empty means ".".
+	Separator string
+	// Format is the output format; empty means FormatJSON.
+	Format Format
+	// Indent pretty-prints FormatJSON output, indenting each level by
+	// Indent, such as "  ". Empty writes compact JSON.
+	Indent string
+	// Encoding is the source encoding: utf-8, utf-16, utf-16le, utf-16be,
+	// windows-1252, iso-8859-1 or iso-8859-15. Empty means UTF-8, or UTF-16
+	// when the input starts with its byte order mark.
+	Encoding string
+}
+
+// Validate reports options that cannot be used, such as a delimiter equal
+// to the quote or Unflatten with Arrays. NewConverter calls it, and the
+// Convert functions fail with its error before reading any input.
+func (o Options) Validate() error {
+	if err := o.converterOptions().Validate(); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	return nil
+}
+
+func (o Options) converterOptions() converter.Options {
+	opts := converter.Options{
+		Parser: parser.Options{
+			Delimiter:   o.Delimiter,
+			Quote:       o.Quote,
+			Comment:     o.Comment,
+			LazyQuotes:  o.LazyQuotes,
+			TrimSpace:   o.TrimSpace,
+			TrimHeaders: o.TrimHeaders,
+			HeaderCase:  parser.HeaderCase(o.HeaderCase),
+			Ragged:      parser.RaggedPolicy(o.Ragged),
+			Encoding:    o.Encoding,
+			Header:      parser.HeaderMode(o.Header),
+			ColumnNames: o.ColumnNames,
+		},
+		InferTypes: o.InferTypes,
+		Arrays:     o.Arrays,
+		Unflatten:  o.Unflatten,
+		Separator:  o.Separator,
+		Format:     encoder.Format(o.Format),
+		Indent:     o.Indent,
+	}
+	if len(o.ColumnTypes) > 0 {
+		opts.ColumnTypes = make(map[string]infer.Type, len(o.ColumnTypes))
+		for column, t := range o.ColumnTypes {
+			opts.ColumnTypes[column] = infer.Type(t)
+		}
+	}
+	return opts
+}
+
+// Converter converts CSV to JSON with a fixed set of validated Options.
+// It is safe for concurrent use.
+type Converter struct {
+	opts Options
+}
+
+// NewConverter returns a Converter using opts, or an error describing the
+// first option that cannot be used.
+func NewConverter(opts Options) (*Converter, error) {
+	if err := opts.Validate(); err != nil {
 		return nil, err
 	}
-	return json.Marshal(records)
+	// Copy what the caller could change under us
+	if opts.ColumnTypes != nil {
+		types := make(map[string]Type, len(opts.ColumnTypes))
+		for column, t := range opts.ColumnTypes {
+			types[column] = t
+		}
+		opts.ColumnTypes = types
+	}
+	if opts.ColumnNames != nil {
+		opts.ColumnNames = append([]string{}, opts.ColumnNames...)
+	}
+	return &Converter{opts: opts}, nil
 }
 
-func ConvertFile(filePath string) ([]byte, error) {
+// Options returns the options c converts with.
+func (c *Converter) Options() Options {
+	return c.opts
+}
+
+// ConvertReader converts the CSV read from r and returns the JSON.
+func (c *Converter) ConvertReader(r io.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.ConvertReaderTo(&buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ConvertFile converts the CSV file at filePath and returns the JSON.
+func (c *Converter) ConvertFile(filePath string) ([]byte, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 
-	return ConvertReader(file)
+	return c.ConvertReader(file)
+}
+
+// ConvertReaderTo streams the CSV read from r to w, writing each record as
+// soon as it is parsed.
+func (c *Converter) ConvertReaderTo(w io.Writer, r io.Reader) error {
+	_, err := converter.Convert(r, w, c.opts.converterOptions())
+	return err
+}
+
+// ConvertReader converts the CSV read from r to a JSON array of objects
+// keyed by the header row, with every value a string and the dialect
+// detected.
+func ConvertReader(r io.Reader) ([]byte, error) {
+	return ConvertReaderWithOptions(r, Options{})
+}
+
+// ConvertFile converts the CSV file at filePath as ConvertReader does.
+func ConvertFile(filePath string) ([]byte, error) {
+	return ConvertFileWithOptions(filePath, Options{})
+}
+
+// ConvertReaderTo streams the CSV read from r to w as a JSON array, writing
+// each record as soon as it is parsed.
+func ConvertReaderTo(w io.Writer, r io.Reader) error {
+	return ConvertReaderToWithOptions(w, r, Options{})
+}
+
+// ConvertReaderWithOptions is ConvertReader with options.
+func ConvertReaderWithOptions(r io.Reader, opts Options) ([]byte, error) {
+	c, err := NewConverter(opts)
+	if err != nil {
+		return nil, err
+	}
+	return c.ConvertReader(r)
+}
+
+// ConvertFileWithOptions is ConvertFile with options.
+func ConvertFileWithOptions(filePath string, opts Options) ([]byte, error) {
+	c, err := NewConverter(opts)
+	if err != nil {
+		return nil, err
+	}
+	return c.ConvertFile(filePath)
+}
+
+// ConvertReaderToWithOptions is ConvertReaderTo with options.
+func ConvertReaderToWithOptions(w io.Writer, r io.Reader, opts Options) error {
+	c, err := NewConverter(opts)
+	if err != nil {
+		return err
+	}
+	return c.ConvertReaderTo(w, r)
 }
@@ -0,0 +1,174 @@
+package csv2jsonx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+	"github.com/agileproject-gurpreet/csv2json/internal/parser"
+	"github.com/agileproject-gurpreet/csv2json/internal/structs"
+)
+
+// DecodeError reports a value that cannot be stored in its struct field,
+// with the data row, the line and column in the input, the CSV column and
+// the Go field. It wraps the conversion error.
+type DecodeError = structs.DecodeError
+
+// Decoder reads CSV records into values of type T, a struct or a pointer
+// to one, without going through JSON.
+//
+// Columns map to exported fields by their `csv` tag, or the field name
+// without one, matched exactly or else ignoring case:
+//
+//	type Order struct {
+//		ID       int       `csv:"id"`
+//		Placed   time.Time `csv:"placed_at"`
+//		Discount *float64  `csv:"discount"`
+//		Status   Status    `csv:"status"` // an encoding.TextUnmarshaler
+//		Internal string    `csv:"-"`
+//		Address  Address   `csv:"address"` // columns "address.city", ...
+//	}
+//
+// Strings, bools, integers, floats, time.Time (RFC 3339 or a date) and
+// types implementing encoding.TextUnmarshaler are supported, and pointers
+// to them. Empty values leave numbers, bools, times and pointers zero.
+// Fields of embedded structs are promoted; other nested structs read the
+// columns named by their own name, Options.Separator and the field name.
+// Columns without a field are ignored.
+//
+// The reading options apply; InferTypes, ColumnTypes, Arrays, Unflatten,
+// Format and Indent do not.
+//
+// Typical use:
+//
+//	d := csv2jsonx.NewDecoder[Order](file, csv2jsonx.Options{})
+//	for d.Next() {
+//		order := d.Value()
+//		...
+//	}
+//	if err := d.Err(); err != nil {
+//		...
+//	}
+type Decoder[T any] struct {
+	reader  *parser.Reader
+	opts    Options
+	decoder *structs.Decoder
+	row     int
+	value   T
+	err     error
+}
+
+// NewDecoder returns a Decoder reading CSV from r using opts. Invalid
+// options, and struct types that cannot be decoded into, are reported by
+// Err once Next returns false.
+func NewDecoder[T any](r io.Reader, opts Options) *Decoder[T] {
+	d := &Decoder[T]{opts: opts}
+	if err := opts.Validate(); err != nil {
+		d.err = err
+		return d
+	}
+	d.reader = parser.NewReaderWithOptions(r, opts.converterOptions().Parser)
+	return d
+}
+
+// Next decodes the next record, reporting whether there was one.
+func (d *Decoder[T]) Next() bool {
+	if d.err != nil {
+		return false
+	}
+	if d.decoder == nil {
+		if d.err = d.init(); d.err != nil {
+			return false
+		}
+	}
+	if !d.reader.Next() {
+		// Input without even a header row has no records
+		if err := d.reader.Err(); !errors.Is(err, io.EOF) {
+			d.err = err
+		}
+		return false
+	}
+	d.row++
+
+	var value T
+	target := reflect.ValueOf(&value).Elem()
+	if target.Kind() == reflect.Pointer {
+		target.Set(reflect.New(target.Type().Elem()))
+		target = target.Elem()
+	}
+	if err := d.decoder.Decode(d.reader.Record(), d.row, d.reader.Position, target); err != nil {
+		d.err = err
+		return false
+	}
+	d.value = value
+	return true
+}
+
+// init checks T and matches its fields to the header row.
+func (d *Decoder[T]) init() error {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot decode into %s: not a struct", t)
+	}
+
+	headers, err := d.reader.Headers()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	sep := d.opts.Separator
+	if sep == "" {
+		sep = encoder.DefaultSeparator
+	}
+	if d.decoder, err = structs.NewDecoder(t, headers, sep); err != nil {
+		return fmt.Errorf("cannot decode into %s: %w", t, err)
+	}
+	return nil
+}
+
+// Value returns the record decoded by the last call to Next.
+func (d *Decoder[T]) Value() T {
+	return d.value
+}
+
+// Err returns the first error encountered: a *ParseError for malformed
+// input, or a *DecodeError for a value that does not fit its field.
+// Reaching the end of the input is not an error.
+func (d *Decoder[T]) Err() error {
+	return d.err
+}
+
+// Decode reads all CSV records from r into values of type T, as described
+// for Decoder.
+func Decode[T any](r io.Reader) ([]T, error) {
+	return DecodeWithOptions[T](r, Options{})
+}
+
+// DecodeWithOptions is Decode with options.
+func DecodeWithOptions[T any](r io.Reader, opts Options) ([]T, error) {
+	d := NewDecoder[T](r, opts)
+	values := []T{}
+	for d.Next() {
+		values = append(values, d.Value())
+	}
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Unmarshal decodes the CSV in data into the slice v points to, replacing
+// its contents.
+func Unmarshal[T any](data []byte, v *[]T) error {
+	values, err := Decode[T](bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	*v = values
+	return nil
+}
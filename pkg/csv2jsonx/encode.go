@@ -0,0 +1,113 @@
+package csv2jsonx
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/encoder"
+	"github.com/agileproject-gurpreet/csv2json/internal/structs"
+)
+
+// Validate reports options that cannot be used, such as a delimiter equal
+// to the quote. The CSV writing functions fail with its error before
+// reading or writing anything.
+func (o CSVOptions) Validate() error {
+	if err := o.converterOptions().Validate(); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	return nil
+}
+
+// Encoder writes Go structs and maps as CSV, the reverse of Decoder.
+//
+// Struct fields become columns by the same `csv` tags and rules Decoder
+// reads them with, in declaration order: nested structs are flattened
+// into columns such as "address.city", and "-" skips a field. The tag
+// option "omitempty" writes an empty field for a zero value:
+//
+//	type Order struct {
+//		ID     int       `csv:"id"`
+//		Placed time.Time `csv:"placed_at"`
+//		Note   string    `csv:"note,omitempty"`
+//	}
+//
+// Values implementing encoding.TextMarshaler, such as time.Time, are
+// written as their text; nil pointers are empty. Maps are written with
+// their keys sorted, nested maps and slices flattened as ConvertJSONToCSV
+// flattens objects and arrays.
+//
+// The header row is CSVOptions.Columns if set, dropping other columns, or
+// else comes from the first record: the fields of its type, or the keys
+// of a map. A later record with a column outside it is an error.
+type Encoder struct {
+	out *encoder.CSVWriter
+	enc *structs.Encoder
+	err error
+}
+
+// NewEncoder returns an Encoder writing to w. Invalid options are returned
+// by the first call to Encode or EncodeAll.
+func NewEncoder(w io.Writer, opts CSVOptions) *Encoder {
+	e := &Encoder{}
+	if e.err = opts.Validate(); e.err != nil {
+		return e
+	}
+	sep := opts.Separator
+	if sep == "" {
+		sep = encoder.DefaultSeparator
+	}
+	e.out = encoder.NewCSVWriter(w, opts.Delimiter, opts.Quote, opts.QuoteAll)
+	e.enc = structs.NewEncoder(e.out, opts.Columns, sep)
+	return e
+}
+
+// Encode writes v, a struct, a pointer to one, or a map with string keys,
+// as one row, preceded by the header row on the first call.
+func (e *Encoder) Encode(v interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+	if err := e.enc.Encode(reflect.ValueOf(v)); err != nil {
+		return err
+	}
+	return e.out.Flush()
+}
+
+// EncodeAll writes each record of v, a slice or array of records, or a
+// channel of them, which is read until closed. With a slice of maps and
+// no Columns, the header row is the union of their keys in order of first
+// appearance. The header row of structs is written even without records.
+func (e *Encoder) EncodeAll(v interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+	if err := e.enc.EncodeAll(reflect.ValueOf(v)); err != nil {
+		return err
+	}
+	return e.out.Flush()
+}
+
+// Rows returns the number of records written, excluding the header row.
+func (e *Encoder) Rows() int {
+	if e.enc == nil {
+		return 0
+	}
+	return e.enc.Rows()
+}
+
+// Marshal returns the CSV encoding of v, a slice, array or channel of
+// structs or maps, as written by Encoder.EncodeAll.
+func Marshal(v interface{}) ([]byte, error) {
+	return MarshalWithOptions(v, CSVOptions{})
+}
+
+// MarshalWithOptions is Marshal with options.
+func MarshalWithOptions(v interface{}, opts CSVOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, opts).EncodeAll(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,57 @@
+package csv2jsonx
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/converter"
+)
+
+// CSVOptions controls a JSON to CSV conversion. The zero value writes
+// comma separated CSV, quoting fields only where needed.
+type CSVOptions struct {
+	// Delimiter is the field delimiter; zero means ','.
+	Delimiter rune
+	// Quote is the quote character; zero means '"'.
+	Quote rune
+	// QuoteAll quotes every field.
+	QuoteAll bool
+	// Separator joins nested keys into header names; empty means ".".
+	Separator string
+	// Columns fixes the header row, dropping other keys, and lets records
+	// be written as they are read. By default the header is the union of
+	// the keys of all records, in order of first appearance.
+	Columns []string
+}
+
+func (o CSVOptions) converterOptions() converter.CSVOptions {
+	return converter.CSVOptions{
+		Delimiter: o.Delimiter,
+		Quote:     o.Quote,
+		QuoteAll:  o.QuoteAll,
+		Separator: o.Separator,
+		Columns:   o.Columns,
+	}
+}
+
+// ConvertJSONToCSV converts a JSON array of objects, or NDJSON with one
+// object per line, to CSV. Nested objects and arrays become headers such as
+// "address.city" and "tags[0]", the reverse of Options.Unflatten.
+func ConvertJSONToCSV(r io.Reader) ([]byte, error) {
+	return ConvertJSONToCSVWithOptions(r, CSVOptions{})
+}
+
+// ConvertJSONToCSVWithOptions is ConvertJSONToCSV with options.
+func ConvertJSONToCSVWithOptions(r io.Reader, opts CSVOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := ConvertJSONToCSVTo(&buf, r, opts); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ConvertJSONToCSVTo writes the CSV converted from the JSON read from r to w.
+func ConvertJSONToCSVTo(w io.Writer, r io.Reader, opts CSVOptions) error {
+	_, err := converter.ConvertJSON(r, w, opts.converterOptions())
+	return err
+}
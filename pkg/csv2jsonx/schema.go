@@ -0,0 +1,32 @@
+package csv2jsonx
+
+import (
+	"io"
+
+	"github.com/agileproject-gurpreet/csv2json/internal/converter"
+	"github.com/agileproject-gurpreet/csv2json/internal/schema"
+)
+
+// Schema is a JSON Schema (draft 2020-12) describing converted records.
+// It marshals to the schema document with encoding/json.
+type Schema = schema.Schema
+
+// InferSchema returns the JSON Schema of the records the CSV read from r
+// converts to with type inference: each column's type and nullability,
+// enums for columns with a few repeated values, and string lengths.
+func InferSchema(r io.Reader) (*Schema, error) {
+	return InferSchemaWithOptions(r, Options{InferTypes: true})
+}
+
+// InferSchemaWithOptions returns the JSON Schema of the records the CSV
+// read from r converts to with opts. Without InferTypes every value is a
+// string, and so is every column in the schema.
+func InferSchemaWithOptions(r io.Reader, opts Options) (*Schema, error) {
+	copts := opts.converterOptions()
+	copts.Schema = true
+	result, err := converter.Convert(r, io.Discard, copts)
+	if err != nil {
+		return nil, err
+	}
+	return result.Schema, nil
+}
@@ -0,0 +1,41 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/pkg/csv2jsonx"
+)
+
+func TestConvertBatch(t *testing.T) {
+	dir, out := t.TempDir(), t.TempDir()
+	for name, content := range map[string]string{
+		"a.csv": "id,qty\n1,5\n",
+		"b.csv": "id,qty\n2,\"7\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := csv2jsonx.ConvertBatch([]string{dir}, csv2jsonx.BatchOptions{
+		Options:   csv2jsonx.Options{InferTypes: true},
+		OutputDir: out,
+		Workers:   2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Rows != 1 || result.Failed != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	data, err := os.ReadFile(filepath.Join(out, "a.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `[{"id":1,"qty":5}]` {
+		t.Errorf("unexpected a.json %s", data)
+	}
+}
@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/pkg/csv2jsonx"
+)
+
+func TestConvertReader(t *testing.T) {
+	result, err := csv2jsonx.ConvertReader(strings.NewReader("name,age\nAlice,30"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `[{"name":"Alice","age":"30"}]`; string(result) != want {
+		t.Errorf("expected %s, got %s", want, result)
+	}
+}
+
+func TestConvertReaderWithOptions_InferTypes(t *testing.T) {
+	opts := csv2jsonx.Options{
+		InferTypes:  true,
+		ColumnTypes: map[string]csv2jsonx.Type{"zip": csv2jsonx.String},
+	}
+	result, err := csv2jsonx.ConvertReaderWithOptions(strings.NewReader("zip,age,active\n02134,30,true"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `[{"zip":"02134","age":30,"active":true}]`; string(result) != want {
+		t.Errorf("expected %s, got %s", want, result)
+	}
+}
+
+func TestConvertReaderWithOptions_Headerless(t *testing.T) {
+	opts := csv2jsonx.Options{Header: csv2jsonx.HeaderAbsent, ColumnNames: []string{"sku", "name"}}
+	result, err := csv2jsonx.ConvertReaderWithOptions(strings.NewReader("A1,Widget"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `[{"sku":"A1","name":"Widget"}]`; string(result) != want {
+		t.Errorf("expected %s, got %s", want, result)
+	}
+}
+
+func TestConvertReaderWithOptions_GeneratedNames(t *testing.T) {
+	result, err := csv2jsonx.ConvertReaderWithOptions(strings.NewReader("A1,Widget"), csv2jsonx.Options{Header: csv2jsonx.HeaderAbsent})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `[{"column_1":"A1","column_2":"Widget"}]`; string(result) != want {
+		t.Errorf("expected %s, got %s", want, result)
+	}
+}
+
+func TestConvertReader_ParseError(t *testing.T) {
+	_, err := csv2jsonx.ConvertReader(strings.NewReader("name,age\nAlice,30\nBob\n"))
+
+	var parseErr *csv2jsonx.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if parseErr.Line != 3 || parseErr.Snippet != "Bob" {
+		t.Errorf("unexpected error: %+v", parseErr)
+	}
+}
+
+func TestConvertReaderWithOptions_Unflatten(t *testing.T) {
+	opts := csv2jsonx.Options{Unflatten: true, InferTypes: true}
+	input := "id,address.city,address.zip,tags[0],tags[1]\n1,Paris,75001,a,b"
+	result, err := csv2jsonx.ConvertReaderWithOptions(strings.NewReader(input), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `[{"id":1,"address":{"city":"Paris","zip":75001},"tags":["a","b"]}]`; string(result) != want {
+		t.Errorf("expected %s, got %s", want, result)
+	}
+}
+
+func TestConvertReaderWithOptions_UnflattenConflict(t *testing.T) {
+	opts := csv2jsonx.Options{Unflatten: true, Separator: "/"}
+	_, err := csv2jsonx.ConvertReaderWithOptions(strings.NewReader("a,a/b\n1,2"), opts)
+
+	var pathErr *csv2jsonx.PathError
+	if !errors.As(err, &pathErr) {
+		t.Fatalf("expected PathError, got %v", err)
+	}
+	if pathErr.Key != "a/b" || pathErr.Other != "a" {
+		t.Errorf("unexpected error: %+v", pathErr)
+	}
+}
+
+func TestConvertReaderToWithOptions_NDJSON(t *testing.T) {
+	var buf strings.Builder
+	opts := csv2jsonx.Options{Format: csv2jsonx.FormatNDJSON}
+	if err := csv2jsonx.ConvertReaderToWithOptions(&buf, strings.NewReader("name,age\nAlice,30\nBob,25"), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "{\"name\":\"Alice\",\"age\":\"30\"}\n{\"name\":\"Bob\",\"age\":\"25\"}\n"; buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestConverter(t *testing.T) {
+	c, err := csv2jsonx.NewConverter(csv2jsonx.Options{
+		Delimiter:  ';',
+		Comment:    '#',
+		LazyQuotes: true,
+		TrimSpace:  true,
+		HeaderCase: csv2jsonx.CaseSnake,
+		Indent:     "  ",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := "# inventory export\nItem Name; Size\n bolt ; 3\" long\n"
+	result, err := c.ConvertReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "[\n  {\n    \"item_name\": \"bolt\",\n    \"size\": \"3\\\" long\"\n  }\n]"
+	if string(result) != want {
+		t.Errorf("expected %s, got %s", want, result)
+	}
+}
+
+func TestConverter_Ragged(t *testing.T) {
+	c, err := csv2jsonx.NewConverter(csv2jsonx.Options{Ragged: csv2jsonx.RaggedSkip})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.ConvertReader(strings.NewReader("name,age\nAlice,30\nBob\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"name":"Alice","age":"30"}]`; string(result) != want {
+		t.Errorf("expected %s, got %s", want, result)
+	}
+}
+
+func TestNewConverter_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts csv2jsonx.Options
+		want string
+	}{
+		{"delimiter", csv2jsonx.Options{Delimiter: '\n'}, "invalid delimiter"},
+		{"quote", csv2jsonx.Options{Delimiter: ';', Quote: ';'}, "delimiter and quote must differ"},
+		{"comment", csv2jsonx.Options{Delimiter: '#', Comment: '#'}, "comment must differ"},
+		{"indent", csv2jsonx.Options{Indent: "--"}, "indent must be spaces or tabs"},
+		{"ndjson indent", csv2jsonx.Options{Indent: "  ", Format: csv2jsonx.FormatNDJSON}, "indent cannot be combined"},
+		{"format", csv2jsonx.Options{Format: "xml"}, "unknown format"},
+		{"ragged", csv2jsonx.Options{Ragged: 9}, "invalid ragged row policy"},
+		{"header case", csv2jsonx.Options{HeaderCase: 9}, "invalid header case"},
+		{"arrays", csv2jsonx.Options{Arrays: true, Unflatten: true}, "unflatten cannot be combined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := csv2jsonx.NewConverter(tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+			if !strings.HasPrefix(err.Error(), "invalid options: ") {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestConvertReaderWithOptions_InvalidOptions(t *testing.T) {
+	// The input is never read
+	_, err := csv2jsonx.ConvertReaderWithOptions(errReader{}, csv2jsonx.Options{Quote: 'é'})
+	if err == nil || !strings.Contains(err.Error(), "invalid quote") {
+		t.Errorf("expected invalid quote error, got %v", err)
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("read called")
+}
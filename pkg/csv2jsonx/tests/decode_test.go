@@ -0,0 +1,232 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agileproject-gurpreet/csv2json/pkg/csv2jsonx"
+)
+
+type status int
+
+const (
+	statusOpen status = iota + 1
+	statusClosed
+)
+
+func (s *status) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "open":
+		*s = statusOpen
+	case "closed":
+		*s = statusClosed
+	default:
+		return fmt.Errorf("unknown status %q", text)
+	}
+	return nil
+}
+
+type address struct {
+	City string `csv:"city"`
+	Zip  string `csv:"zip"`
+}
+
+type audit struct {
+	CreatedBy string `csv:"created_by"`
+}
+
+type order struct {
+	audit
+	ID       int       `csv:"id"`
+	Total    float64   `csv:"total"`
+	Paid     bool      `csv:"paid"`
+	Placed   time.Time `csv:"placed_at"`
+	Discount *float64  `csv:"discount"`
+	Status   status    `csv:"status"`
+	Ship     address   `csv:"ship"`
+	Note     string
+	Internal string `csv:"-"`
+}
+
+func TestDecode(t *testing.T) {
+	input := "id,total,paid,placed_at,discount,status,ship.city,ship.zip,NOTE,Internal,created_by,unused\n" +
+		"1,9.5,true,2024-03-01T10:00:00Z,0.1,open,Paris,75001,fragile,x,alice,?\n" +
+		"2,20,false,2024-03-02,,closed,Oslo,0150,,x,bob,?\n"
+
+	orders, err := csv2jsonx.Decode[order](strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 {
+		t.Fatalf("expected 2 orders, got %d", len(orders))
+	}
+
+	first := orders[0]
+	if first.ID != 1 || first.Total != 9.5 || !first.Paid || first.Status != statusOpen {
+		t.Errorf("unexpected order %+v", first)
+	}
+	if !first.Placed.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected placed_at %v", first.Placed)
+	}
+	if first.Discount == nil || *first.Discount != 0.1 {
+		t.Errorf("unexpected discount %v", first.Discount)
+	}
+	if first.Ship != (address{City: "Paris", Zip: "75001"}) || first.CreatedBy != "alice" {
+		t.Errorf("unexpected nested fields %+v", first)
+	}
+	if first.Note != "fragile" || first.Internal != "" {
+		t.Errorf("unexpected untagged fields %+v", first)
+	}
+
+	second := orders[1]
+	if second.Discount != nil || second.Status != statusClosed || second.Ship.Zip != "0150" {
+		t.Errorf("unexpected order %+v", second)
+	}
+	if !second.Placed.Equal(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected placed_at %v", second.Placed)
+	}
+}
+
+func TestDecode_Pointers(t *testing.T) {
+	type person struct {
+		Name string `csv:"name"`
+		Age  *int   `csv:"age"`
+	}
+
+	people, err := csv2jsonx.Decode[*person](strings.NewReader("name,age\nAlice,30\nBob,\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(people) != 2 || *people[0].Age != 30 || people[1].Name != "Bob" || people[1].Age != nil {
+		t.Errorf("unexpected people %+v %+v", people[0], people[1])
+	}
+}
+
+func TestDecode_UnexportedEmbeddedPointer(t *testing.T) {
+	type record struct {
+		*audit
+		ID int `csv:"id"`
+	}
+
+	records, err := csv2jsonx.Decode[record](strings.NewReader("id,created_by\n1,alice\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].ID != 1 || records[0].audit != nil {
+		t.Errorf("expected the unexported embedded pointer to be skipped, got %+v", records)
+	}
+}
+
+func TestDecode_Error(t *testing.T) {
+	type person struct {
+		Name string `csv:"name"`
+		Age  uint8  `csv:"age"`
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"name,age\nAlice,30\nBob,old\n", `"old" is not a valid uint8`},
+		{"name,age\nAlice,30\nBob,300\n", `"300" is out of range for uint8`},
+	}
+
+	for _, tt := range tests {
+		_, err := csv2jsonx.Decode[person](strings.NewReader(tt.input))
+
+		var decodeErr *csv2jsonx.DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Fatalf("expected DecodeError, got %v", err)
+		}
+		if decodeErr.Row != 2 || decodeErr.Line != 3 || decodeErr.Column != 5 {
+			t.Errorf("unexpected position %+v", decodeErr)
+		}
+		if decodeErr.Header != "age" || decodeErr.Field != "Age" {
+			t.Errorf("unexpected column or field %+v", decodeErr)
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("expected error containing %q, got %v", tt.want, err)
+		}
+	}
+}
+
+func TestDecode_TextUnmarshalerError(t *testing.T) {
+	_, err := csv2jsonx.Decode[order](strings.NewReader("id,status\n1,lost\n"))
+
+	want := `row 1 (line 2, column 3): column "status" into field Status: unknown status "lost"`
+	if err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
+func TestDecode_UnsupportedType(t *testing.T) {
+	type bad struct {
+		Tags []string `csv:"tags"`
+	}
+
+	_, err := csv2jsonx.Decode[bad](strings.NewReader("tags\na\n"))
+	if err == nil || !strings.Contains(err.Error(), "field Tags: unsupported type []string") {
+		t.Errorf("expected unsupported type error, got %v", err)
+	}
+
+	if _, err := csv2jsonx.Decode[int](strings.NewReader("a\n1\n")); err == nil {
+		t.Error("expected error decoding into int")
+	}
+}
+
+func TestDecodeWithOptions(t *testing.T) {
+	type item struct {
+		SKU   string `csv:"sku"`
+		Count int    `csv:"count"`
+	}
+
+	opts := csv2jsonx.Options{Delimiter: ';', Comment: '#', TrimSpace: true}
+	items, err := csv2jsonx.DecodeWithOptions[item](strings.NewReader("# stock\nsku;count\n A1 ; 4 \n"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 1 || items[0] != (item{SKU: "A1", Count: 4}) {
+		t.Errorf("unexpected items %+v", items)
+	}
+}
+
+func TestDecoder_Stream(t *testing.T) {
+	type row struct {
+		N int `csv:"n"`
+	}
+
+	d := csv2jsonx.NewDecoder[row](strings.NewReader("n\n1\n2\n3\n"), csv2jsonx.Options{})
+	sum := 0
+	for d.Next() {
+		sum += d.Value().N
+	}
+	if err := d.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if sum != 6 {
+		t.Errorf("expected 6, got %d", sum)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	type row struct {
+		Name string `csv:"name"`
+	}
+
+	rows := []row{{Name: "stale"}}
+	if err := csv2jsonx.Unmarshal([]byte("name\nAlice\n"), &rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Name != "Alice" {
+		t.Errorf("unexpected rows %+v", rows)
+	}
+
+	if err := csv2jsonx.Unmarshal([]byte(""), &rows); err != nil || len(rows) != 0 {
+		t.Errorf("expected no rows, got %+v, %v", rows, err)
+	}
+}
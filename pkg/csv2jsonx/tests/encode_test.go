@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agileproject-gurpreet/csv2json/pkg/csv2jsonx"
+)
+
+func (s status) MarshalText() ([]byte, error) {
+	switch s {
+	case statusOpen:
+		return []byte("open"), nil
+	case statusClosed:
+		return []byte("closed"), nil
+	}
+	return nil, errors.New("unknown status")
+}
+
+func TestMarshal_Structs(t *testing.T) {
+	discount := 0.25
+	orders := []order{
+		{
+			audit:  audit{CreatedBy: "alice"},
+			ID:     1,
+			Total:  9.5,
+			Paid:   true,
+			Placed: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+			Status: statusOpen,
+			Ship:   address{City: "Paris, FR", Zip: "75001"},
+			Note:   `say "hi"`,
+		},
+		{ID: 2, Total: 1e21, Discount: &discount, Status: statusClosed, Internal: "hidden"},
+	}
+
+	result, err := csv2jsonx.Marshal(orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "created_by,id,total,paid,placed_at,discount,status,ship.city,ship.zip,Note\n" +
+		"alice,1,9.5,true,2024-03-01T10:00:00Z,,open,\"Paris, FR\",75001,\"say \"\"hi\"\"\"\n" +
+		",2,1000000000000000000000,false,0001-01-01T00:00:00Z,0.25,closed,,,\n"
+	if string(result) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, result)
+	}
+
+	// What Marshal writes, Decode reads back
+	decoded, err := csv2jsonx.Decode[order](bytes.NewReader(result))
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders[1].Internal = ""
+	if len(decoded) != 2 || decoded[0] != orders[0] || decoded[1].Discount == nil || *decoded[1].Discount != 0.25 {
+		t.Errorf("round trip changed the orders: %+v", decoded)
+	}
+}
+
+func TestMarshal_OmitEmptyAndPointers(t *testing.T) {
+	type item struct {
+		SKU   string    `csv:"sku"`
+		Count int       `csv:"count,omitempty"`
+		Ship  *address  `csv:"ship"`
+		Seen  time.Time `csv:"seen,omitempty"`
+	}
+
+	result, err := csv2jsonx.Marshal([]*item{{SKU: "A1"}, {SKU: "B2", Count: 3, Ship: &address{City: "Oslo"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "sku,count,ship.city,ship.zip,seen\nA1,,,,\nB2,3,Oslo,,\n"; string(result) != want {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+}
+
+func TestMarshal_EmptySliceWritesHeader(t *testing.T) {
+	result, err := csv2jsonx.Marshal([]address{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "city,zip\n"; string(result) != want {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+}
+
+func TestMarshal_Maps(t *testing.T) {
+	records := []map[string]interface{}{
+		{"name": "Alice", "age": 30, "address": map[string]interface{}{"city": "Paris"}},
+		{"name": "Bob", "tags": []string{"a", "b"}, "active": true},
+	}
+
+	result, err := csv2jsonx.Marshal(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "address.city,age,name,active,tags[0],tags[1]\n" +
+		"Paris,30,Alice,,,\n" +
+		",,Bob,true,a,b\n"
+	if string(result) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, result)
+	}
+}
+
+func TestEncoder_Channel(t *testing.T) {
+	records := make(chan address, 2)
+	records <- address{City: "Paris", Zip: "75001"}
+	records <- address{City: "Oslo", Zip: "0150"}
+	close(records)
+
+	var buf bytes.Buffer
+	e := csv2jsonx.NewEncoder(&buf, csv2jsonx.CSVOptions{Delimiter: ';', Columns: []string{"zip", "city"}})
+	if err := e.EncodeAll(records); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "zip;city\n75001;Paris\n0150;Oslo\n"; buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+	if e.Rows() != 2 {
+		t.Errorf("expected 2 rows, got %d", e.Rows())
+	}
+}
+
+func TestEncoder_Encode(t *testing.T) {
+	var buf bytes.Buffer
+	e := csv2jsonx.NewEncoder(&buf, csv2jsonx.CSVOptions{})
+
+	if err := e.Encode(map[string]string{"b": "2", "a": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "a,b\n1,2\n"; buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+
+	err := e.Encode(map[string]string{"a": "3", "c": "4"})
+	if err == nil || !strings.Contains(err.Error(), `record 2: "c" is not in the header row`) {
+		t.Errorf("expected header error, got %v", err)
+	}
+}
+
+func TestEncoder_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts csv2jsonx.CSVOptions
+		v    interface{}
+		want string
+	}{
+		{"options", csv2jsonx.CSVOptions{Delimiter: '"'}, []address{}, "invalid options: delimiter and quote must differ"},
+		{"not a list", csv2jsonx.CSVOptions{}, address{}, "not a slice, array or channel"},
+		{"not a record", csv2jsonx.CSVOptions{}, []int{1}, "record 1: cannot encode int: not a struct or map"},
+		{"marshaler", csv2jsonx.CSVOptions{}, []order{{Status: 7}}, "record 1: field Status: unknown status"},
+		{"unsupported", csv2jsonx.CSVOptions{}, []map[string]interface{}{{"f": func() {}}}, "f: unsupported type func()"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := csv2jsonx.NewEncoder(&bytes.Buffer{}, tt.opts).EncodeAll(tt.v)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/pkg/csv2jsonx"
+)
+
+func TestConvertJSONToCSV_Array(t *testing.T) {
+	input := `[
+		{"name":"Alice","age":30,"address":{"city":"Paris","zip":"75001"},"tags":["a","b"]},
+		{"name":"Bob","email":"bob@example.com","active":true,"age":null}
+	]`
+	result, err := csv2jsonx.ConvertJSONToCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "name,age,address.city,address.zip,tags[0],tags[1],email,active\n" +
+		"Alice,30,Paris,75001,a,b,,\n" +
+		"Bob,,,,,,bob@example.com,true\n"
+	if string(result) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, result)
+	}
+}
+
+func TestConvertJSONToCSV_NDJSON(t *testing.T) {
+	input := "{\"id\":1,\"price\":1.50}\n{\"id\":2,\"price\":12345678901234567890}\n"
+	result, err := csv2jsonx.ConvertJSONToCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "id,price\n1,1.50\n2,12345678901234567890\n"; string(result) != want {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+}
+
+func TestConvertJSONToCSVWithOptions(t *testing.T) {
+	opts := csv2jsonx.CSVOptions{
+		Delimiter: ';',
+		Quote:     '\'',
+		Separator: "_",
+		Columns:   []string{"note", "user_name"},
+	}
+	input := `[{"user":{"name":"O'Brien"},"note":"a;b","dropped":1}]`
+	result, err := csv2jsonx.ConvertJSONToCSVWithOptions(strings.NewReader(input), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "note;user_name\n'a;b';'O''Brien'\n"; string(result) != want {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+}
+
+func TestConvertJSONToCSV_QuoteAll(t *testing.T) {
+	opts := csv2jsonx.CSVOptions{QuoteAll: true}
+	result, err := csv2jsonx.ConvertJSONToCSVWithOptions(strings.NewReader(`{"a":"x \"y\""}`), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "\"a\"\n\"x \"\"y\"\"\"\n"; string(result) != want {
+		t.Errorf("expected %q, got %q", want, result)
+	}
+}
+
+func TestConvertJSONToCSV_RoundTrip(t *testing.T) {
+	csv := "id,address.city,tags[0],tags[1]\n1,Paris,a,b\n"
+	json, err := csv2jsonx.ConvertReaderWithOptions(strings.NewReader(csv), csv2jsonx.Options{Unflatten: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := csv2jsonx.ConvertJSONToCSV(strings.NewReader(string(json)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result) != csv {
+		t.Errorf("expected %q, got %q", csv, result)
+	}
+}
+
+func TestConvertJSONToCSV_Errors(t *testing.T) {
+	tests := map[string]string{
+		"not an object": `[1]`,
+		"truncated":     `[{"a":1}`,
+		"syntax":        `{"a":}`,
+		"trailing data": `[{"a":1}] x`,
+	}
+	for name, input := range tests {
+		if _, err := csv2jsonx.ConvertJSONToCSV(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestConvertJSONToCSV_Empty(t *testing.T) {
+	for _, input := range []string{"", "[]", "  \n"} {
+		result, err := csv2jsonx.ConvertJSONToCSV(strings.NewReader(input))
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", input, err)
+		}
+		if len(result) != 0 {
+			t.Errorf("%q: expected no output, got %q", input, result)
+		}
+	}
+}
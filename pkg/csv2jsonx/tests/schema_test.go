@@ -0,0 +1,46 @@
+package tests
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/agileproject-gurpreet/csv2json/pkg/csv2jsonx"
+)
+
+func TestInferSchema(t *testing.T) {
+	csv := "id,name,score\n1,Alice,9.5\n2,Bob,\n"
+	s, err := csv2jsonx.InferSchema(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := s.Items
+	if items == nil {
+		t.Fatal("expected an items schema")
+	}
+	if got := items.Property("id").Types(); len(got) != 1 || got[0] != "integer" {
+		t.Errorf("expected id to be an integer, got %v", got)
+	}
+	if got := items.Property("score").Types(); strings.Join(got, ",") != "number,null" {
+		t.Errorf("expected score to be a nullable number, got %v", got)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"$schema":"https://json-schema.org/draft/2020-12/schema"`) {
+		t.Errorf("expected a $schema keyword, got %s", data)
+	}
+}
+
+func TestInferSchemaWithOptions_NoInference(t *testing.T) {
+	s, err := csv2jsonx.InferSchemaWithOptions(strings.NewReader("id\n1\n2\n"), csv2jsonx.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Items.Property("id").Types(); len(got) != 1 || got[0] != "string" {
+		t.Errorf("expected id to be a string, got %v", got)
+	}
+}